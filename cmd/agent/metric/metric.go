@@ -58,7 +58,8 @@ type RuntimeMetrics struct {
 	RandomValue Gauge
 }
 
-func (m *RuntimeMetrics) ForEach(key string, metricHandler func(metric *common.Metric)) {
+// ForEach passes every metric with labels and hash signed by key (if key is not empty) to metricHandler.
+func (m *RuntimeMetrics) ForEach(key string, labels map[string]string, metricHandler func(metric *common.Metric)) {
 	if m == nil {
 		return
 	}
@@ -71,8 +72,9 @@ func (m *RuntimeMetrics) ForEach(key string, metricHandler func(metric *common.M
 		metricValue := fmt.Sprint(v.Field(i))
 
 		metric := &common.Metric{
-			ID:    metricName,
-			MType: metricType,
+			ID:     metricName,
+			MType:  metricType,
+			Labels: labels,
 		}
 
 		switch metricType {
@@ -17,13 +17,15 @@ import (
 type HTTPClient struct {
 	endpointURL string
 	hashKey     string
+	labels      map[string]string
+	tenant      string
 
 	rsaKey *rsa.PublicKey
 }
 
 func (s *HTTPClient) SendMetrics(runtimeMetrics metric.RuntimeMetrics) {
 	metricsArr := []*common.Metric{}
-	runtimeMetrics.ForEach(s.hashKey, func(metric *common.Metric) {
+	runtimeMetrics.ForEach(s.hashKey, s.labels, func(metric *common.Metric) {
 		metricsArr = append(metricsArr, metric)
 	})
 
@@ -61,6 +63,10 @@ func (s *HTTPClient) SendMetrics(runtimeMetrics metric.RuntimeMetrics) {
 
 	req.Header.Set("Content-Type", "application/json")
 
+	if s.tenant != "" {
+		req.Header.Set(common.TenantHeader, s.tenant)
+	}
+
 	if s.rsaKey != nil {
 		req.Header.Set("Content-Encoding", "gzip")
 	}
@@ -77,7 +83,7 @@ func (s *HTTPClient) SendMetrics(runtimeMetrics metric.RuntimeMetrics) {
 }
 
 func (s *HTTPClient) SendMetricsOneByOne(runtimeMetrics metric.RuntimeMetrics) {
-	runtimeMetrics.ForEach(s.hashKey, func(metric *common.Metric) {
+	runtimeMetrics.ForEach(s.hashKey, s.labels, func(metric *common.Metric) {
 		metricBytes, err := metric.MarshalJSON()
 		if err != nil {
 			log.Println(err)
@@ -100,6 +106,10 @@ func (s *HTTPClient) SendMetricsOneByOne(runtimeMetrics metric.RuntimeMetrics) {
 
 		req.Header.Add("Content-Type", "application/json")
 
+		if s.tenant != "" {
+			req.Header.Set(common.TenantHeader, s.tenant)
+		}
+
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			log.Println(err)
@@ -117,5 +127,7 @@ func InitHTTPClient(config common.AgentConfig, ctx context.Context) *HTTPClient
 		endpointURL: "http://" + config.Address,
 		rsaKey:      config.CryptoKey.PublicKey,
 		hashKey:     config.Key,
+		labels:      config.Labels,
+		tenant:      config.Tenant,
 	}
 }
@@ -7,19 +7,21 @@ import (
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	pb "github.com/GermanVor/devops-pet-project/proto"
 )
 
 type RPCClient struct {
 	hashKey string
+	labels  map[string]string
 	c       pb.MetricsClient
 	ctx     context.Context
 }
 
 func (s *RPCClient) SendMetrics(runtimeMetrics metric.RuntimeMetrics) {
 	metricsArr := make([]*pb.Metric, 0)
-	runtimeMetrics.ForEach(s.hashKey, func(metric *common.Metric) {
+	runtimeMetrics.ForEach(s.hashKey, s.labels, func(metric *common.Metric) {
 		metricsArr = append(metricsArr, pb.GetProtoMetric(metric))
 	})
 
@@ -27,7 +29,7 @@ func (s *RPCClient) SendMetrics(runtimeMetrics metric.RuntimeMetrics) {
 }
 
 func (s *RPCClient) SendMetricsOneByOne(runtimeMetrics metric.RuntimeMetrics) {
-	runtimeMetrics.ForEach(s.hashKey, func(metric *common.Metric) {
+	runtimeMetrics.ForEach(s.hashKey, s.labels, func(metric *common.Metric) {
 		s.c.AddMetric(s.ctx, &pb.AddMetricRequest{
 			Metric: pb.GetProtoMetric(metric),
 		})
@@ -40,8 +42,13 @@ func InitRPCClient(config common.AgentConfig, ctx context.Context) (*RPCClient,
 		return nil, err
 	}
 
+	if config.Tenant != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, common.TenantHeader, config.Tenant)
+	}
+
 	return &RPCClient{
 		hashKey: config.Key,
+		labels:  config.Labels,
 		c:       pb.NewMetricsClient(conn),
 		ctx:     ctx,
 	}, err
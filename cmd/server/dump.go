@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+)
+
+const (
+	dumpUsage    = "usage: server [flags] dump FILE"
+	restoreUsage = "usage: server [flags] restore FILE|- [merge|overwrite]"
+)
+
+var (
+	ErrDumpUsage    = errors.New(dumpUsage)
+	ErrRestoreUsage = errors.New(restoreUsage)
+)
+
+// openStorage opens the storage of Config without wrappers of the service.
+// Memory storage is loaded from STORE_FILE and, if persist is set, saved back to it by the returned function.
+func openStorage(ctx context.Context, persist bool) (storage.StorageInterface, func(), error) {
+	if Config.DataBaseDSN != "" {
+		sqlStorage, err := storage.InitV2(ctx, Config.DataBaseDSN)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return sqlStorage, sqlStorage.Close, nil
+	}
+
+	if Config.StoreFile == "" {
+		return nil, nil, errors.New("database dsn or store file is required")
+	}
+
+	switch Config.StoreBackend {
+	case common.BoltStoreBackend:
+		boltStorage, err := storage.InitBolt(Config.StoreFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return boltStorage, boltStorage.Close, nil
+	case "", common.MemoryStoreBackend:
+		codec, err := storage.NewBackupCodec(Config.StoreCompression, Config.StoreEncryptionKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if Config.StoreEncryptionMigrate {
+			codec.AllowUnencrypted()
+		}
+
+		stor, err := storage.InitShardedWithCodec(&Config.StoreFile, Config.StoreShards, codec)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, err
+		}
+
+		if !persist {
+			return stor, func() {}, nil
+		}
+
+		backupConfig := storage.BackupConfig{
+			FilePath:  Config.StoreFile,
+			Retention: Config.StoreRetention,
+			Codec:     codec,
+		}
+
+		walStor, err := storage.WithWAL(stor, backupConfig, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return walStor, walStor.Close, nil
+	default:
+		return nil, nil, common.ErrUnknownStoreBackend
+	}
+}
+
+// runDump executes `dump` command: writes all the metrics of the storage to FILE.
+//
+//	server -d postgres://... dump metrics.dump
+//	server -f /tmp/devops-metrics-db.json dump metrics.dump
+func runDump(args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return ErrDumpUsage
+	}
+
+	ctx := context.Background()
+
+	stor, closeStorage, err := openStorage(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+
+	count, err := storage.Dump(ctx, stor, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d metrics are dumped\n", count)
+
+	return nil
+}
+
+// runRestore executes `restore` command: saves metrics of FILE (`-` is stdin) to the storage.
+// `merge` adds counters and histograms to the stored ones, `overwrite` (default) sets metrics to dumped values.
+//
+//	server -d postgres://... restore metrics.dump
+//	server -d postgres://... restore metrics.dump merge
+func runRestore(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return ErrRestoreUsage
+	}
+
+	mode := storage.RestoreOverwrite
+	if len(args) == 2 {
+		var err error
+		if mode, err = storage.ParseRestoreMode(args[1]); err != nil {
+			return ErrRestoreUsage
+		}
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		r = file
+	}
+
+	ctx := context.Background()
+
+	stor, closeStorage, err := openStorage(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	count, err := storage.Restore(ctx, stor, r, mode)
+	if err != nil {
+		return fmt.Errorf("%d metrics are restored: %w", count, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%d metrics are restored (%s)\n", count, mode)
+
+	return nil
+}
@@ -1,8 +1,12 @@
 package handlers_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	handlers "github.com/GermanVor/devops-pet-project/cmd/server/handlers"
@@ -32,3 +36,69 @@ func BenchmarkGetAllMetrics(b *testing.B) {
 
 	handler.ServeHTTP(rr, req)
 }
+
+// agentBatch builds /updates/ body of an agent with 32 metrics like the real agent sends.
+func agentBatch(agentID int64) ([]byte, []byte) {
+	metrics := make([]common.Metric, 0, 32)
+
+	for i := 0; i < 31; i++ {
+		value := float64(i)
+		metrics = append(metrics, common.Metric{
+			ID:    fmt.Sprintf("agent%d-gauge%d", agentID, i),
+			MType: common.GaugeMetricName,
+			Value: &value,
+		})
+	}
+
+	delta := int64(1)
+	metrics = append(metrics, common.Metric{
+		ID:    fmt.Sprintf("agent%d-PollCount", agentID),
+		MType: common.CounterMetricName,
+		Delta: &delta,
+	})
+
+	batchBytes, _ := json.Marshal(metrics)
+	getBytes, _ := json.Marshal(common.Metric{ID: metrics[0].ID, MType: metrics[0].MType})
+
+	return batchBytes, getBytes
+}
+
+// BenchmarkConcurrentAgents runs many agents sending batches and reading metrics
+// against memory storage with a single shard (global lock) and with default shards.
+func BenchmarkConcurrentAgents(b *testing.B) {
+	for _, shardsCount := range []int{1, storage.DefaultShardsCount} {
+		b.Run(fmt.Sprintf("shards=%d", shardsCount), func(b *testing.B) {
+			stor, _ := storage.InitSharded(nil, shardsCount)
+			s := handlers.InitStorageWrapper(stor, "")
+
+			agentsCounter := int64(0)
+
+			b.SetParallelism(64)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				batchBytes, getBytes := agentBatch(atomic.AddInt64(&agentsCounter, 1))
+
+				for pb.Next() {
+					req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(batchBytes))
+					rr := httptest.NewRecorder()
+					s.UpdateMetrics(rr, req)
+
+					if rr.Code != http.StatusOK {
+						b.Error("/updates/ status", rr.Code)
+						return
+					}
+
+					req = httptest.NewRequest(http.MethodPost, "/value/", bytes.NewReader(getBytes))
+					rr = httptest.NewRecorder()
+					s.GetMetric(rr, req)
+
+					if rr.Code != http.StatusOK {
+						b.Error("/value/ status", rr.Code)
+						return
+					}
+				}
+			})
+		})
+	}
+}
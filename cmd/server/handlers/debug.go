@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"net/http"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+)
+
+// CacheStats Handler to get hit, miss and eviction statistics of the storage cache.
+//
+// Response is JSON object.
+//
+//	type CacheStats struct {
+//		Hits      uint64 `json:"hits"`
+//		Misses    uint64 `json:"misses"`
+//		Evictions uint64 `json:"evictions"`
+//		Size      int    `json:"size"`     // количество метрик в кеше
+//		Capacity  int    `json:"capacity"` // CACHE_SIZE
+//	}
+func CacheStats(cache *storage.CacheStorageWrapper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResp, _ := json.Marshal(cache.Stats())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
+
+// WatchStats Handler to get subscription statistics of the watch hub.
+//
+// Response is JSON object.
+//
+//	type WatchStats struct {
+//		Subscriptions int    `json:"subscriptions"` // количество активных подписок
+//		Published     uint64 `json:"published"`     // количество доставленных событий
+//		Lagged        uint64 `json:"lagged"`        // количество подписок, отключенных из-за переполнения буфера
+//		BufferSize    int    `json:"buffer_size"`   // WATCH_BUFFER_SIZE
+//	}
+func WatchStats(hub *storage.WatchHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResp, _ := json.Marshal(hub.Stats())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
+
+// Faults Handler to get (GET), set (PUT, POST) and turn off (DELETE) faults injected into storage.
+//
+// key - secret key for authorization, X-Hash header of PUT, POST and DELETE should be common.FaultsHash(key).
+//
+// Request of PUT and POST is JSON object, response is JSON object with the current config and statistics.
+//
+//	type FaultConfig struct {
+//		Methods          []string `json:"methods,omitempty"`  // методы хранилища с ошибками (пусто - все)
+//		Latency          string   `json:"latency"`            // задержка каждого вызова, например "100ms"
+//		LatencyJitter    string   `json:"latency_jitter"`     // случайная добавка к задержке от 0 до значения
+//		ErrorRate        float64  `json:"error_rate"`         // доля вызовов с ошибкой
+//		TimeoutRate      float64  `json:"timeout_rate"`       // доля зависших вызовов
+//		Timeout          string   `json:"timeout"`            // время зависания вызова
+//		PartialBatchRate float64  `json:"partial_batch_rate"` // доля пачек, сохраненных частично
+//		Seed             int64    `json:"seed,omitempty"`     // для повторяемых ошибок
+//	}
+//
+//	type FaultState struct {
+//		Config FaultConfig `json:"config"`
+//		Stats  struct {
+//			Calls          uint64 `json:"calls"`
+//			Delayed        uint64 `json:"delayed"`
+//			Errors         uint64 `json:"errors"`
+//			Timeouts       uint64 `json:"timeouts"`
+//			PartialBatches uint64 `json:"partial_batches"`
+//		} `json:"stats"`
+//	}
+func Faults(faults *storage.FaultStorageWrapper, key string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key != "" && r.Method != http.MethodGet &&
+			!hmac.Equal([]byte(r.Header.Get(common.HashHeader)), []byte(common.FaultsHash(key))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			config := storage.FaultConfig{}
+			if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := faults.SetConfig(config); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			faults.SetConfig(storage.FaultConfig{})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		jsonResp, _ := json.Marshal(faults.State())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
+
+// SelfMetrics Handler to get latency, errors and in-flight calls of the storage and timing of its backups.
+//
+// Response is JSON array of Metric ordered by id and labels.
+//
+//	StorageLatency  histogram {method="UpdateMetrics"} // время вызова метода хранилища в секундах
+//	StorageErrors   counter   {method="UpdateMetrics"} // количество вызовов с ошибкой
+//	StorageInFlight gauge     {method="UpdateMetrics"} // количество выполняемых вызовов
+//	BackupLatency   histogram {phase="snapshot|write"} // время копирования под блокировкой и записи резервной копии
+//	BackupErrors    counter   {phase="snapshot|write"} // количество неудачных записей резервной копии
+func SelfMetrics(metrics *storage.StorageMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResp, _ := json.Marshal(metrics.Metrics())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
@@ -13,20 +13,30 @@ import (
 
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/crypto"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
 )
 
 // UpdateMetric Handler to save Agent metrics by request Body.
 //
 // key - secret key to for authorization.
 //
-// Expected Request Body interface is Metric.
+// Expected Request Body interface is Metric. Gauge with Precondition is saved only if the stored gauge
+// matches it, otherwise response status is 409 Conflict.
 //
 //	type Metric struct {
 //		ID    string   `json:"id"`              // имя метрики
-//		MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
+//		MType string   `json:"type"`            // параметр, принимающий значение gauge, counter, histogram или summary
 //		Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 //		Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 //		Hash  *string   `json:"hash,omitempty"`  // значение хеш-функции
+//
+//		Labels map[string]string `json:"labels,omitempty"` // метки серии (host, region, ...)
+//
+//		Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+//		Summary   *Summary   `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+//
+//		Version      *uint64       `json:"version,omitempty"`      // версия gauge, возвращается сервером
+//		Precondition *Precondition `json:"precondition,omitempty"` // условие обновления gauge
 //	}
 func (s *StorageWrapper) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 	metric := &common.Metric{}
@@ -36,8 +46,10 @@ func (s *StorageWrapper) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.key != "" {
-		if ok, _ := metric.CheckHash(s.key); !ok {
+	r = s.withHashTenant(r, metric)
+
+	if key := s.hashKey(r.Context()); key != "" {
+		if ok, _ := metric.CheckHash(key); !ok {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
@@ -46,29 +58,45 @@ func (s *StorageWrapper) UpdateMetric(w http.ResponseWriter, r *http.Request) {
 	switch metric.MType {
 	case common.CounterMetricName:
 	case common.GaugeMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if err := metric.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := s.stor.UpdateMetric(r.Context(), *metric); err == nil {
 		w.WriteHeader(http.StatusOK)
 	} else {
 		log.Println(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), updateErrorStatus(err, http.StatusInternalServerError))
 	}
 }
 
 // UpdateMetrics Handler to save pack of Metric by request Body.
 //
-// Expected Request Body interface is []Metric.
+// Expected Request Body interface is []Metric. Nothing is saved if any of preconditions is not satisfied
+// (409 Conflict).
 //
 //	type Metric struct {
 //		ID    string   `json:"id"`              // имя метрики
-//		MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
+//		MType string   `json:"type"`            // параметр, принимающий значение gauge, counter, histogram или summary
 //		Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 //		Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 //		Hash  *string   `json:"hash,omitempty"`  // значение хеш-функции
+//
+//		Labels map[string]string `json:"labels,omitempty"` // метки серии (host, region, ...)
+//
+//		Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+//		Summary   *Summary   `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+//
+//		Version      *uint64       `json:"version,omitempty"`      // версия gauge, возвращается сервером
+//		Precondition *Precondition `json:"precondition,omitempty"` // условие обновления gauge
 //	}
 func (s *StorageWrapper) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 	metricsArr := []common.Metric{}
@@ -78,18 +106,29 @@ func (s *StorageWrapper) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if s.key != "" {
+	if len(metricsArr) != 0 {
+		r = s.withHashTenant(r, &metricsArr[0])
+	}
+
+	if key := s.hashKey(r.Context()); key != "" {
 		for _, m := range metricsArr {
-			if ok, _ := m.CheckHash(s.key); !ok {
+			if ok, _ := m.CheckHash(key); !ok {
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 		}
 	}
 
+	for _, m := range metricsArr {
+		if err := m.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	err := s.stor.UpdateMetrics(r.Context(), metricsArr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), updateErrorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -111,13 +150,22 @@ func MissedMetricNameHandlerFunc(w http.ResponseWriter, r *http.Request) {
 // key - secret key to for authorization.
 //
 // Expected Request Body interface is Metric. Delta and Value field in Request will be ignored.
+// Type rate returns per-second rate of the counter in Value field, gauge is returned with its Version.
 //
 //	type Metric struct {
 //		ID    string   `json:"id"`              // имя метрики
-//		MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
+//		MType string   `json:"type"`            // параметр, принимающий значение gauge, counter, histogram или summary
 //		Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 //		Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 //		Hash  *string   `json:"hash,omitempty"`  // значение хеш-функции
+//
+//		Labels map[string]string `json:"labels,omitempty"` // метки серии (host, region, ...)
+//
+//		Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+//		Summary   *Summary   `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+//
+//		Version      *uint64       `json:"version,omitempty"`      // версия gauge, возвращается сервером
+//		Precondition *Precondition `json:"precondition,omitempty"` // условие обновления gauge
 //	}
 //
 // Response is Metric Value as String.
@@ -133,12 +181,15 @@ func (s *StorageWrapper) GetMetric(w http.ResponseWriter, r *http.Request) {
 	switch metric.MType {
 	case common.GaugeMetricName:
 	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	case common.RateMetricName:
 	default:
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	storMetric, err := s.stor.GetMetric(r.Context(), metric.MType, metric.ID)
+	storMetric, err := s.stor.GetMetric(r.Context(), metric.MType, metric.Key())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -149,15 +200,24 @@ func (s *StorageWrapper) GetMetric(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	metric.Precondition = nil
+
 	switch metric.MType {
 	case common.GaugeMetricName:
 		metric.Value = &storMetric.Value
+		metric.Version = &storMetric.Version
+	case common.RateMetricName:
+		metric.Value = &storMetric.Value
 	case common.CounterMetricName:
 		metric.Delta = &storMetric.Delta
+	case common.HistogramMetricName:
+		metric.Histogram = storMetric.Histogram
+	case common.SummaryMetricName:
+		metric.Summary = storMetric.Summary
 	}
 
-	if s.key != "" {
-		metric.SetHash(s.key)
+	if key := s.hashKey(r.Context()); key != "" {
+		metric.SetHash(key)
 	}
 
 	jsonResp, _ := metric.MarshalJSON()
@@ -246,3 +306,23 @@ func MiddlewareTrustedSubnet(trustedSubnet string) HandlerResponse {
 		})
 	}
 }
+
+// MiddlewareTenant scopes request to the tenant of common.TenantHeader, unknown tenant is rejected.
+func MiddlewareTenant(tenants *storage.Tenants) HandlerResponse {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Header.Get(common.TenantHeader)
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if tenants.Get(name) == nil {
+				http.Error(w, "unknown tenant", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(storage.ContextWithTenant(r.Context(), name)))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/go-chi/chi"
+)
+
+// DeleteMetric Handler to delete metric with its history by URL.
+//
+// URL view: DELETE /value/{mType}/{id} where
+// mType - (gauge|counter|histogram|summary), id - Metric Id or series key with labels (HeapAlloc{host="a"}).
+//
+// key - secret key to for authorization, X-Hash header should be common.DeleteMetricHash(mType, id, key).
+//
+// Response is 200 if metric is deleted, 404 if there is no such metric.
+func (s *StorageWrapper) DeleteMetric(w http.ResponseWriter, r *http.Request) {
+	mType := chi.URLParam(r, "mType")
+
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, labels, err := seriesParam(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	seriesKey := common.SeriesKey(id, labels)
+
+	if key := s.hashKey(r.Context()); key != "" {
+		if r.Header.Get(common.HashHeader) != common.DeleteMetricHash(mType, seriesKey, key) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	deleted, err := s.stor.DeleteMetric(r.Context(), mType, seriesKey)
+	if err != nil {
+		http.Error(w, err.Error(), updateErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+	"github.com/go-chi/chi"
+)
+
+type HistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Delta     *int64    `json:"delta,omitempty"`
+	Value     *float64  `json:"value,omitempty"`
+	Min       *float64  `json:"min,omitempty"`
+	Max       *float64  `json:"max,omitempty"`
+
+	Histogram *common.Histogram `json:"histogram,omitempty"`
+	Summary   *common.Summary   `json:"summary,omitempty"`
+}
+
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetMetricHistory Handler to get timestamped history of the Metric.
+//
+// URL view: /history/{mType}/{id}?from={from}&to={to}&step={step} where
+// mType - (gauge|counter|histogram|summary), id - Metric Id or series key with labels (HeapAlloc{host="a"}),
+// from, to - optional RFC3339 range bounds, step - optional duration (1m, 30s) to downsample points.
+//
+// Response is JSON array of points.
+//
+//	type HistoryPoint struct {
+//		Timestamp time.Time `json:"timestamp"`
+//		Delta     *int64    `json:"delta,omitempty"` // накопленное значение counter
+//		Value     *float64  `json:"value,omitempty"` // значение gauge (среднее в окне step для DATABASE_DSN)
+//		Min       *float64  `json:"min,omitempty"`   // минимум gauge в окне step (DATABASE_DSN)
+//		Max       *float64  `json:"max,omitempty"`   // максимум gauge в окне step (DATABASE_DSN)
+//
+//		Histogram *Histogram `json:"histogram,omitempty"` // накопленная гистограмма
+//		Summary   *Summary   `json:"summary,omitempty"`   // значение summary
+//	}
+func (s *StorageWrapper) GetMetricHistory(w http.ResponseWriter, r *http.Request) {
+	mType := chi.URLParam(r, "mType")
+
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	case common.RateMetricName:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, labels, err := seriesParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	from, err := parseHistoryTime(query.Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseHistoryTime(query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var step time.Duration
+	if stepStr := query.Get("step"); stepStr != "" {
+		step, err = time.ParseDuration(stepStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	points, err := s.stor.GetMetricHistory(r.Context(), mType, common.SeriesKey(id, labels), from, to, step)
+	if err != nil {
+		if errors.Is(err, storage.ErrHistoryNotSupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if points == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	resp := make([]HistoryPoint, len(points))
+	for i, p := range points {
+		resp[i].Timestamp = p.Timestamp
+
+		switch mType {
+		case common.GaugeMetricName:
+			resp[i].Value = &p.Value
+			resp[i].Min = p.Min
+			resp[i].Max = p.Max
+		case common.CounterMetricName:
+			resp[i].Delta = &p.Delta
+		case common.HistogramMetricName:
+			resp[i].Histogram = p.Histogram
+		case common.SummaryMetricName:
+			resp[i].Summary = p.Summary
+		}
+	}
+
+	jsonResp, _ := json.Marshal(resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
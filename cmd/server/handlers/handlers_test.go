@@ -1,16 +1,20 @@
 package handlers_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GermanVor/devops-pet-project/cmd/agent/metric"
 	"github.com/GermanVor/devops-pet-project/cmd/server/handlers"
@@ -39,6 +43,8 @@ func createTestEnvironment(key string) (*storage.Storage, string, func()) {
 
 	r.Get("/value/{mType}/{id}", s.GetMetricV1)
 
+	r.Get("/history/{mType}/{id}", s.GetMetricHistory)
+
 	r.Get("/", s.GetAllMetrics)
 
 	r.Post("/update/", s.UpdateMetric)
@@ -336,3 +342,797 @@ func TestServerOperationsV2(t *testing.T) {
 		counterTestFunc(t, "zxmxlcjsda")
 	})
 }
+
+func TestMetricHistory(t *testing.T) {
+	currentStorage, endpointURL, destructor := createTestEnvironment("")
+	defer destructor()
+
+	values := []float64{1, 2, 3}
+	for i := range values {
+		err := currentStorage.UpdateMetric(context.TODO(), common.Metric{
+			ID:    "qwerty",
+			MType: common.GaugeMetricName,
+			Value: &values[i],
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("Get history", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/history/gauge/qwerty")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		points := []handlers.HistoryPoint{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&points))
+		require.Len(t, points, len(values))
+
+		for i, p := range points {
+			require.NotNil(t, p.Value)
+			assert.Equal(t, values[i], *p.Value)
+		}
+	})
+
+	t.Run("Get history with step", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/history/gauge/qwerty?step=1h")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		points := []handlers.HistoryPoint{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&points))
+		require.Len(t, points, 1)
+		assert.Equal(t, values[len(values)-1], *points[0].Value)
+	})
+
+	t.Run("Missing metric", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/history/counter/qwerty")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Bad range", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/history/gauge/qwerty?from=qwe")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestMetricLabels(t *testing.T) {
+	key := "qwerty"
+	currentStorage, endpointURL, destructor := createTestEnvironment(key)
+	defer destructor()
+
+	hosts := []string{"host-1", "host-2"}
+
+	for i, host := range hosts {
+		value := float64(i)
+		metric := &common.Metric{
+			ID:     "HeapAlloc",
+			MType:  common.GaugeMetricName,
+			Value:  &value,
+			Labels: map[string]string{"host": host},
+		}
+		require.NoError(t, metric.SetHash(key))
+
+		jsonReq, err := metric.MarshalJSON()
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/update/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	t.Run("Series are stored separately", func(t *testing.T) {
+		for i, host := range hosts {
+			seriesKey := common.SeriesKey("HeapAlloc", map[string]string{"host": host})
+
+			storageMetric, err := currentStorage.GetMetric(context.TODO(), common.GaugeMetricName, seriesKey)
+			require.NoError(t, err)
+			require.NotNil(t, storageMetric)
+			assert.Equal(t, float64(i), storageMetric.Value)
+			assert.Equal(t, host, storageMetric.Labels["host"])
+		}
+	})
+
+	t.Run("Hash covers labels", func(t *testing.T) {
+		value := float64(24)
+		metric := &common.Metric{
+			ID:    "HeapAlloc",
+			MType: common.GaugeMetricName,
+			Value: &value,
+		}
+		require.NoError(t, metric.SetHash(key))
+		metric.Labels = map[string]string{"host": "host-1"}
+
+		jsonReq, err := metric.MarshalJSON()
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/update/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Get value by series key", func(t *testing.T) {
+		seriesKey := url.PathEscape(`HeapAlloc{host="host-2"}`)
+
+		resp, err := http.DefaultClient.Get(endpointURL + "/value/gauge/" + seriesKey)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "1", string(body))
+	})
+
+	t.Run("Get all metrics by label matchers", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/?match=" + url.QueryEscape(`{host=~".*-1"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, true, strings.Contains(string(body), "host-1"))
+		assert.Equal(t, false, strings.Contains(string(body), "host-2"))
+	})
+
+	t.Run("Bad label matchers", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/?match=" + url.QueryEscape(`{host~"a"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Bad label name", func(t *testing.T) {
+		value := float64(24)
+		metric := &common.Metric{
+			ID:     "HeapAlloc",
+			MType:  common.GaugeMetricName,
+			Value:  &value,
+			Labels: map[string]string{"host-name": "host-1"},
+		}
+		require.NoError(t, metric.SetHash(key))
+
+		jsonReq, err := metric.MarshalJSON()
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/update/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestHistogramMetric(t *testing.T) {
+	key := "qwerty"
+	_, endpointURL, destructor := createTestEnvironment(key)
+	defer destructor()
+
+	postMetric := func(t *testing.T, metric *common.Metric) int {
+		require.NoError(t, metric.SetHash(key))
+
+		jsonReq, err := metric.MarshalJSON()
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/update/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	for i := 0; i < 2; i++ {
+		statusCode := postMetric(t, &common.Metric{
+			ID:    "Latency",
+			MType: common.HistogramMetricName,
+			Histogram: &common.Histogram{
+				Buckets: []common.HistogramBucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}},
+				Sum:     1.5,
+				Count:   3,
+			},
+		})
+		assert.Equal(t, http.StatusOK, statusCode)
+	}
+
+	t.Run("Get merged histogram", func(t *testing.T) {
+		jsonReq, err := json.Marshal(common.Metric{ID: "Latency", MType: common.HistogramMetricName})
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/value/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		metric := &common.Metric{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(metric))
+		require.NotNil(t, metric.Histogram)
+
+		ok, err := metric.CheckHash(key)
+		require.NoError(t, err)
+		assert.Equal(t, true, ok)
+
+		assert.Equal(t, uint64(6), metric.Histogram.Count)
+		assert.Equal(t, 3.0, metric.Histogram.Sum)
+		assert.Equal(t, uint64(4), metric.Histogram.Buckets[1].Count)
+	})
+
+	t.Run("Bad histogram", func(t *testing.T) {
+		statusCode := postMetric(t, &common.Metric{
+			ID:    "Latency",
+			MType: common.HistogramMetricName,
+			Histogram: &common.Histogram{
+				Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 1}, {UpperBound: 0.5, Count: 2}},
+				Count:   2,
+			},
+		})
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+	})
+
+	t.Run("Bad summary", func(t *testing.T) {
+		statusCode := postMetric(t, &common.Metric{
+			ID:      "Latency",
+			MType:   common.SummaryMetricName,
+			Summary: &common.Summary{Quantiles: []common.SummaryQuantile{{Quantile: 2, Value: 1}}},
+		})
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+	})
+}
+
+func TestCounterRate(t *testing.T) {
+	tracker, err := storage.NewRateTracker(time.Minute, "")
+	require.NoError(t, err)
+
+	baseStorage, _ := storage.Init(nil)
+	s := handlers.InitStorageWrapper(storage.WithRates(baseStorage, tracker), "")
+
+	r := chi.NewRouter()
+	r.Post("/update/{mType}/{id}/{metricValue}", s.UpdateMetricV1)
+	r.Get("/value/{mType}/{id}", s.GetMetricV1)
+	r.Post("/value/", s.GetMetric)
+	r.Get("/", s.GetAllMetrics)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	req, err := buildRequest(ts.URL, common.CounterMetricName, "PollCount", "5")
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("Get rate", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(ts.URL + "/value/rate/PollCount")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "0", string(body))
+	})
+
+	t.Run("Get rate as JSON", func(t *testing.T) {
+		jsonReq, err := json.Marshal(common.Metric{ID: "PollCount", MType: common.RateMetricName})
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(ts.URL+"/value/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		metric := &common.Metric{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(metric))
+		assert.Equal(t, true, metric.Value != nil)
+	})
+
+	t.Run("Rate is listed", func(t *testing.T) {
+		resp, err := http.DefaultClient.Get(ts.URL + "/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(body), "PollCount"))
+	})
+
+	t.Run("Rate is read-only", func(t *testing.T) {
+		req, err := buildRequest(ts.URL, common.RateMetricName, "PollCount", "5")
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+}
+
+func TestCacheStats(t *testing.T) {
+	baseStorage, _ := storage.Init(nil)
+	cache := storage.WithCache(baseStorage, 10)
+
+	_, err := cache.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handlers.CacheStats(cache).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	stats := storage.CacheStats{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	assert.Equal(t, storage.CacheStats{Misses: 1, Capacity: 10}, stats)
+}
+
+func TestFaults(t *testing.T) {
+	baseStorage, _ := storage.Init(nil)
+	faults := storage.WithFaults(baseStorage)
+
+	r := chi.NewRouter()
+	r.Handle("/debug/faults", handlers.Faults(faults, "key"))
+	r.Post("/update/", handlers.InitStorageWrapper(faults, "").UpdateMetric)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	hash := common.FaultsHash("key")
+
+	doRequest := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(common.HashHeader, hash)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.StatusCode, string(respBody)
+	}
+
+	update := `{"id":"Alloc","type":"gauge","value":1}`
+
+	// faults are changed only with the hash of key
+	hash = common.FaultsHash("other-key")
+	status, _ := doRequest(http.MethodPut, "/debug/faults", `{"error_rate":1}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	hash = common.FaultsHash("key")
+
+	status, _ = doRequest(http.MethodPut, "/debug/faults", `{"error_rate":2}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = doRequest(http.MethodPut, "/debug/faults", `{"methods":["UpdateMetric"],"error_rate":1,"latency":"1ms"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(http.MethodPost, "/update/", update)
+	assert.Equal(t, http.StatusInternalServerError, status)
+
+	status, body := doRequest(http.MethodGet, "/debug/faults", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	state := storage.FaultState{}
+	require.NoError(t, json.Unmarshal([]byte(body), &state))
+	assert.Equal(t, []string{"UpdateMetric"}, state.Config.Methods)
+	assert.Equal(t, time.Millisecond, state.Config.Latency.Duration)
+	assert.Equal(t, storage.FaultStats{Calls: 1, Delayed: 1, Errors: 1}, state.Stats)
+
+	status, _ = doRequest(http.MethodDelete, "/debug/faults", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = doRequest(http.MethodPost, "/update/", update)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestSelfMetrics(t *testing.T) {
+	baseStorage, _ := storage.Init(nil)
+	metrics := storage.NewStorageMetrics(nil)
+	stor := storage.WithInstrumentation(baseStorage, metrics)
+
+	_, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handlers.SelfMetrics(metrics).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	selfMetrics := []common.Metric{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&selfMetrics))
+
+	calls := map[string]uint64{}
+	for _, metric := range selfMetrics {
+		if metric.ID == storage.StorageLatencyMetric {
+			calls[metric.Labels["method"]] = metric.Histogram.Count
+		}
+	}
+
+	assert.Equal(t, uint64(1), calls["GetMetric"])
+	assert.Equal(t, uint64(0), calls["UpdateMetrics"])
+}
+
+func TestTenants(t *testing.T) {
+	tenants, err := storage.ParseTenants([]byte(`[
+		{"name": "team-a", "key": "key-a", "max_batch_size": 2},
+		{"name": "team-b", "public": true}
+	]`))
+	require.NoError(t, err)
+
+	baseStorage, _ := storage.Init(nil)
+	s := handlers.InitStorageWrapper(storage.WithTenants(baseStorage, tenants), "")
+	s.SetTenants(tenants)
+
+	r := chi.NewRouter()
+	r.Use(handlers.MiddlewareTenant(tenants))
+	r.Get("/", s.GetAllMetrics)
+	r.Post("/updates/", s.UpdateMetrics)
+	r.Post("/update/{mType}/{id}/{metricValue}", s.UpdateMetricV1)
+	r.Post("/value/", s.GetMetric)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	post := func(path, tenant string, body interface{}) *http.Response {
+		jsonReq, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set(common.TenantHeader, tenant)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp
+	}
+	listMetrics := func(tenant string) string {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+		require.NoError(t, err)
+		req.Header.Set(common.TenantHeader, tenant)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return string(body)
+	}
+
+	value := float64(1)
+	signedMetric := common.Metric{ID: "Alloc", MType: common.GaugeMetricName, Value: &value}
+	require.NoError(t, signedMetric.SetHash("key-a"))
+
+	t.Run("Tenant by key", func(t *testing.T) {
+		resp := post("/updates/", "", []common.Metric{signedMetric})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, true, strings.Contains(listMetrics("team-a"), "Alloc"))
+		assert.Equal(t, false, strings.Contains(listMetrics("team-b"), "Alloc"))
+	})
+
+	t.Run("Tenant by header", func(t *testing.T) {
+		sys := common.Metric{ID: "Sys", MType: common.GaugeMetricName, Value: &value}
+
+		resp := post("/updates/", "team-b", []common.Metric{sys})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		assert.Equal(t, true, strings.Contains(listMetrics("team-b"), "Sys"))
+		assert.Equal(t, false, strings.Contains(listMetrics("team-a"), "Sys"))
+
+		resp = post("/value/", "team-b", common.Metric{ID: "Alloc", MType: common.GaugeMetricName})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Tenant key is checked", func(t *testing.T) {
+		resp := post("/updates/", "team-a", []common.Metric{{ID: "Sys", MType: common.GaugeMetricName, Value: &value}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("URL update of tenant with key is refused", func(t *testing.T) {
+		resp := post("/update/gauge/Alloc/2", "team-a", nil)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp = post("/update/gauge/Alloc/2", "team-b", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Tenant limit", func(t *testing.T) {
+		resp := post("/updates/", "", []common.Metric{signedMetric, signedMetric, signedMetric})
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("Unknown tenant", func(t *testing.T) {
+		resp := post("/value/", "team-c", common.Metric{ID: "Alloc", MType: common.GaugeMetricName})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestGetAllMetricsPages(t *testing.T) {
+	_, endpointURL, destructor := createTestEnvironment("")
+	defer destructor()
+
+	for _, id := range []string{"HeapAlloc", "HeapSys", "HeapIdle", "Alloc"} {
+		req, err := buildRequest(endpointURL, common.GaugeMetricName, id, "1")
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	getPage := func(query url.Values) (*http.Response, string) {
+		resp, err := http.DefaultClient.Get(endpointURL + "/?" + query.Encode())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp, string(body)
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		query := url.Values{"type": {common.GaugeMetricName}, "prefix": {"Heap"}, "limit": {"2"}}
+
+		resp, body := getPage(query)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "<div><ul><li>HeapAlloc - 1</li><li>HeapIdle - 1</li></ul>", body[:strings.Index(body, "<a")])
+
+		cursor := resp.Header.Get(common.NextCursorHeader)
+		require.NotEqual(t, "", cursor)
+
+		query.Set("cursor", cursor)
+		assert.Equal(t, true, strings.Contains(body, html.EscapeString(query.Encode())))
+
+		resp, body = getPage(query)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "", resp.Header.Get(common.NextCursorHeader))
+		assert.Equal(t, "<div><ul><li>HeapSys - 1</li></ul></div>", body)
+	})
+
+	t.Run("Bad query", func(t *testing.T) {
+		for _, query := range []url.Values{
+			{"limit": {"-1"}},
+			{"type": {"unknown"}},
+			{"regex": {"("}},
+			{"cursor": {"!"}},
+		} {
+			resp, _ := getPage(query)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
+
+func TestDeleteMetric(t *testing.T) {
+	currentStorage, _ := storage.Init(nil)
+	s := handlers.InitStorageWrapper(currentStorage, "key")
+
+	r := chi.NewRouter()
+	r.Delete("/value/{mType}/{id}", s.DeleteMetric)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	value := float64(1)
+	metric := common.Metric{ID: "HeapAlloc", MType: common.GaugeMetricName, Value: &value, Labels: map[string]string{"host": "a"}}
+	require.NoError(t, currentStorage.UpdateMetric(context.TODO(), metric))
+
+	deleteMetric := func(mType, seriesKey, hash string) int {
+		req, err := http.NewRequest(http.MethodDelete, ts.URL+"/value/"+mType+"/"+url.PathEscape(seriesKey), nil)
+		require.NoError(t, err)
+		req.Header.Set(common.HashHeader, hash)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	seriesKey := metric.Key()
+	hash := common.DeleteMetricHash(common.GaugeMetricName, seriesKey, "key")
+
+	assert.Equal(t, http.StatusBadRequest, deleteMetric(common.GaugeMetricName, seriesKey, "hash"))
+	assert.Equal(t, http.StatusBadRequest, deleteMetric("unknown", seriesKey, hash))
+	assert.Equal(t, http.StatusOK, deleteMetric(common.GaugeMetricName, seriesKey, hash))
+	assert.Equal(t, http.StatusNotFound, deleteMetric(common.GaugeMetricName, seriesKey, hash))
+
+	storageMetric, err := currentStorage.GetMetric(context.TODO(), common.GaugeMetricName, seriesKey)
+	require.NoError(t, err)
+	assert.Equal(t, (*storage.StorageMetric)(nil), storageMetric)
+}
+
+func TestConditionalGauge(t *testing.T) {
+	key := "qwerty"
+	_, endpointURL, destructor := createTestEnvironment(key)
+	defer destructor()
+
+	post := func(t *testing.T, endpoint string, body interface{}) int {
+		jsonReq, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+endpoint, "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	gauge := func(value float64, version uint64) common.Metric {
+		metric := common.Metric{
+			ID:           "Jobs",
+			MType:        common.GaugeMetricName,
+			Value:        &value,
+			Precondition: &common.Precondition{Version: &version},
+		}
+		require.NoError(t, metric.SetHash(key))
+
+		return metric
+	}
+
+	getVersion := func(t *testing.T) uint64 {
+		jsonReq, err := json.Marshal(common.Metric{ID: "Jobs", MType: common.GaugeMetricName})
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(endpointURL+"/value/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		metric := &common.Metric{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(metric))
+		require.NotNil(t, metric.Version)
+
+		return *metric.Version
+	}
+
+	assert.Equal(t, http.StatusOK, post(t, "/update/", gauge(1, 0)))
+	assert.Equal(t, http.StatusConflict, post(t, "/update/", gauge(2, 0)))
+	assert.Equal(t, uint64(1), getVersion(t))
+
+	assert.Equal(t, http.StatusConflict, post(t, "/updates/", []common.Metric{gauge(2, 1), gauge(3, 1)}))
+	assert.Equal(t, http.StatusOK, post(t, "/updates/", []common.Metric{gauge(2, 1), gauge(3, 2)}))
+	assert.Equal(t, uint64(3), getVersion(t))
+
+	t.Run("Precondition is signed", func(t *testing.T) {
+		metric := gauge(4, 3)
+		version := uint64(0)
+		metric.Precondition.Version = &version
+
+		assert.Equal(t, http.StatusBadRequest, post(t, "/update/", metric))
+	})
+
+	t.Run("Precondition of counter", func(t *testing.T) {
+		delta := int64(1)
+		version := uint64(0)
+		metric := common.Metric{
+			ID:           "Jobs",
+			MType:        common.CounterMetricName,
+			Delta:        &delta,
+			Precondition: &common.Precondition{Version: &version},
+		}
+		require.NoError(t, metric.SetHash(key))
+
+		assert.Equal(t, http.StatusBadRequest, post(t, "/update/", metric))
+	})
+}
+
+func TestWatchMetrics(t *testing.T) {
+	baseStorage, _ := storage.Init(nil)
+	hub := storage.NewWatchHub(16)
+	s := handlers.InitStorageWrapper(storage.WithWatch(baseStorage, hub), "")
+	s.SetWatchHub(hub)
+
+	r := chi.NewRouter()
+	r.Get("/watch", s.WatchMetrics)
+	r.Post("/update/", s.UpdateMetric)
+	r.Delete("/value/{mType}/{id}", s.DeleteMetric)
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	update := func(t *testing.T, id string, value float64) {
+		jsonReq, err := json.Marshal(common.Metric{ID: id, MType: common.GaugeMetricName, Value: &value})
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Post(ts.URL+"/update/", "application/json", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	resp, err := http.DefaultClient.Get(ts.URL + "/watch?type=counter&regex=(")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	update(t, "Alloc", 1)
+	update(t, "Sys", 1)
+
+	resp, err = http.DefaultClient.Get(ts.URL + "/watch?prefix=Alloc&initial=true")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	nextEvent := func(t *testing.T) (string, string) {
+		event, data := "", ""
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && event != "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	event, data := nextEvent(t)
+	assert.Equal(t, "updated", event)
+	assert.Equal(t, `{"id":"Alloc","type":"gauge","value":1}`, data)
+
+	update(t, "Sys", 2)
+	update(t, "Alloc", 2)
+
+	event, data = nextEvent(t)
+	assert.Equal(t, "updated", event)
+	assert.Equal(t, `{"id":"Alloc","type":"gauge","value":2,"version":2}`, data)
+
+	req, err := http.NewRequest(http.MethodDelete, ts.URL+"/value/gauge/Alloc", nil)
+	require.NoError(t, err)
+	deleteResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	deleteResp.Body.Close()
+
+	event, data = nextEvent(t)
+	assert.Equal(t, "deleted", event)
+	assert.Equal(t, `{"id":"Alloc","type":"gauge"}`, data)
+
+	hub.Close()
+
+	event, data = nextEvent(t)
+	assert.Equal(t, "error", event)
+	assert.Equal(t, storage.ErrWatchClosed.Error(), data)
+
+	_, err = reader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,11 +16,26 @@ import (
 // UpdateMetricV1 [Depricatred] Handler to save Agent metrics by URL.
 //
 // URL view: /update/{mType}/{id}/{metricValue} where
-// mType - (gauge|counter), id - Metric Id, metricValue - (float64|int64)
+// mType - (gauge|counter), id - Metric Id or series key with labels (HeapAlloc{host="a"}),
+// metricValue - (float64|int64)
+//
+// URL can not be signed, so update of tenant with key is rejected with 403.
 func (s *StorageWrapper) UpdateMetricV1(w http.ResponseWriter, r *http.Request) {
+	if storage.TenantFromContext(r.Context()) != "" && s.hashKey(r.Context()) != "" {
+		http.Error(w, "tenant with key accepts signed updates only", http.StatusForbidden)
+		return
+	}
+
+	id, labels, err := seriesParam(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	metric := common.Metric{
-		MType: chi.URLParam(r, "mType"),
-		ID:    chi.URLParam(r, "id"),
+		MType:  chi.URLParam(r, "mType"),
+		ID:     id,
+		Labels: labels,
 	}
 
 	switch metric.MType {
@@ -41,35 +58,41 @@ func (s *StorageWrapper) UpdateMetricV1(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := s.stor.UpdateMetric(r.Context(), metric)
+	err = s.stor.UpdateMetric(r.Context(), metric)
 
 	if err == nil {
 		w.Header().Add("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 	} else {
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(updateErrorStatus(err, http.StatusBadRequest))
 	}
 }
 
 // GetMetricV1 [Depricatred] Handler to get Agent metrics by URL.
 //
 // URL view: /value/{mType}/{id} where
-// mType - (gauge|counter), id - Metric Id.
+// mType - (gauge|counter|rate), id - Metric Id or series key with labels (HeapAlloc{host="a"}).
 //
 // Response is Metric Value as String.
 func (s *StorageWrapper) GetMetricV1(w http.ResponseWriter, r *http.Request) {
 	mType := chi.URLParam(r, "mType")
-	id := chi.URLParam(r, "id")
 
 	switch mType {
 	case common.GaugeMetricName:
 	case common.CounterMetricName:
+	case common.RateMetricName:
 	default:
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	metric, err := s.stor.GetMetric(r.Context(), mType, id)
+	id, labels, err := seriesParam(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	metric, err := s.stor.GetMetric(r.Context(), mType, common.SeriesKey(id, labels))
 
 	if err == nil {
 		if metric != nil {
@@ -77,7 +100,7 @@ func (s *StorageWrapper) GetMetricV1(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
 
 			switch mType {
-			case common.GaugeMetricName:
+			case common.GaugeMetricName, common.RateMetricName:
 				w.Write([]byte(fmt.Sprint(metric.Value)))
 			case common.CounterMetricName:
 				w.Write([]byte(fmt.Sprint(metric.Delta)))
@@ -95,30 +118,76 @@ func (s *StorageWrapper) GetMetricV1(w http.ResponseWriter, r *http.Request) {
 //
 //	<div>
 //		<ul>
-//			<li>${metricId} - ${metricValue}</li>
+//			<li>${seriesKey} - ${metricValue}</li>
 //			...
 //		</ul>
+//		<a href="?...&cursor=${nextCursor}">next</a>
 //	</div>
+//
+// Optional query params select metrics:
+// type - metric type, prefix - ID prefix, regex - regexp of the whole ID,
+// match - labels, e.g. ?match={host="a",region=~"eu-.*"},
+// limit - page size (all metrics by default), cursor - X-Next-Cursor header of the previous page.
+//
+// Metrics are ordered by type and series key, the last page has no X-Next-Cursor header and next link.
 func (s *StorageWrapper) GetAllMetrics(w http.ResponseWriter, r *http.Request) {
-	list := make([]string, 0)
+	query := r.URL.Query()
+
+	filter, err := storage.NewMetricsFilter(query.Get("type"), query.Get("prefix"), query.Get("regex"), query.Get("match"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	err := s.stor.ForEachMetrics(r.Context(), func(sm *storage.StorageMetric) {
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 0 {
+			http.Error(w, "limit should be non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := s.stor.ListMetrics(r.Context(), storage.ListQuery{
+		Filter: *filter,
+		Limit:  limit,
+		Cursor: query.Get("cursor"),
+	})
+	if errors.Is(err, storage.ErrInvalidListQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]string, 0, len(page.Metrics))
+
+	for _, sm := range page.Metrics {
 		item := ""
 
 		switch sm.MType {
-		case common.GaugeMetricName:
+		case common.GaugeMetricName, common.RateMetricName:
 			item = fmt.Sprint(sm.Value)
 		case common.CounterMetricName:
 			item = fmt.Sprint(sm.Delta)
+		case common.HistogramMetricName:
+			item = sm.Histogram.String()
+		case common.SummaryMetricName:
+			item = sm.Summary.String()
 		}
 
-		list = append(list, fmt.Sprintf("<li>%s - %s</li>", sm.ID, item))
-	})
+		list = append(list, fmt.Sprintf("<li>%s - %s</li>", html.EscapeString(sm.Key()), item))
+	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	} else {
-		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprintf(w, "<div><ul>%s</ul></div>", strings.Join(list, ""))
+	next := ""
+	if page.NextCursor != "" {
+		w.Header().Set(common.NextCursorHeader, page.NextCursor)
+
+		query.Set("cursor", page.NextCursor)
+		next = fmt.Sprintf(`<a href="?%s">next</a>`, html.EscapeString(query.Encode()))
 	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<div><ul>%s</ul>%s</div>", strings.Join(list, ""), next)
 }
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+)
+
+// watchKeepAlive is the time between comments which keep idle event stream open through proxies
+const watchKeepAlive = 15 * time.Second
+
+// SetWatchHub enables WatchMetrics with subscriptions to hub.
+func (s *StorageWrapper) SetWatchHub(hub *storage.WatchHub) {
+	s.watch = hub
+}
+
+// watchMetric converts metric of the event to Metric sent to the watcher, deleted Metric has no value.
+func watchMetric(event storage.WatchEvent) *common.Metric {
+	sm := event.Metric
+	metric := &common.Metric{
+		ID:     sm.ID,
+		MType:  sm.MType,
+		Labels: sm.Labels,
+	}
+
+	if event.Type == storage.WatchDeleted {
+		return metric
+	}
+
+	switch sm.MType {
+	case common.GaugeMetricName:
+		metric.Value = &sm.Value
+		if sm.Version != 0 {
+			metric.Version = &sm.Version
+		}
+	case common.RateMetricName:
+		metric.Value = &sm.Value
+	case common.CounterMetricName:
+		metric.Delta = &sm.Delta
+	case common.HistogramMetricName:
+		metric.Histogram = sm.Histogram
+	case common.SummaryMetricName:
+		metric.Summary = sm.Summary
+	}
+
+	return metric
+}
+
+// writeWatchEvent writes Server-Sent Event with Metric of the event as JSON data.
+func writeWatchEvent(w http.ResponseWriter, event storage.WatchEvent) error {
+	data, err := watchMetric(event).MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+
+	return err
+}
+
+// WatchMetrics Handler to stream changes of metrics as Server-Sent Events (text/event-stream).
+//
+// Optional query params select metrics like GET / does: type, prefix, regex, match.
+// initial=true - the current metrics are sent as updated events before the changes.
+//
+// Every event is
+//
+//	event: (updated|deleted)
+//	data: ${Metric}
+//
+// where data is JSON of Metric, updated Metric has the stored value (gauge with version),
+// deleted Metric has id, type and labels only. Subscriber which can not keep up with updates
+// gets `event: error` and the stream ends, it should reconnect with initial=true to catch up.
+func (s *StorageWrapper) WatchMetrics(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || s.watch == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter, err := storage.NewMetricsFilter(query.Get("type"), query.Get("prefix"), query.Get("regex"), query.Get("match"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	initial := false
+	if initialStr := query.Get("initial"); initialStr != "" {
+		if initial, err = strconv.ParseBool(initialStr); err != nil {
+			http.Error(w, "initial should be bool", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// subscription is made before the initial metrics are read, so no update is missed
+	sub, err := s.watch.Subscribe(r.Context(), *filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	var initialMetrics []*storage.StorageMetric
+	if initial {
+		page, err := s.stor.ListMetrics(r.Context(), storage.ListQuery{Filter: *filter})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		initialMetrics = page.Metrics
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	now := time.Now()
+	for _, sm := range initialMetrics {
+		if err := writeWatchEvent(w, storage.WatchEvent{Type: storage.WatchUpdated, Metric: sm, Timestamp: now}); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", sub.Err())
+				flusher.Flush()
+
+				return
+			}
+
+			if err := writeWatchEvent(w, event); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
@@ -1,17 +1,91 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/storage"
+	"github.com/go-chi/chi"
 )
 
 type StorageWrapper struct {
 	stor storage.StorageInterface
 	key  string
+	// tenants is nil if the server has the default tenant only
+	tenants *storage.Tenants
+	// watch is nil if WatchMetrics is not served
+	watch *storage.WatchHub
 }
 
 func InitStorageWrapper(stor storage.StorageInterface, key string) *StorageWrapper {
 	return &StorageWrapper{
-		stor,
-		key,
+		stor: stor,
+		key:  key,
+	}
+}
+
+// SetTenants makes handlers verify hashes with KEY of the request tenant
+// and scope requests without tenant to the tenant which key signs the metrics.
+func (s *StorageWrapper) SetTenants(tenants *storage.Tenants) {
+	s.tenants = tenants
+}
+
+// hashKey returns KEY of the request tenant.
+func (s *StorageWrapper) hashKey(ctx context.Context) string {
+	if name := storage.TenantFromContext(ctx); name != "" && s.tenants != nil {
+		if tenant := s.tenants.Get(name); tenant != nil {
+			return tenant.Key
+		}
+	}
+
+	return s.key
+}
+
+// withHashTenant scopes request without tenant to the tenant which key signs the metric.
+func (s *StorageWrapper) withHashTenant(r *http.Request, metric *common.Metric) *http.Request {
+	if s.tenants == nil || storage.TenantFromContext(r.Context()) != "" {
+		return r
+	}
+
+	if tenant := s.tenants.ByHash(metric); tenant != nil {
+		return r.WithContext(storage.ContextWithTenant(r.Context(), tenant.Name))
+	}
+
+	return r
+}
+
+// seriesParam parses {id} URL param as series key: `HeapAlloc` or `HeapAlloc{host="a"}` (URL encoded).
+func seriesParam(r *http.Request) (string, map[string]string, error) {
+	key := chi.URLParam(r, "id")
+
+	// chi matches escaped path if it differs from the default encoding
+	if r.URL.RawPath != "" {
+		var err error
+		if key, err = url.PathUnescape(key); err != nil {
+			return "", nil, err
+		}
 	}
+
+	return common.ParseSeriesKey(key)
+}
+
+// updateErrorStatus returns http status of the storage update error,
+// defaultStatus is used for errors of the request or the storage.
+func updateErrorStatus(err error, defaultStatus int) int {
+	if errors.Is(err, storage.ErrReadOnlyReplica) {
+		return http.StatusServiceUnavailable
+	}
+
+	if errors.Is(err, storage.ErrTenantLimit) {
+		return http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, storage.ErrPreconditionFailed) {
+		return http.StatusConflict
+	}
+
+	return defaultStatus
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/GermanVor/devops-pet-project/cmd/server/service"
 	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
 
 	_ "net/http/pprof"
 )
@@ -30,6 +31,18 @@ var Config = &common.ServerConfig{
 	StoreInterval: common.Duration{Duration: 300 * time.Second},
 	StoreFile:     "/tmp/devops-metrics-db.json",
 	IsRestore:     true,
+
+	StoreBackend:       common.MemoryStoreBackend,
+	StoreShards:        storage.DefaultShardsCount,
+	StoreRetention:     3,
+	WALCompactInterval: common.Duration{Duration: 300 * time.Second},
+	HistorySize:        storage.DefaultHistorySize,
+	HistoryRollups:     storage.DefaultHistoryRollups,
+	RollupInterval:     common.Duration{Duration: time.Minute},
+	TTLCheckInterval:   common.Duration{Duration: time.Minute},
+	RateWindow:         common.Duration{Duration: storage.DefaultRateWindow},
+	ReplicationLogSize: storage.DefaultReplicationLogSize,
+	WatchBufferSize:    storage.DefaultWatchBufferSize,
 }
 
 func initConfig() {
@@ -42,6 +55,28 @@ func initConfig() {
 
 func main() {
 	initConfig()
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Fatalln(err.Error())
+		}
+		return
+	}
+
+	if flag.Arg(0) == "dump" {
+		if err := runDump(flag.Args()[1:]); err != nil {
+			log.Fatalln(err.Error())
+		}
+		return
+	}
+
+	if flag.Arg(0) == "restore" {
+		if err := runRestore(flag.Args()[1:]); err != nil {
+			log.Fatalln(err.Error())
+		}
+		return
+	}
+
 	log.Println("Config is", Config)
 
 	s, err := service.InitService(Config, context.Background(), common.HTTP)
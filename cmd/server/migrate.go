@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+)
+
+const migrateUsage = "usage: server [flags] migrate up|down [steps]|status"
+
+var ErrMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate executes `migrate` command against DATABASE_DSN (-d flag).
+//
+//	server -d postgres://... migrate up        apply all pending migrations
+//	server -d postgres://... migrate down [N]  revert last N migrations (1 by default)
+//	server -d postgres://... migrate status    print applied and pending migrations
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return ErrMigrateUsage
+	}
+
+	if Config.DataBaseDSN == "" {
+		return errors.New("database dsn is required to run migrations")
+	}
+
+	ctx := context.Background()
+
+	migrator, closeDB, err := storage.InitMigrator(ctx, Config.DataBaseDSN)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return ErrMigrateUsage
+			}
+		}
+
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return nil
+	default:
+		return ErrMigrateUsage
+	}
+}
@@ -31,6 +31,8 @@ type HTTPServer struct {
 	ctx         context.Context
 	r           *chi.Mux
 	storWrapper *handlers.StorageWrapper
+	// watch subscriptions are ended on shutdown, otherwise open event streams hold it
+	watch *storage.WatchHub
 }
 
 func (s *HTTPServer) Start() error {
@@ -43,6 +45,10 @@ func (s *HTTPServer) Start() error {
 		},
 	}
 
+	if s.watch != nil {
+		server.RegisterOnShutdown(s.watch.Close)
+	}
+
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
@@ -74,12 +80,16 @@ func InitHTTPServer(
 	config *common.ServerConfig,
 	ctx context.Context,
 	stor storage.StorageInterface,
+	debugHandlers map[string]http.Handler,
+	tenants *storage.Tenants,
+	watch *storage.WatchHub,
 ) *HTTPServer {
 	s := &HTTPServer{
 		address:     config.Address,
 		ctx:         ctx,
 		r:           chi.NewRouter(),
 		storWrapper: handlers.InitStorageWrapper(stor, config.Key),
+		watch:       watch,
 	}
 
 	s.r.Use(middleware.Logger)
@@ -103,6 +113,11 @@ func InitHTTPServer(
 
 	s.r.Use(handlers.MiddlewareDecompressGzip)
 
+	if tenants != nil {
+		s.storWrapper.SetTenants(tenants)
+		s.r.Use(handlers.MiddlewareTenant(tenants))
+	}
+
 	if config.DataBaseDSN != "" {
 		s.r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 			if stor.Ping(r.Context()) == nil {
@@ -126,13 +141,26 @@ func InitHTTPServer(
 
 	s.r.Get("/value/{mType}/{id}", s.storWrapper.GetMetricV1)
 
+	s.r.Delete("/value/{mType}/{id}", s.storWrapper.DeleteMetric)
+
+	s.r.Get("/history/{mType}/{id}", s.storWrapper.GetMetricHistory)
+
 	s.r.Get("/", s.storWrapper.GetAllMetrics)
 
+	if watch != nil {
+		s.storWrapper.SetWatchHub(watch)
+		s.r.Get("/watch", s.storWrapper.WatchMetrics)
+	}
+
 	s.r.Post("/update/", s.storWrapper.UpdateMetric)
 
 	s.r.Post("/updates/", s.storWrapper.UpdateMetrics)
 
 	s.r.Post("/value/", s.storWrapper.GetMetric)
 
+	for path, handler := range debugHandlers {
+		s.r.Handle(path, handler)
+	}
+
 	return s
 }
@@ -7,18 +7,29 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/GermanVor/devops-pet-project/cmd/server/handlers"
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/storage"
 	pb "github.com/GermanVor/devops-pet-project/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type RPCImpl struct {
 	pb.UnimplementedMetricsServer
 	stor storage.StorageInterface
+	// replication is nil if the server does not log updates for replicas
+	replication *Replication
+	// tenants is nil if the server has the default tenant only
+	tenants *storage.Tenants
+	// key signs DeleteMetrics requests of the default tenant
+	key string
+	// watch is nil if WatchMetrics is not served
+	watch *storage.WatchHub
 }
 
 func InitRPCImpl(stor storage.StorageInterface) *RPCImpl {
@@ -27,12 +38,95 @@ func InitRPCImpl(stor storage.StorageInterface) *RPCImpl {
 	}
 }
 
+// SetTenants makes updates verify hashes with key of the request tenant
+// and scopes updates without tenant to the tenant which key signs the metrics.
+func (s *RPCImpl) SetTenants(tenants *storage.Tenants) {
+	s.tenants = tenants
+}
+
+// SetKey makes DeleteMetrics verify hash of the request with key.
+func (s *RPCImpl) SetKey(key string) {
+	s.key = key
+}
+
+// SetWatchHub enables WatchMetrics with subscriptions to hub.
+func (s *RPCImpl) SetWatchHub(hub *storage.WatchHub) {
+	s.watch = hub
+}
+
+// hashKey returns key of the request tenant.
+func (s *RPCImpl) hashKey(ctx context.Context) string {
+	if name := storage.TenantFromContext(ctx); name != "" && s.tenants != nil {
+		if tenant := s.tenants.Get(name); tenant != nil {
+			return tenant.Key
+		}
+	}
+
+	return s.key
+}
+
+var ErrTenantHash = errors.New("hash does not match key of tenant")
+
+// tenantContext returns ctx scoped to the tenant of metrics and checks their hashes by key of the tenant.
+func (s *RPCImpl) tenantContext(ctx context.Context, metricsList []common.Metric) (context.Context, error) {
+	if s.tenants == nil || len(metricsList) == 0 {
+		return ctx, nil
+	}
+
+	name := storage.TenantFromContext(ctx)
+	if name == "" {
+		tenant := s.tenants.ByHash(&metricsList[0])
+		if tenant == nil {
+			return ctx, nil
+		}
+
+		name = tenant.Name
+		ctx = storage.ContextWithTenant(ctx, name)
+	}
+
+	if tenant := s.tenants.Get(name); tenant != nil && tenant.Key != "" {
+		for _, metric := range metricsList {
+			if ok, _ := metric.CheckHash(tenant.Key); !ok {
+				return ctx, fmt.Errorf("%w %q", ErrTenantHash, name)
+			}
+		}
+	}
+
+	return ctx, nil
+}
+
+// SetReplication enables replication methods.
+func (s *RPCImpl) SetReplication(replication *Replication) {
+	s.replication = replication
+}
+
 type RPCServer struct {
 	address string
 	server  *grpc.Server
 	impl    *RPCImpl
 }
 
+// updateErrorCode returns http status of the storage update error.
+func updateErrorCode(err error) int32 {
+	if common.IsValidationError(err) || errors.Is(err, storage.ErrUnknowMetricType) || errors.Is(err, ErrTenantHash) {
+		return http.StatusBadRequest
+	}
+
+	if errors.Is(err, storage.ErrReadOnlyReplica) {
+		return http.StatusServiceUnavailable
+	}
+
+	if errors.Is(err, storage.ErrTenantLimit) {
+		return http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, storage.ErrPreconditionFailed) {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
 func (s *RPCImpl) AddMetric(ctx context.Context, in *pb.AddMetricRequest) (*pb.AddMetricResponse, error) {
 	resp := &pb.AddMetricResponse{}
 
@@ -45,11 +139,16 @@ func (s *RPCImpl) AddMetric(ctx context.Context, in *pb.AddMetricRequest) (*pb.A
 		return resp, nil
 	}
 
-	err := s.stor.UpdateMetric(ctx, *in.Metric.GetRequestMetric())
+	metric := *in.Metric.GetRequestMetric()
+
+	ctx, err := s.tenantContext(ctx, []common.Metric{metric})
+	if err == nil {
+		err = s.stor.UpdateMetric(ctx, metric)
+	}
 
 	if err != nil {
 		resp.Error = &pb.Error{
-			Code:    http.StatusInternalServerError,
+			Code:    updateErrorCode(err),
 			Message: err.Error(),
 		}
 	}
@@ -63,6 +162,9 @@ func (s *RPCImpl) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*pb.G
 	switch in.Type {
 	case common.GaugeMetricName:
 	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	case common.RateMetricName:
 	default:
 		resp.Error = &pb.Error{
 			Code:    http.StatusNotFound,
@@ -72,7 +174,7 @@ func (s *RPCImpl) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*pb.G
 		return resp, nil
 	}
 
-	storageMetric, err := s.stor.GetMetric(ctx, in.Type, in.Id)
+	storageMetric, err := s.stor.GetMetric(ctx, in.Type, common.SeriesKey(in.Id, in.Labels))
 
 	if err != nil {
 		resp.Error = &pb.Error{
@@ -105,11 +207,14 @@ func (s *RPCImpl) AddMetrics(ctx context.Context, in *pb.AddMetricsRequest) (*pb
 		metricsList = append(metricsList, *m.GetRequestMetric())
 	}
 
-	err := s.stor.UpdateMetrics(ctx, metricsList)
+	ctx, err := s.tenantContext(ctx, metricsList)
+	if err == nil {
+		err = s.stor.UpdateMetrics(ctx, metricsList)
+	}
 
 	if err != nil {
 		resp.Error = &pb.Error{
-			Code:    http.StatusInternalServerError,
+			Code:    updateErrorCode(err),
 			Message: err.Error(),
 		}
 	}
@@ -122,19 +227,231 @@ func (s *RPCImpl) GetMetrics(ctx context.Context, in *pb.GetMetricsRequest) (*pb
 		Metrics: make([]*pb.Metric, 0),
 	}
 
-	err := s.stor.ForEachMetrics(ctx, func(sm *storage.StorageMetric) {
-		resp.Metrics = append(resp.Metrics, pb.GetProtoStorageMetric(sm))
-	})
+	filter, err := storage.NewMetricsFilter(in.Type, in.IdPrefix, in.IdRegex, in.Match)
+	if err != nil {
+		resp.Error = &pb.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+
+		return resp, nil
+	}
 
+	page, err := s.stor.ListMetrics(ctx, storage.ListQuery{
+		Filter: *filter,
+		Limit:  int(in.Limit),
+		Cursor: in.Cursor,
+	})
 	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrInvalidListQuery) {
+			code = http.StatusBadRequest
+		}
+
 		return &pb.GetMetricsResponse{
 			Error: &pb.Error{
-				Code:    http.StatusInternalServerError,
+				Code:    int32(code),
 				Message: err.Error(),
 			},
 		}, nil
 	}
 
+	for _, sm := range page.Metrics {
+		resp.Metrics = append(resp.Metrics, pb.GetProtoStorageMetric(sm))
+	}
+	resp.NextCursor = page.NextCursor
+
+	return resp, nil
+}
+
+func (s *RPCImpl) DeleteMetrics(ctx context.Context, in *pb.DeleteMetricsRequest) (*pb.DeleteMetricsResponse, error) {
+	resp := &pb.DeleteMetricsResponse{
+		Deleted: make([]*pb.Series, 0),
+	}
+
+	if in.Type == "" && in.IdPrefix == "" && in.IdRegex == "" && in.Match == "" {
+		resp.Error = &pb.Error{
+			Code:    http.StatusBadRequest,
+			Message: "empty filter",
+		}
+
+		return resp, nil
+	}
+
+	if key := s.hashKey(ctx); key != "" && in.Hash != common.DeleteMetricsHash(in.Type, in.IdPrefix, in.IdRegex, in.Match, key) {
+		resp.Error = &pb.Error{
+			Code:    http.StatusBadRequest,
+			Message: "hash does not match key",
+		}
+
+		return resp, nil
+	}
+
+	filter, err := storage.NewMetricsFilter(in.Type, in.IdPrefix, in.IdRegex, in.Match)
+	if err != nil {
+		resp.Error = &pb.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+
+		return resp, nil
+	}
+
+	deleted, err := s.stor.DeleteMetrics(ctx, *filter)
+	for _, sm := range deleted {
+		resp.Deleted = append(resp.Deleted, pb.GetProtoSeries(sm))
+	}
+
+	if err != nil {
+		resp.Error = &pb.Error{
+			Code:    updateErrorCode(err),
+			Message: err.Error(),
+		}
+	}
+
+	return resp, nil
+}
+
+// WatchMetrics subscribes to changes of metrics selected by the filter of GetMetrics and streams them
+// until the client cancels the call. Subscription which can not keep up with updates is ended
+// by response with 503 error, the client should resubscribe (with initial metrics to catch up).
+func (s *RPCImpl) WatchMetrics(in *pb.WatchMetricsRequest, stream pb.Metrics_WatchMetricsServer) error {
+	ctx := stream.Context()
+
+	if s.watch == nil {
+		return stream.Send(&pb.WatchMetricsResponse{
+			Error: &pb.Error{
+				Code:    http.StatusNotImplemented,
+				Message: "watch is not served",
+			},
+		})
+	}
+
+	filter, err := storage.NewMetricsFilter(in.Type, in.IdPrefix, in.IdRegex, in.Match)
+	if err != nil {
+		return stream.Send(&pb.WatchMetricsResponse{
+			Error: &pb.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			},
+		})
+	}
+
+	// subscription is made before the initial metrics are read, so no update is missed
+	sub, err := s.watch.Subscribe(ctx, *filter)
+	if err != nil {
+		return stream.Send(&pb.WatchMetricsResponse{
+			Error: &pb.Error{
+				Code:    http.StatusServiceUnavailable,
+				Message: err.Error(),
+			},
+		})
+	}
+	defer sub.Close()
+
+	if in.Initial {
+		page, err := s.stor.ListMetrics(ctx, storage.ListQuery{Filter: *filter})
+		if err != nil {
+			return stream.Send(&pb.WatchMetricsResponse{
+				Error: &pb.Error{
+					Code:    http.StatusInternalServerError,
+					Message: err.Error(),
+				},
+			})
+		}
+
+		now := time.Now()
+		for _, sm := range page.Metrics {
+			if err := stream.Send(pb.GetProtoWatchEvent(storage.WatchEvent{
+				Type:      storage.WatchUpdated,
+				Metric:    sm,
+				Timestamp: now,
+			})); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-sub.Events():
+			if !ok {
+				return stream.Send(&pb.WatchMetricsResponse{
+					Error: &pb.Error{
+						Code:    http.StatusServiceUnavailable,
+						Message: sub.Err().Error(),
+					},
+				})
+			}
+
+			if err := stream.Send(pb.GetProtoWatchEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *RPCImpl) GetMetricHistory(
+	ctx context.Context,
+	in *pb.GetMetricHistoryRequest,
+) (*pb.GetMetricHistoryResponse, error) {
+	resp := &pb.GetMetricHistoryResponse{
+		Points: make([]*pb.MetricPoint, 0),
+	}
+
+	switch in.Type {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	case common.RateMetricName:
+	default:
+		resp.Error = &pb.Error{
+			Code:    http.StatusNotFound,
+			Message: "unknown metric type",
+		}
+
+		return resp, nil
+	}
+
+	var from, to time.Time
+	if in.From != nil {
+		from = in.From.AsTime()
+	}
+	if in.To != nil {
+		to = in.To.AsTime()
+	}
+
+	points, err := s.stor.GetMetricHistory(ctx, in.Type, common.SeriesKey(in.Id, in.Labels), from, to, in.Step.AsDuration())
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrHistoryNotSupported) {
+			code = http.StatusNotImplemented
+		}
+
+		resp.Error = &pb.Error{
+			Code:    int32(code),
+			Message: err.Error(),
+		}
+
+		return resp, nil
+	}
+
+	if points == nil {
+		resp.Error = &pb.Error{
+			Code:    http.StatusNotFound,
+			Message: "metric not found",
+		}
+
+		return resp, nil
+	}
+
+	for _, p := range points {
+		resp.Points = append(resp.Points, pb.GetProtoHistoryPoint(in.Type, p))
+	}
+
 	return resp, nil
 }
 
@@ -144,6 +461,73 @@ func (s *RPCImpl) Ping(ctx context.Context, in *pb.PingRequest) (*pb.PingRespons
 	return &pb.PingResponse{Status: err == nil}, nil
 }
 
+// metadataHash returns HashHeader of request metadata.
+func metadataHash(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if hashes := md.Get(common.HashHeader); len(hashes) != 0 {
+		return hashes[0]
+	}
+
+	return ""
+}
+
+// Replicate streams the log to replica, HashHeader metadata should be common.ReplicationHash(common.ReplicateAction, key).
+func (s *RPCImpl) Replicate(stream pb.Metrics_ReplicateServer) error {
+	if s.replication == nil {
+		return status.Error(codes.Unimplemented, "replication is disabled")
+	}
+
+	if !s.replication.Authorized(common.ReplicateAction, metadataHash(stream.Context())) {
+		return status.Error(codes.PermissionDenied, "hash does not match key")
+	}
+
+	return s.replication.Serve(stream)
+}
+
+func (s *RPCImpl) GetReplicationStatus(
+	ctx context.Context,
+	in *pb.ReplicationStatusRequest,
+) (*pb.ReplicationStatusResponse, error) {
+	if s.replication == nil {
+		return &pb.ReplicationStatusResponse{
+			Error: &pb.Error{
+				Code:    http.StatusNotImplemented,
+				Message: "replication is disabled",
+			},
+		}, nil
+	}
+
+	return GetProtoReplicationStatus(s.replication.Status(time.Now())), nil
+}
+
+// Promote makes replica primary, HashHeader metadata should be common.ReplicationHash(common.PromoteAction, key).
+func (s *RPCImpl) Promote(ctx context.Context, in *pb.PromoteRequest) (*pb.PromoteResponse, error) {
+	resp := &pb.PromoteResponse{}
+
+	if s.replication == nil {
+		resp.Error = &pb.Error{
+			Code:    http.StatusNotImplemented,
+			Message: "replication is disabled",
+		}
+
+		return resp, nil
+	}
+
+	if !s.replication.Authorized(common.PromoteAction, metadataHash(ctx)) {
+		resp.Error = &pb.Error{
+			Code:    http.StatusForbidden,
+			Message: "hash does not match key",
+		}
+
+		return resp, nil
+	}
+
+	s.replication.Promote()
+
+	return resp, nil
+}
+
 func (s *RPCServer) Start() error {
 	listen, err := net.Listen("tcp", s.address)
 	if err != nil {
@@ -161,6 +545,31 @@ const TrustedSubnetHeader = "X-Real-IP"
 
 var ErrTrust = errors.New("")
 
+// trustedContext checks that TrustedSubnetHeader of ctx metadata is within ipnet.
+func trustedContext(ctx context.Context, ipnet *net.IPNet) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ErrTrust
+	}
+
+	subnets := md.Get(TrustedSubnetHeader)
+	if len(subnets) == 0 {
+		return ErrTrust
+	}
+
+	netIP := net.ParseIP(subnets[0])
+
+	if netIP == nil {
+		return ErrTrust
+	}
+
+	if !ipnet.Contains(netIP) {
+		return ErrTrust
+	}
+
+	return nil
+}
+
 func TrustedSubnetServerInterceptor(trustedSubnet string) grpc.UnaryServerInterceptor {
 	_, ipnetA, _ := net.ParseCIDR(trustedSubnet)
 
@@ -170,32 +579,102 @@ func TrustedSubnetServerInterceptor(trustedSubnet string) grpc.UnaryServerInterc
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (resp interface{}, err error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, ErrTrust
+		if err := trustedContext(ctx, ipnetA); err != nil {
+			return nil, err
 		}
 
-		subnets := md.Get(TrustedSubnetHeader)
-		if len(subnets) == 0 {
-			return nil, ErrTrust
-		}
+		return handler(ctx, req)
+	}
+}
 
-		netIP := net.ParseIP(subnets[0])
+// TrustedSubnetStreamServerInterceptor checks streams (Replicate, WatchMetrics) like TrustedSubnetServerInterceptor.
+func TrustedSubnetStreamServerInterceptor(trustedSubnet string) grpc.StreamServerInterceptor {
+	_, ipnetA, _ := net.ParseCIDR(trustedSubnet)
 
-		if netIP == nil {
-			return nil, ErrTrust
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := trustedContext(stream.Context(), ipnetA); err != nil {
+			return err
 		}
 
-		if !ipnetA.Contains(netIP) {
-			return nil, ErrTrust
+		return handler(srv, stream)
+	}
+}
+
+// metadataTenantContext returns ctx scoped to the tenant of common.TenantHeader metadata, unknown tenant is rejected.
+func metadataTenantContext(ctx context.Context, tenants *storage.Tenants) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	names := md.Get(common.TenantHeader)
+	if len(names) == 0 || names[0] == "" {
+		return ctx, nil
+	}
+
+	if tenants.Get(names[0]) == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "%s: %q", storage.ErrUnknownTenant, names[0])
+	}
+
+	return storage.ContextWithTenant(ctx, names[0]), nil
+}
+
+// TenantServerInterceptor scopes request to the tenant of common.TenantHeader metadata, unknown tenant is rejected.
+func TenantServerInterceptor(tenants *storage.Tenants) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+		ctx, err = metadataTenantContext(ctx, tenants)
+		if err != nil {
+			return nil, err
 		}
 
 		return handler(ctx, req)
 	}
 }
 
-func InitRPCServer(config *common.ServerConfig, ctx context.Context, stor storage.StorageInterface) *RPCServer {
-	var opts []grpc.ServerOption
+// tenantServerStream is the stream with context scoped to the tenant.
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *tenantServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+// TenantStreamServerInterceptor scopes streams like TenantServerInterceptor scopes requests.
+func TenantStreamServerInterceptor(tenants *storage.Tenants) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := metadataTenantContext(stream.Context(), tenants)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &tenantServerStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func InitRPCServer(
+	config *common.ServerConfig,
+	ctx context.Context,
+	stor storage.StorageInterface,
+	replication *Replication,
+	tenants *storage.Tenants,
+	watch *storage.WatchHub,
+) *RPCServer {
+	var interceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
 
 	if config.TrustedSubnet != "" {
 		log.Printf(
@@ -204,13 +683,28 @@ func InitRPCServer(config *common.ServerConfig, ctx context.Context, stor storag
 			config.TrustedSubnet,
 		)
 
-		opts = append(opts, grpc.UnaryInterceptor(TrustedSubnetServerInterceptor(config.TrustedSubnet)))
+		interceptors = append(interceptors, TrustedSubnetServerInterceptor(config.TrustedSubnet))
+		streamInterceptors = append(streamInterceptors, TrustedSubnetStreamServerInterceptor(config.TrustedSubnet))
+	}
+
+	if tenants != nil {
+		interceptors = append(interceptors, TenantServerInterceptor(tenants))
+		streamInterceptors = append(streamInterceptors, TenantStreamServerInterceptor(tenants))
 	}
 
 	s := &RPCServer{
 		address: config.Address,
-		server:  grpc.NewServer(opts...),
-		impl:    InitRPCImpl(stor),
+		server: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(interceptors...),
+			grpc.ChainStreamInterceptor(streamInterceptors...),
+		),
+		impl: InitRPCImpl(stor),
+	}
+	s.impl.SetReplication(replication)
+	s.impl.SetKey(config.Key)
+	s.impl.SetWatchHub(watch)
+	if tenants != nil {
+		s.impl.SetTenants(tenants)
 	}
 
 	return s
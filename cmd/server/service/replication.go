@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+	pb "github.com/GermanVor/devops-pet-project/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	PrimaryRole = "primary"
+	ReplicaRole = "replica"
+
+	// heartbeatInterval is the time between heartbeats of primary to idle replica
+	heartbeatInterval = time.Second
+	// reconnectInterval is the time between attempts of replica to connect to primary
+	reconnectInterval = time.Second
+	// dialTimeout is the time replica waits for connection to primary
+	dialTimeout = 10 * time.Second
+	// snapshotPartSize is the number of metrics in a message of snapshot
+	snapshotPartSize = 1000
+)
+
+type ReplicaStatus struct {
+	ID         string        `json:"id"`
+	Sequence   uint64        `json:"sequence"`
+	LagBatches uint64        `json:"lag_batches"`
+	Lag        time.Duration `json:"lag"`
+}
+
+type ReplicationStatus struct {
+	Role     string `json:"role"`
+	Epoch    uint64 `json:"epoch"`
+	Sequence uint64 `json:"sequence"`
+
+	// fields of replica
+	Primary         string        `json:"primary,omitempty"`
+	PrimarySequence uint64        `json:"primary_sequence,omitempty"`
+	Lag             time.Duration `json:"lag,omitempty"`
+
+	// connected replicas of primary
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+}
+
+type replicaState struct {
+	sequence uint64
+}
+
+// Replication serves replicas of the server and follows primary when the server is a replica.
+type Replication struct {
+	log       *storage.ReplicationStorageWrapper
+	primary   string
+	replicaID string
+	// key authorizes replicas and promotion (empty - they are checked only by trusted subnet)
+	key string
+
+	mutex    sync.Mutex
+	replicas map[string]*replicaState
+
+	// state of replica
+	primarySequence  uint64
+	lastMessageAt    time.Time
+	lastBatchAt      time.Time
+	stopFollowing    func()
+	followingStopped chan struct{}
+}
+
+func NewReplication(replicationLog *storage.ReplicationStorageWrapper, primary, replicaID string) *Replication {
+	return &Replication{
+		log:       replicationLog,
+		primary:   primary,
+		replicaID: replicaID,
+		replicas:  make(map[string]*replicaState),
+	}
+}
+
+// SetKey makes replication and promotion require HashHeader with common.ReplicationHash of key,
+// replica sends it to primary with the same key.
+func (r *Replication) SetKey(key string) {
+	r.key = key
+}
+
+// Authorized reports if hash of request authorizes action.
+func (r *Replication) Authorized(action, hash string) bool {
+	if r.key == "" {
+		return true
+	}
+
+	return hmac.Equal([]byte(hash), []byte(common.ReplicationHash(action, r.key)))
+}
+
+// Status returns replication state and lag at the moment now.
+func (r *Replication) Status(now time.Time) ReplicationStatus {
+	epoch, sequence := r.log.Position()
+
+	status := ReplicationStatus{
+		Role:     PrimaryRole,
+		Epoch:    epoch,
+		Sequence: sequence,
+		Replicas: make([]ReplicaStatus, 0),
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.log.IsReadOnly() {
+		status.Role = ReplicaRole
+		status.Primary = r.primary
+		status.PrimarySequence = r.primarySequence
+
+		// caught up replica is as fresh as the last message of primary
+		if sequence >= r.primarySequence {
+			status.Lag = now.Sub(r.lastMessageAt)
+		} else {
+			status.Lag = now.Sub(r.lastBatchAt)
+		}
+	}
+
+	for id, replica := range r.replicas {
+		replicaStatus := ReplicaStatus{ID: id, Sequence: replica.sequence}
+
+		if replica.sequence < sequence {
+			replicaStatus.LagBatches = sequence - replica.sequence
+
+			if timestamp, ok := r.log.Timestamp(replica.sequence + 1); ok {
+				replicaStatus.Lag = now.Sub(timestamp)
+			}
+		}
+
+		status.Replicas = append(status.Replicas, replicaStatus)
+	}
+
+	sort.Slice(status.Replicas, func(i, j int) bool {
+		return status.Replicas[i].ID < status.Replicas[j].ID
+	})
+
+	return status
+}
+
+// Promote stops following primary and makes the storage writable.
+func (r *Replication) Promote() {
+	r.mutex.Lock()
+	stopFollowing := r.stopFollowing
+	r.stopFollowing = nil
+	r.mutex.Unlock()
+
+	if stopFollowing != nil {
+		stopFollowing()
+	}
+
+	if r.log.IsReadOnly() {
+		r.log.Promote()
+
+		epoch, sequence := r.log.Position()
+		log.Printf("Replica is promoted to primary at epoch %d sequence %d\n", epoch, sequence)
+	}
+}
+
+// sendSnapshot sends all the metrics of primary in parts and returns position of the snapshot.
+func (r *Replication) sendSnapshot(stream pb.Metrics_ReplicateServer) (uint64, uint64, error) {
+	metrics := make([]*pb.Metric, 0)
+
+	epoch, sequence, err := r.log.Snapshot(stream.Context(), func(sm *storage.StorageMetric) {
+		metrics = append(metrics, pb.GetProtoStorageMetric(sm))
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	timestamp := timestamppb.Now()
+
+	for start := 0; start == 0 || start < len(metrics); start += snapshotPartSize {
+		end := start + snapshotPartSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+
+		err := stream.Send(&pb.ReplicationMessage{
+			Epoch:       epoch,
+			Sequence:    sequence,
+			Timestamp:   timestamp,
+			Metrics:     metrics[start:end],
+			Snapshot:    true,
+			SnapshotEnd: end == len(metrics),
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return epoch, sequence, nil
+}
+
+// Serve streams batches of the log to replica starting from the position of its first acknowledgement.
+func (r *Replication) Serve(stream pb.Metrics_ReplicateServer) error {
+	ack, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	replicaID := ack.ReplicaId
+	log.Printf("Replica %s is connected at epoch %d sequence %d\n", replicaID, ack.Epoch, ack.Sequence)
+
+	r.mutex.Lock()
+	r.replicas[replicaID] = &replicaState{sequence: ack.Sequence}
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.replicas, replicaID)
+		r.mutex.Unlock()
+
+		log.Printf("Replica %s is disconnected\n", replicaID)
+	}()
+
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			r.mutex.Lock()
+			if replica, ok := r.replicas[replicaID]; ok {
+				replica.sequence = ack.Sequence
+			}
+			r.mutex.Unlock()
+		}
+	}()
+
+	epoch, sequence := ack.Epoch, ack.Sequence
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		batches, ok, notify := r.log.Since(epoch, sequence)
+		if !ok {
+			if epoch, sequence, err = r.sendSnapshot(stream); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, batch := range batches {
+			metrics := make([]*pb.Metric, len(batch.Metrics))
+			for i := range batch.Metrics {
+				metrics[i] = pb.GetProtoMetric(&batch.Metrics[i])
+			}
+
+			deleted := make([]*pb.Series, len(batch.Deleted))
+			for i, sm := range batch.Deleted {
+				deleted[i] = pb.GetProtoSeries(sm)
+			}
+
+			err := stream.Send(&pb.ReplicationMessage{
+				Epoch:     epoch,
+				Sequence:  batch.Sequence,
+				Timestamp: timestamppb.New(batch.Timestamp),
+				Metrics:   metrics,
+				Deleted:   deleted,
+			})
+			if err != nil {
+				return err
+			}
+
+			sequence = batch.Sequence
+		}
+
+		if len(batches) != 0 {
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-heartbeat.C:
+			_, lastSequence := r.log.Position()
+
+			err := stream.Send(&pb.ReplicationMessage{
+				Epoch:     epoch,
+				Sequence:  lastSequence,
+				Timestamp: timestamppb.Now(),
+			})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func storageMetricOf(metric *common.Metric) *storage.StorageMetric {
+	storageMetric := &storage.StorageMetric{
+		ID:        metric.ID,
+		MType:     metric.MType,
+		Labels:    metric.Labels,
+		Histogram: metric.Histogram,
+		Summary:   metric.Summary,
+	}
+
+	if metric.Delta != nil {
+		storageMetric.Delta = *metric.Delta
+	}
+	if metric.Value != nil {
+		storageMetric.Value = *metric.Value
+	}
+
+	return storageMetric
+}
+
+// apply saves message of primary to the storage.
+func (r *Replication) apply(ctx context.Context, msg *pb.ReplicationMessage) error {
+	switch {
+	case msg.Snapshot:
+		snapshot := make([]*storage.StorageMetric, len(msg.Metrics))
+		for i, m := range msg.Metrics {
+			snapshot[i] = storageMetricOf(m.GetRequestMetric())
+		}
+
+		if err := r.log.ApplySnapshot(ctx, snapshot, msg.Epoch, msg.Sequence, msg.SnapshotEnd); err != nil {
+			return err
+		}
+
+		if msg.SnapshotEnd {
+			log.Printf("Snapshot of primary at epoch %d sequence %d is applied\n", msg.Epoch, msg.Sequence)
+		}
+	case len(msg.Metrics) != 0 || len(msg.Deleted) != 0:
+		batch := storage.ReplicationBatch{
+			Sequence:  msg.Sequence,
+			Timestamp: msg.Timestamp.AsTime(),
+			Metrics:   make([]common.Metric, len(msg.Metrics)),
+			Deleted:   make([]*storage.StorageMetric, len(msg.Deleted)),
+		}
+		for i, m := range msg.Metrics {
+			batch.Metrics[i] = *m.GetRequestMetric()
+		}
+		for i, series := range msg.Deleted {
+			batch.Deleted[i] = &storage.StorageMetric{ID: series.Id, MType: series.Type, Labels: series.Labels}
+		}
+
+		if err := r.log.Apply(ctx, msg.Epoch, batch); err != nil {
+			return err
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if msg.Sequence > r.primarySequence || msg.Snapshot {
+		r.primarySequence = msg.Sequence
+	}
+	r.lastMessageAt = time.Now()
+	if len(msg.Metrics) != 0 || len(msg.Deleted) != 0 {
+		r.lastBatchAt = msg.Timestamp.AsTime()
+	}
+
+	return nil
+}
+
+// follow applies messages of primary until the stream is broken.
+func (r *Replication) follow(ctx context.Context) error {
+	// local address of the connection is sent as TrustedSubnetHeader, primary may accept only trusted subnet
+	var localIP atomic.Value
+	dialer := func(ctx context.Context, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err == nil {
+			if tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+				localIP.Store(tcpAddr.IP.String())
+			}
+		}
+
+		return conn, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(
+		dialCtx,
+		r.primary,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(dialer),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if ip, ok := localIP.Load().(string); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx, TrustedSubnetHeader, ip)
+	}
+	if r.key != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, common.HashHeader, common.ReplicationHash(common.ReplicateAction, r.key))
+	}
+
+	stream, err := pb.NewMetricsClient(conn).Replicate(ctx)
+	if err != nil {
+		return err
+	}
+
+	sendAck := func() error {
+		epoch, sequence := r.log.Position()
+
+		return stream.Send(&pb.ReplicationAck{
+			ReplicaId: r.replicaID,
+			Epoch:     epoch,
+			Sequence:  sequence,
+		})
+	}
+
+	if err := sendAck(); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if err := r.apply(ctx, msg); err != nil {
+			return err
+		}
+
+		if err := sendAck(); err != nil {
+			return err
+		}
+	}
+}
+
+// StartFollowing follows primary (reconnecting after failures) until Promote or returned function is called.
+func (r *Replication) StartFollowing() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for {
+			err := r.follow(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectInterval):
+				log.Println("Replication from", r.primary, "is broken:", err)
+			}
+		}
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+	}
+
+	r.mutex.Lock()
+	r.stopFollowing = stop
+	r.mutex.Unlock()
+
+	return func() {
+		r.mutex.Lock()
+		stopFollowing := r.stopFollowing
+		r.stopFollowing = nil
+		r.mutex.Unlock()
+
+		if stopFollowing != nil {
+			stopFollowing()
+		}
+	}
+}
+
+// StatusHandler Handler to get replication role, position and lag as JSON (lag in nanoseconds).
+func (r *Replication) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		jsonResp, _ := json.Marshal(r.Status(time.Now()))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
+
+// PromoteHandler Handler to promote replica to primary by POST request.
+//
+// key - secret key for authorization, X-Hash header should be common.ReplicationHash(common.PromoteAction, key).
+func (r *Replication) PromoteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !r.Authorized(common.PromoteAction, req.Header.Get(common.HashHeader)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		r.Promote()
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetProtoReplicationStatus converts status to gRPC response.
+func GetProtoReplicationStatus(status ReplicationStatus) *pb.ReplicationStatusResponse {
+	resp := &pb.ReplicationStatusResponse{
+		Role:            status.Role,
+		Epoch:           status.Epoch,
+		Sequence:        status.Sequence,
+		Primary:         status.Primary,
+		PrimarySequence: status.PrimarySequence,
+		Replicas:        make([]*pb.ReplicaStatus, len(status.Replicas)),
+	}
+
+	if status.Role == ReplicaRole {
+		resp.Lag = durationpb.New(status.Lag)
+	}
+
+	for i, replica := range status.Replicas {
+		resp.Replicas[i] = &pb.ReplicaStatus{
+			Id:         replica.ID,
+			Sequence:   replica.Sequence,
+			LagBatches: replica.LagBatches,
+			Lag:        durationpb.New(replica.Lag),
+		}
+	}
+
+	return resp
+}
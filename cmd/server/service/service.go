@@ -2,13 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
 	"time"
 
+	"github.com/GermanVor/devops-pet-project/cmd/server/handlers"
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/storage"
 )
 
+var ErrReplicationAuth = errors.New("replication needs KEY or TRUSTED_SUBNET to authorize replicas and promotion")
+
+var ErrFaultInjectionAuth = errors.New("fault injection needs KEY or TRUSTED_SUBNET to authorize /debug/faults")
+
 type ServiceInterface interface {
 	Start() error
 }
@@ -16,6 +24,23 @@ type ServiceInterface interface {
 type service struct {
 	server     ServiceInterface
 	destructor func()
+	// debugHandlers are served by HTTP server by path
+	debugHandlers map[string]http.Handler
+	// replicationServer is the gRPC listener for replicas apart from server
+	replicationServer ServiceInterface
+}
+
+// addDestructor makes destructor called before the current one.
+func (s *service) addDestructor(destructor func()) {
+	next := s.destructor
+
+	s.destructor = func() {
+		destructor()
+
+		if next != nil {
+			next()
+		}
+	}
 }
 
 func InitService(
@@ -23,7 +48,13 @@ func InitService(
 	ctx context.Context,
 	serviceType common.ServiceType,
 ) (*service, error) {
-	service := &service{}
+	service := &service{
+		debugHandlers: make(map[string]http.Handler),
+	}
+
+	// storageMetrics times calls of the base storage and its backups
+	storageMetrics := storage.NewStorageMetrics(nil)
+	service.debugHandlers["/debug/metrics"] = handlers.SelfMetrics(storageMetrics)
 
 	var currentStor storage.StorageInterface
 	if config.DataBaseDSN != "" {
@@ -36,41 +67,224 @@ func InitService(
 
 		currentStor = sqlStorage
 		service.destructor = sqlStorage.Close
-	} else {
+
+		if config.HistoryRollups != "" {
+			rollupPolicy, err := storage.ParseRollupPolicy(config.HistoryRollups)
+			if err != nil {
+				sqlStorage.Close()
+				return nil, err
+			}
+
+			sqlStorage.SetRollupPolicy(rollupPolicy)
+
+			if config.RollupInterval.Duration > 0 {
+				log.Println("Server rolls up metrics history every", config.RollupInterval.Duration)
+
+				service.addDestructor(sqlStorage.StartRollups(config.RollupInterval.Duration))
+			}
+		}
+
+		if config.CacheSize > 0 {
+			log.Println("Server caches", config.CacheSize, "metrics in front of database")
+
+			cache := storage.WithCache(sqlStorage, config.CacheSize)
+			currentStor = cache
+			service.debugHandlers["/debug/cache"] = handlers.CacheStats(cache)
+		}
+	} else if config.StoreBackend == common.BoltStoreBackend {
+		boltStorage, err := storage.InitBolt(config.StoreFile)
+		if err != nil {
+			return nil, err
+		}
+
+		boltStorage.SetHistorySize(config.HistorySize)
+
+		currentStor = boltStorage
+		service.destructor = boltStorage.Close
+	} else if config.StoreBackend == "" || config.StoreBackend == common.MemoryStoreBackend {
 		var initialFilePath *string
 		if config.IsRestore && config.StoreFile != "" {
 			initialFilePath = &config.StoreFile
 		}
 
-		stor, _ := storage.Init(initialFilePath)
+		codec, err := storage.NewBackupCodec(config.StoreCompression, config.StoreEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		if config.StoreEncryptionMigrate {
+			codec.AllowUnencrypted()
+		}
+
+		stor, err := storage.InitShardedWithCodec(initialFilePath, config.StoreShards, codec)
+		// backup which can not be decrypted (or write-ahead log which can not be moved aside)
+		// is not overwritten by empty storage
+		if errors.Is(err, storage.ErrBackupKeyRequired) || errors.Is(err, storage.ErrBackupDecrypt) ||
+			errors.Is(err, storage.ErrBackupNotEncrypted) || errors.Is(err, storage.ErrWALCorrupted) {
+			return nil, err
+		}
+		stor.SetHistorySize(config.HistorySize)
 		currentStor = stor
 
 		if config.StoreFile != "" {
+			backupConfig := storage.BackupConfig{
+				FilePath:  config.StoreFile,
+				Retention: config.StoreRetention,
+				Codec:     codec,
+				Metrics:   storageMetrics,
+			}
+
 			if config.StoreInterval.Duration == time.Duration(0) {
-				currentStor = storage.WithBackup(stor, config.StoreFile)
+				log.Println("Server works with write-ahead log", storage.WALFilePath(config.StoreFile))
+
+				walStor, err := storage.WithWAL(stor, backupConfig, config.WALCompactInterval.Duration)
+				if err != nil {
+					return nil, err
+				}
+
+				currentStor = walStor
+				service.destructor = walStor.Close
 			} else {
 				log.Println("Server works with InitBackupTicker", config.StoreFile, config.StoreInterval.Duration)
-				service.destructor = storage.InitBackupTicker(stor, config.StoreFile, config.StoreInterval.Duration)
+				service.destructor = storage.InitBackupTicker(stor, backupConfig, config.StoreInterval.Duration)
 			}
 		}
+	} else {
+		return nil, common.ErrUnknownStoreBackend
+	}
+
+	// faults are injected right above the base storage, so every wrapper sees them as storage failures
+	if config.FaultInjection {
+		// faults make storage fail for every client
+		if config.Key == "" && config.TrustedSubnet == "" {
+			service.Destructor()
+			return nil, ErrFaultInjectionAuth
+		}
+
+		log.Println("WARNING: Server injects storage faults set by /debug/faults, it is for tests and chaos drills only")
+
+		faults := storage.WithFaults(currentStor)
+		currentStor = faults
+		service.debugHandlers["/debug/faults"] = handlers.Faults(faults, config.Key)
+	}
+
+	// instrumentation is above faults, so injected latency and errors are seen in /debug/metrics
+	currentStor = storage.WithInstrumentation(currentStor, storageMetrics)
+
+	// watch wraps the base storage, so updates applied by replica are published too
+	var watchHub *storage.WatchHub
+	if config.WatchBufferSize > 0 {
+		watchHub = storage.NewWatchHub(config.WatchBufferSize)
+		currentStor = storage.WithWatch(currentStor, watchHub)
+
+		service.addDestructor(watchHub.Close)
+		service.debugHandlers["/debug/watch"] = handlers.WatchStats(watchHub)
+	}
+
+	var replication *Replication
+	var replicationLog *storage.ReplicationStorageWrapper
+	if config.ReplicationAddress != "" || config.ReplicateFrom != "" {
+		// replication stream has metrics of all the tenants, promotion makes a second primary
+		if config.Key == "" && config.TrustedSubnet == "" {
+			service.Destructor()
+			return nil, ErrReplicationAuth
+		}
+
+		replicationLog = storage.WithReplicationLog(currentStor, config.ReplicationLogSize, config.ReplicateFrom != "")
+		currentStor = replicationLog
+
+		replicaID := config.Address
+		if hostname, err := os.Hostname(); err == nil {
+			replicaID = hostname + "/" + config.Address
+		}
+
+		replication = NewReplication(replicationLog, config.ReplicateFrom, replicaID)
+		replication.SetKey(config.Key)
+		service.debugHandlers["/replication/status"] = replication.StatusHandler()
+		service.debugHandlers["/replication/promote"] = replication.PromoteHandler()
+	}
+
+	// rates are above the replication log, so primary logs converted cumulative counters
+	// and replica tracks batches applied below rates by the log
+	if config.RateWindow.Duration > 0 {
+		rateTracker, err := storage.NewRateTracker(config.RateWindow.Duration, config.CumulativeCounters)
+		if err != nil {
+			service.Destructor()
+			return nil, err
+		}
+
+		rateStor := storage.WithRates(currentStor, rateTracker)
+		if replicationLog != nil {
+			replicationLog.OnApply(rateStor.ObserveReplicated)
+		}
+
+		currentStor = rateStor
+	}
+
+	if config.ReplicateFrom != "" {
+		log.Println("Server is a read-only replica of", config.ReplicateFrom)
+
+		service.addDestructor(replication.StartFollowing())
+	}
+
+	var tenants *storage.Tenants
+	if config.TenantsFile != "" {
+		var err error
+		if tenants, err = storage.LoadTenants(config.TenantsFile); err != nil {
+			service.Destructor()
+			return nil, err
+		}
+
+		log.Println("Server keeps metrics of tenants apart, tenants are loaded from", config.TenantsFile)
+
+		currentStor = storage.WithTenants(currentStor, tenants)
+	}
+
+	ttlPolicy, err := storage.NewTTLPolicy(config.MetricTTL.Duration, config.MetricTTLRules)
+	if err != nil {
+		service.Destructor()
+		return nil, err
+	}
+
+	if ttlPolicy.MinTTL() > 0 && config.TTLCheckInterval.Duration > 0 {
+		log.Println("Server evicts expired metrics every", config.TTLCheckInterval.Duration)
+
+		service.addDestructor(storage.NewJanitor(currentStor, ttlPolicy).Start(config.TTLCheckInterval.Duration))
 	}
 
 	switch serviceType {
 	case common.HTTP:
-		service.server = InitHTTPServer(config, ctx, currentStor)
+		service.server = InitHTTPServer(config, ctx, currentStor, service.debugHandlers, tenants, watchHub)
 	case common.GRPC:
-		service.server = InitRPCServer(config, ctx, currentStor)
+		service.server = InitRPCServer(config, ctx, currentStor, replication, tenants, watchHub)
 	default:
+		service.Destructor()
 		return nil, common.ErrUnknownServiceType
 	}
 
+	if config.ReplicationAddress != "" && (serviceType != common.GRPC || config.ReplicationAddress != config.Address) {
+		replicationConfig := *config
+		replicationConfig.Address = config.ReplicationAddress
+
+		service.replicationServer = InitRPCServer(&replicationConfig, ctx, currentStor, replication, tenants, watchHub)
+	}
+
 	return service, nil
 }
 
 func (s *service) Start() error {
+	if s.replicationServer != nil {
+		go func() {
+			if err := s.replicationServer.Start(); err != nil {
+				log.Println("Replication listener is stopped:", err)
+			}
+		}()
+	}
+
 	return s.server.Start()
 }
 
 func (s *service) Destructor() {
-	s.destructor()
+	if s.destructor != nil {
+		s.destructor()
+	}
 }
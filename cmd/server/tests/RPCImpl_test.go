@@ -4,7 +4,9 @@ import (
 	"context"
 	"log"
 	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/GermanVor/devops-pet-project/cmd/server/service"
 	"github.com/GermanVor/devops-pet-project/internal/common"
@@ -13,7 +15,10 @@ import (
 	"github.com/bmizerany/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -66,6 +71,20 @@ func TestSingleMetric(t *testing.T) {
 		assert.Equal(t, (*pb.Error)(nil), resp.Error)
 	})
 
+	t.Run("GetMetricHistory", func(t *testing.T) {
+		resp, err := client.GetMetricHistory(ctx, &pb.GetMetricHistoryRequest{
+			Id:   pbMetric.Id,
+			Type: metricType,
+		})
+
+		require.NoError(t, err)
+
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+
+		require.NotEqual(t, 0, len(resp.Points))
+		assert.Equal(t, value, resp.Points[len(resp.Points)-1].GetGauge().GetValue())
+	})
+
 	t.Run("GetMetric", func(t *testing.T) {
 		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{
 			Id:   pbMetric.Id,
@@ -133,3 +152,648 @@ func TestMiltiplyMetrics(t *testing.T) {
 		}
 	})
 }
+
+func TestMetricLabels(t *testing.T) {
+	metrics := []*pb.Metric{
+		{
+			Id:     "Labeled",
+			Spec:   &pb.Metric_Gauge{Gauge: &pb.GaugeMetric{Value: 1}},
+			Labels: map[string]string{"host": "host-1"},
+		},
+		{
+			Id:     "Labeled",
+			Spec:   &pb.Metric_Gauge{Gauge: &pb.GaugeMetric{Value: 2}},
+			Labels: map[string]string{"host": "host-2"},
+		},
+	}
+
+	ctx := context.Background()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	addResp, err := client.AddMetrics(ctx, &pb.AddMetricsRequest{Metrics: metrics})
+	require.NoError(t, err)
+	assert.Equal(t, (*pb.Error)(nil), addResp.Error)
+
+	t.Run("GetMetric", func(t *testing.T) {
+		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{
+			Id:     "Labeled",
+			Type:   common.GaugeMetricName,
+			Labels: map[string]string{"host": "host-2"},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		assert.Equal(t, true, resp.Metric.Equal(metrics[1]))
+	})
+
+	t.Run("GetMetrics with matchers", func(t *testing.T) {
+		resp, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{Match: `{host="host-1"}`})
+
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		require.Len(t, resp.Metrics, 1)
+		assert.Equal(t, true, resp.Metrics[0].Equal(metrics[0]))
+	})
+
+	t.Run("Bad label name", func(t *testing.T) {
+		resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{
+			Metric: &pb.Metric{
+				Id:     "Labeled",
+				Spec:   &pb.Metric_Gauge{Gauge: &pb.GaugeMetric{Value: 1}},
+				Labels: map[string]string{"host-name": "host-1"},
+			},
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+}
+
+func TestHistogramAndSummary(t *testing.T) {
+	histogram := &pb.HistogramMetric{
+		Buckets: []*pb.HistogramBucket{{UpperBound: 0.5, Count: 1}, {UpperBound: 1, Count: 2}},
+		Sum:     1.5,
+		Count:   3,
+	}
+	summary := &pb.SummaryMetric{
+		Quantiles: []*pb.SummaryQuantile{{Quantile: 0.5, Value: 0.4}, {Quantile: 0.9, Value: 0.9}},
+		Sum:       1.5,
+		Count:     3,
+	}
+
+	ctx := context.Background()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	for i := 0; i < 2; i++ {
+		addResp, err := client.AddMetrics(ctx, &pb.AddMetricsRequest{Metrics: []*pb.Metric{
+			{Id: "Latency", Spec: &pb.Metric_Histogram{Histogram: histogram}},
+			{Id: "Latency", Spec: &pb.Metric_Summary{Summary: summary}},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), addResp.Error)
+	}
+
+	t.Run("Histogram is merged", func(t *testing.T) {
+		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Latency", Type: common.HistogramMetricName})
+
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		assert.Equal(t, true, resp.Metric.Equal(&pb.Metric{
+			Id: "Latency",
+			Spec: &pb.Metric_Histogram{Histogram: &pb.HistogramMetric{
+				Buckets: []*pb.HistogramBucket{{UpperBound: 0.5, Count: 2}, {UpperBound: 1, Count: 4}},
+				Sum:     3,
+				Count:   6,
+			}},
+		}))
+	})
+
+	t.Run("Summary is replaced", func(t *testing.T) {
+		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Latency", Type: common.SummaryMetricName})
+
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		assert.Equal(t, true, resp.Metric.Equal(&pb.Metric{Id: "Latency", Spec: &pb.Metric_Summary{Summary: summary}}))
+	})
+
+	t.Run("Bad histogram", func(t *testing.T) {
+		resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{
+			Metric: &pb.Metric{
+				Id: "Latency",
+				Spec: &pb.Metric_Histogram{Histogram: &pb.HistogramMetric{
+					Buckets: []*pb.HistogramBucket{{UpperBound: 1, Count: 2}, {UpperBound: 0.5, Count: 1}},
+				}},
+			},
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+}
+
+// startReplicationServer serves gRPC methods of storage with replication on a local port.
+func startReplicationServer(t *testing.T, replication *service.Replication, stor storage.StorageInterface) string {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	impl := service.InitRPCImpl(stor)
+	impl.SetReplication(replication)
+
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, impl)
+
+	go s.Serve(listen)
+	t.Cleanup(s.Stop)
+
+	return listen.Addr().String()
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	replicationKey := "replication-key"
+
+	primaryStor, _ := storage.Init(nil)
+	primaryLog := storage.WithReplicationLog(primaryStor, 0, false)
+	primaryReplication := service.NewReplication(primaryLog, "", "primary")
+	primaryReplication.SetKey(replicationKey)
+	primaryAddress := startReplicationServer(t, primaryReplication, primaryLog)
+
+	delta := int64(5)
+	require.NoError(t, primaryLog.UpdateMetric(ctx, common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}))
+
+	replicaStor, _ := storage.Init(nil)
+	replicaLog := storage.WithReplicationLog(replicaStor, 0, true)
+	replication := service.NewReplication(replicaLog, primaryAddress, "replica")
+	replication.SetKey(replicationKey)
+	replicaAddress := startReplicationServer(t, replication, replicaLog)
+
+	stopFollowing := replication.StartFollowing()
+	defer stopFollowing()
+
+	conn, err := grpc.DialContext(ctx, replicaAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	getPollCount := func() int64 {
+		resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: common.CounterMetricName})
+		require.NoError(t, err)
+
+		if resp.Error != nil {
+			return 0
+		}
+
+		return resp.Metric.GetCounter().GetDelta()
+	}
+
+	t.Run("Replica receives snapshot", func(t *testing.T) {
+		require.Eventually(t, func() bool { return getPollCount() == 5 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Replica receives batches", func(t *testing.T) {
+		require.NoError(t, primaryLog.UpdateMetric(ctx, common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}))
+
+		require.Eventually(t, func() bool { return getPollCount() == 10 }, 5*time.Second, 10*time.Millisecond)
+
+		resp, err := client.GetReplicationStatus(ctx, &pb.ReplicationStatusRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, service.ReplicaRole, resp.Role)
+		assert.Equal(t, primaryAddress, resp.Primary)
+		assert.Equal(t, uint64(2), resp.Sequence)
+		assert.Equal(t, true, resp.Lag.AsDuration() < 5*time.Second)
+	})
+
+	t.Run("Replica rejects writes", func(t *testing.T) {
+		resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{
+			Metric: pb.GetProtoMetric(&common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}),
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusServiceUnavailable), resp.Error.Code)
+	})
+
+	t.Run("Primary reports replica lag", func(t *testing.T) {
+		primaryConn, err := grpc.DialContext(ctx, primaryAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer primaryConn.Close()
+
+		require.Eventually(t, func() bool {
+			resp, err := pb.NewMetricsClient(primaryConn).GetReplicationStatus(ctx, &pb.ReplicationStatusRequest{})
+			require.NoError(t, err)
+
+			return resp.Role == service.PrimaryRole &&
+				len(resp.Replicas) == 1 &&
+				resp.Replicas[0].Id == "replica" &&
+				resp.Replicas[0].Sequence == 2 &&
+				resp.Replicas[0].LagBatches == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Replication needs key", func(t *testing.T) {
+		primaryConn, err := grpc.DialContext(ctx, primaryAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer primaryConn.Close()
+
+		stream, err := pb.NewMetricsClient(primaryConn).Replicate(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.ReplicationAck{ReplicaId: "intruder"}))
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		promoteResp, err := client.Promote(ctx, &pb.PromoteRequest{})
+		require.NoError(t, err)
+		require.NotNil(t, promoteResp.Error)
+		assert.Equal(t, int32(http.StatusForbidden), promoteResp.Error.Code)
+
+		promoteResp, err = client.Promote(
+			metadata.AppendToOutgoingContext(ctx, common.HashHeader, common.ReplicationHash(common.ReplicateAction, replicationKey)),
+			&pb.PromoteRequest{},
+		)
+		require.NoError(t, err)
+		require.NotNil(t, promoteResp.Error)
+	})
+
+	t.Run("Promoted replica accepts writes", func(t *testing.T) {
+		promoteCtx := metadata.AppendToOutgoingContext(ctx, common.HashHeader, common.ReplicationHash(common.PromoteAction, replicationKey))
+
+		promoteResp, err := client.Promote(promoteCtx, &pb.PromoteRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), promoteResp.Error)
+
+		resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{
+			Metric: pb.GetProtoMetric(&common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		assert.Equal(t, int64(15), getPollCount())
+
+		statusResp, err := client.GetReplicationStatus(ctx, &pb.ReplicationStatusRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, service.PrimaryRole, statusResp.Role)
+	})
+
+	t.Run("Replication is disabled", func(t *testing.T) {
+		conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		resp, err := pb.NewMetricsClient(conn).Promote(ctx, &pb.PromoteRequest{})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusNotImplemented), resp.Error.Code)
+	})
+}
+
+func TestTenants(t *testing.T) {
+	ctx := context.Background()
+
+	tenants, err := storage.ParseTenants([]byte(`[{"name": "team-a", "key": "key-a"}, {"name": "team-b", "public": true}]`))
+	require.NoError(t, err)
+
+	baseStor, _ := storage.Init(nil)
+	impl := service.InitRPCImpl(storage.WithTenants(baseStor, tenants))
+	impl.SetTenants(tenants)
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer(grpc.UnaryInterceptor(service.TenantServerInterceptor(tenants)))
+	pb.RegisterMetricsServer(s, impl)
+	go s.Serve(listen)
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(ctx, listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	tenantCtx := func(tenant string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, common.TenantHeader, tenant)
+	}
+	countMetrics := func(ctx context.Context) int {
+		resp, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{})
+		require.NoError(t, err)
+
+		return len(resp.Metrics)
+	}
+
+	value := float64(1)
+	metric := &common.Metric{ID: "Alloc", MType: common.GaugeMetricName, Value: &value}
+	require.NoError(t, metric.SetHash("key-a"))
+
+	pbMetric := pb.GetProtoMetric(metric)
+
+	t.Run("Tenant by key", func(t *testing.T) {
+		resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{Metric: pbMetric})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+
+		assert.Equal(t, 1, countMetrics(tenantCtx("team-a")))
+		assert.Equal(t, 0, countMetrics(tenantCtx("team-b")))
+		assert.Equal(t, 0, countMetrics(ctx))
+	})
+
+	t.Run("Tenant key is checked", func(t *testing.T) {
+		resp, err := client.AddMetric(tenantCtx("team-a"), &pb.AddMetricRequest{
+			Metric: pb.GetProtoMetric(&common.Metric{ID: "Sys", MType: common.GaugeMetricName, Value: &value}),
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+
+	t.Run("Unknown tenant", func(t *testing.T) {
+		_, err := client.GetMetrics(tenantCtx("team-c"), &pb.GetMetricsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestPagedMetrics(t *testing.T) {
+	metrics := make([]*pb.Metric, 0)
+	for i := 0; i < 5; i++ {
+		metrics = append(metrics, &pb.Metric{
+			Id:   "Paged" + string(rune('A'+i)),
+			Spec: &pb.Metric_Gauge{Gauge: &pb.GaugeMetric{Value: float64(i)}},
+		})
+	}
+
+	ctx := context.Background()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial bufnet: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	addResp, err := client.AddMetrics(ctx, &pb.AddMetricsRequest{Metrics: metrics})
+	require.NoError(t, err)
+	assert.Equal(t, (*pb.Error)(nil), addResp.Error)
+
+	t.Run("Pages", func(t *testing.T) {
+		ids := make([]string, 0)
+		req := &pb.GetMetricsRequest{Type: common.GaugeMetricName, IdPrefix: "Paged", Limit: 2}
+
+		for pages := 1; ; pages++ {
+			resp, err := client.GetMetrics(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, (*pb.Error)(nil), resp.Error)
+
+			for _, metric := range resp.Metrics {
+				ids = append(ids, metric.Id)
+			}
+
+			if resp.NextCursor == "" {
+				assert.Equal(t, 3, pages)
+				break
+			}
+
+			req.Cursor = resp.NextCursor
+		}
+
+		assert.Equal(t, []string{"PagedA", "PagedB", "PagedC", "PagedD", "PagedE"}, ids)
+	})
+
+	t.Run("ID regexp", func(t *testing.T) {
+		resp, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{IdRegex: "Paged[BD]"})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		require.Len(t, resp.Metrics, 2)
+		assert.Equal(t, true, resp.Metrics[1].Equal(metrics[3]))
+	})
+
+	t.Run("Bad cursor", func(t *testing.T) {
+		resp, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{Cursor: "!"})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+}
+
+func TestDeleteMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	baseStor, _ := storage.Init(nil)
+	impl := service.InitRPCImpl(baseStor)
+	impl.SetKey("key")
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, impl)
+	go s.Serve(listen)
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(ctx, listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	value := float64(1)
+	for _, id := range []string{"HeapAlloc", "HeapSys", "Alloc"} {
+		require.NoError(t, baseStor.UpdateMetric(ctx, common.Metric{ID: id, MType: common.GaugeMetricName, Value: &value}))
+	}
+
+	t.Run("Empty filter", func(t *testing.T) {
+		resp, err := client.DeleteMetrics(ctx, &pb.DeleteMetricsRequest{Hash: common.DeleteMetricsHash("", "", "", "", "key")})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+
+	t.Run("Wrong hash", func(t *testing.T) {
+		resp, err := client.DeleteMetrics(ctx, &pb.DeleteMetricsRequest{IdPrefix: "Heap", Hash: "hash"})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+
+	t.Run("Delete by filter", func(t *testing.T) {
+		resp, err := client.DeleteMetrics(ctx, &pb.DeleteMetricsRequest{
+			Type:     common.GaugeMetricName,
+			IdPrefix: "Heap",
+			Hash:     common.DeleteMetricsHash(common.GaugeMetricName, "Heap", "", "", "key"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, (*pb.Error)(nil), resp.Error)
+		assert.Equal(t, 2, len(resp.Deleted))
+
+		getResp, err := client.GetMetrics(ctx, &pb.GetMetricsRequest{})
+		require.NoError(t, err)
+		require.Len(t, getResp.Metrics, 1)
+		assert.Equal(t, "Alloc", getResp.Metrics[0].Id)
+	})
+}
+
+func TestConditionalGauge(t *testing.T) {
+	ctx := context.Background()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	gauge := func(value float64, version uint64) *pb.Metric {
+		return &pb.Metric{
+			Id:           "ConditionalJobs",
+			Spec:         &pb.Metric_Gauge{Gauge: &pb.GaugeMetric{Value: value}},
+			Precondition: &pb.Precondition{Version: &version},
+		}
+	}
+
+	resp, err := client.AddMetric(ctx, &pb.AddMetricRequest{Metric: gauge(1, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, (*pb.Error)(nil), resp.Error)
+
+	resp, err = client.AddMetric(ctx, &pb.AddMetricRequest{Metric: gauge(2, 0)})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, int32(http.StatusConflict), resp.Error.Code)
+
+	addResp, err := client.AddMetrics(ctx, &pb.AddMetricsRequest{Metrics: []*pb.Metric{gauge(2, 1), gauge(3, 1)}})
+	require.NoError(t, err)
+	require.NotNil(t, addResp.Error)
+	assert.Equal(t, int32(http.StatusConflict), addResp.Error.Code)
+
+	getResp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "ConditionalJobs", Type: common.GaugeMetricName})
+	require.NoError(t, err)
+	require.NotNil(t, getResp.Metric)
+	assert.Equal(t, uint64(1), getResp.Metric.Version)
+	assert.Equal(t, 1.0, getResp.Metric.GetGauge().Value)
+}
+
+func TestWatchMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	baseStor, _ := storage.Init(nil)
+	hub := storage.NewWatchHub(16)
+	watchStor := storage.WithWatch(baseStor, hub)
+
+	impl := service.InitRPCImpl(watchStor)
+	impl.SetWatchHub(hub)
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, impl)
+	go s.Serve(listen)
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(ctx, listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	gauge := func(id string, value float64) common.Metric {
+		return common.Metric{ID: id, MType: common.GaugeMetricName, Value: &value}
+	}
+
+	t.Run("Without hub", func(t *testing.T) {
+		conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		stream, err := pb.NewMetricsClient(conn).WatchMetrics(ctx, &pb.WatchMetricsRequest{})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusNotImplemented), resp.Error.Code)
+	})
+
+	t.Run("Bad filter", func(t *testing.T) {
+		stream, err := client.WatchMetrics(ctx, &pb.WatchMetricsRequest{IdRegex: "("})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusBadRequest), resp.Error.Code)
+	})
+
+	t.Run("Stream changes", func(t *testing.T) {
+		require.NoError(t, watchStor.UpdateMetric(ctx, gauge("HeapAlloc", 1)))
+
+		streamCtx, cancelStream := context.WithCancel(ctx)
+		defer cancelStream()
+
+		stream, err := client.WatchMetrics(streamCtx, &pb.WatchMetricsRequest{IdPrefix: "Heap", Initial: true})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetUpdated())
+		assert.Equal(t, "HeapAlloc", resp.GetUpdated().Id)
+		assert.Equal(t, float64(1), resp.GetUpdated().GetGauge().Value)
+
+		require.NoError(t, watchStor.UpdateMetric(ctx, gauge("Alloc", 2)))
+		require.NoError(t, watchStor.UpdateMetric(ctx, gauge("HeapAlloc", 2)))
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetUpdated())
+		assert.Equal(t, float64(2), resp.GetUpdated().GetGauge().Value)
+		assert.Equal(t, uint64(2), resp.GetUpdated().Version)
+
+		_, err = watchStor.DeleteMetric(ctx, common.GaugeMetricName, "HeapAlloc")
+		require.NoError(t, err)
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetDeleted())
+		assert.Equal(t, "HeapAlloc", resp.GetDeleted().Id)
+
+		hub.Close()
+
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusServiceUnavailable), resp.Error.Code)
+	})
+}
+
+func TestTrustedSubnetStream(t *testing.T) {
+	ctx := context.Background()
+
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	baseStor, _ := storage.Init(nil)
+
+	s := grpc.NewServer(grpc.ChainStreamInterceptor(service.TrustedSubnetStreamServerInterceptor("10.0.0.0/8")))
+	pb.RegisterMetricsServer(s, service.InitRPCImpl(baseStor))
+	go s.Serve(listen)
+	defer s.Stop()
+
+	conn, err := grpc.DialContext(ctx, listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := pb.NewMetricsClient(conn)
+
+	t.Run("Untrusted stream is rejected", func(t *testing.T) {
+		for _, streamCtx := range []context.Context{
+			ctx,
+			metadata.AppendToOutgoingContext(ctx, service.TrustedSubnetHeader, "192.168.0.1"),
+		} {
+			stream, err := client.WatchMetrics(streamCtx, &pb.WatchMetricsRequest{})
+			require.NoError(t, err)
+
+			_, err = stream.Recv()
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("Trusted stream is served", func(t *testing.T) {
+		stream, err := client.WatchMetrics(
+			metadata.AppendToOutgoingContext(ctx, service.TrustedSubnetHeader, "10.1.2.3"),
+			&pb.WatchMetricsRequest{},
+		)
+		require.NoError(t, err)
+
+		// server without watch hub answers with error response
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.NotNil(t, resp.Error)
+		assert.Equal(t, int32(http.StatusNotImplemented), resp.Error.Code)
+	})
+}
@@ -21,10 +21,29 @@ import (
 //go:generate easyjson common.go
 
 const (
-	CounterMetricName = "counter"
-	GaugeMetricName   = "gauge"
+	CounterMetricName   = "counter"
+	GaugeMetricName     = "gauge"
+	HistogramMetricName = "histogram"
+	SummaryMetricName   = "summary"
+
+	// RateMetricName is read-only per-second rate of counter derived by Server
+	RateMetricName = "rate"
 )
 
+const (
+	MemoryStoreBackend = "memory"
+	BoltStoreBackend   = "bolt"
+)
+
+// TenantHeader is HTTP header (and gRPC metadata) with tenant of the request
+const TenantHeader = "X-Tenant"
+
+// NextCursorHeader is HTTP header with cursor of the next page of metrics list
+const NextCursorHeader = "X-Next-Cursor"
+
+// HashHeader is HTTP header with hash of request without Metric body (DeleteMetricHash)
+const HashHeader = "X-Hash"
+
 //easyjson:json
 type Metric struct {
 	ID    string   `json:"id"`              // имя метрики
@@ -32,6 +51,14 @@ type Metric struct {
 	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
 	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
 	Hash  *string  `json:"hash,omitempty"`  // значение хеш-функции
+
+	Labels map[string]string `json:"labels,omitempty"` // метки серии (host, region, ...)
+
+	Histogram *Histogram `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Summary   *Summary   `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+
+	Version      *uint64       `json:"version,omitempty"`      // версия gauge, возвращается сервером
+	Precondition *Precondition `json:"precondition,omitempty"` // условие обновления gauge
 }
 
 var (
@@ -45,7 +72,7 @@ func createMetricHash(metricsStatsStr, key string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-// getMetricHash build hash of the metrics
+// getMetricHash build hash of the metrics (series key with labels, value and precondition)
 // based on the sha256
 func getHashOfMetric(metrics *Metric, key string) (string, error) {
 	var hash string
@@ -55,13 +82,31 @@ func getHashOfMetric(metrics *Metric, key string) (string, error) {
 			return "", ErrGetMetricHash
 		}
 
-		hash = createMetricHash(fmt.Sprintf("%s:gauge:%f", metrics.ID, *metrics.Value), key)
+		hash = createMetricHash(fmt.Sprintf("%s:gauge:%f", metrics.Key(), *metrics.Value)+metrics.Precondition.String(), key)
 	} else if metrics.MType == CounterMetricName {
 		if metrics.Delta == nil {
 			return "", ErrGetMetricHash
 		}
 
-		hash = createMetricHash(fmt.Sprintf("%s:counter:%d", metrics.ID, *metrics.Delta), key)
+		hash = createMetricHash(fmt.Sprintf("%s:counter:%d", metrics.Key(), *metrics.Delta), key)
+	} else if metrics.MType == HistogramMetricName {
+		if metrics.Histogram == nil {
+			return "", ErrGetMetricHash
+		}
+
+		hash = createMetricHash(fmt.Sprintf("%s:histogram:%s", metrics.Key(), metrics.Histogram), key)
+	} else if metrics.MType == SummaryMetricName {
+		if metrics.Summary == nil {
+			return "", ErrGetMetricHash
+		}
+
+		hash = createMetricHash(fmt.Sprintf("%s:summary:%s", metrics.Key(), metrics.Summary), key)
+	} else if metrics.MType == RateMetricName {
+		if metrics.Value == nil {
+			return "", ErrGetMetricHash
+		}
+
+		hash = createMetricHash(fmt.Sprintf("%s:rate:%f", metrics.Key(), *metrics.Value), key)
 	} else {
 		return "", errors.New("unknown metric type: " + metrics.MType)
 	}
@@ -80,6 +125,10 @@ func (m *Metric) SetHash(key string) error {
 }
 
 func (m *Metric) CheckHash(key string) (bool, error) {
+	if m.Hash == nil {
+		return false, nil
+	}
+
 	hash, err := getHashOfMetric(m, key)
 	if err != nil {
 		return false, err
@@ -88,6 +137,36 @@ func (m *Metric) CheckHash(key string) (bool, error) {
 	return *m.Hash == hash, nil
 }
 
+// DeleteMetricHash returns hash of request to delete metric of mType by series key.
+func DeleteMetricHash(mType, seriesKey, key string) string {
+	return createMetricHash(fmt.Sprintf("%s:%s:delete", seriesKey, mType), key)
+}
+
+// DeleteMetricsHash returns hash of request to delete metrics selected by filter
+// (metric type, ID prefix, ID regexp and label matchers).
+func DeleteMetricsHash(mType, idPrefix, idRegexp, match, key string) string {
+	return createMetricHash(fmt.Sprintf("%q:%q:%q:%q:delete", mType, idPrefix, idRegexp, match), key)
+}
+
+const (
+	// ReplicateAction is the action of replica following primary by Replicate gRPC stream
+	ReplicateAction = "replicate"
+	// PromoteAction is the action of replica promotion to primary
+	PromoteAction = "promote"
+)
+
+// ReplicationHash returns hash of replication request with action (ReplicateAction or PromoteAction),
+// it is sent in HashHeader (metadata of gRPC).
+func ReplicationHash(action, key string) string {
+	return createMetricHash(fmt.Sprintf("%s:replication", action), key)
+}
+
+// FaultsHash returns hash of request which changes faults injected into storage of Server with key,
+// it is sent in HashHeader.
+func FaultsHash(key string) string {
+	return createMetricHash("faults:debug", key)
+}
+
 func readPublicCryptoKey(keyFilePath string) (*rsa.PublicKey, error) {
 	keyBytes, err := os.ReadFile(keyFilePath)
 	if err != nil {
@@ -127,6 +206,11 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 	}
 }
 
+// MarshalJSON writes duration as string read by UnmarshalJSON, e.g. "1.5s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
 type PublicKey struct {
 	*rsa.PublicKey
 }
@@ -159,6 +243,12 @@ type AgentConfig struct {
 	CryptoKey PublicKey `json:"crypto_key,omitempty"`
 
 	Key string
+
+	// Labels are attached to every metric sent by Agent (e.g. host), so metrics of different Agents do not collide
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Tenant is sent in TenantHeader, Server keeps metrics of every tenant apart
+	Tenant string `json:"tenant,omitempty"`
 }
 
 func readPrivateCryptoKey(keyFilePath string) (*rsa.PrivateKey, error) {
@@ -207,13 +297,62 @@ type ServerConfig struct {
 	StoreFile     string   `json:"store_file,omitempty"`
 	IsRestore     bool     `json:"restore,omitempty"`
 
+	StoreRetention int `json:"store_retention,omitempty"`
+	// StoreCompression is compression of STORE_FILE backup (none, gzip, zstd)
+	StoreCompression string `json:"store_compression,omitempty"`
+	// StoreEncryptionKey is hex encoded AES-256 key of STORE_FILE backup and write-ahead log (empty - not encrypted)
+	StoreEncryptionKey string `json:"store_encryption_key,omitempty"`
+	// StoreEncryptionMigrate allows to read unencrypted STORE_FILE backup and write-ahead log with StoreEncryptionKey
+	StoreEncryptionMigrate bool `json:"store_encryption_migrate,omitempty"`
+
+	// StoreBackend is used when DataBaseDSN is empty (memory or bolt)
+	StoreBackend string `json:"store_backend,omitempty"`
+	// StoreShards is the number of shards of memory storage
+	StoreShards int `json:"store_shards,omitempty"`
+
 	CryptoKey PrivateKey `json:"crypto_key,omitempty"`
 
 	DataBaseDSN string `json:"database_dsn,omitempty"`
+	// CacheSize is the number of metrics cached in front of database (0 - cache is off)
+	CacheSize int `json:"cache_size,omitempty"`
 
 	Key string
 
 	TrustedSubnet string `json:"trusted_subnet,omitempty"`
+
+	HistorySize int `json:"history_size,omitempty"`
+	// HistoryRollups are history tiers of database (`raw=retention,resolution=retention`, empty - history is off)
+	HistoryRollups string   `json:"history_rollups,omitempty"`
+	RollupInterval Duration `json:"rollup_interval,omitempty"`
+
+	WALCompactInterval Duration `json:"wal_compact_interval,omitempty"`
+
+	// MetricTTL is the default time to live of not updated metric (0 - metrics never expire)
+	MetricTTL Duration `json:"metric_ttl,omitempty"`
+	// MetricTTLRules overrides MetricTTL for metrics by ID pattern (`pattern=ttl,pattern=ttl`)
+	MetricTTLRules   string   `json:"metric_ttl_rules,omitempty"`
+	TTLCheckInterval Duration `json:"ttl_check_interval,omitempty"`
+
+	// RateWindow is the sliding window of counter rates (0 - rates are not tracked)
+	RateWindow Duration `json:"rate_window,omitempty"`
+	// CumulativeCounters are ID patterns of counters sent as total since Agent start (`pattern,pattern`)
+	CumulativeCounters string `json:"cumulative_counters,omitempty"`
+
+	// ReplicationAddress is the address of gRPC replication listener (empty - replicas connect to gRPC Address)
+	ReplicationAddress string `json:"replication_address,omitempty"`
+	// ReplicateFrom is the replication address of primary, server with it is a read-only replica
+	ReplicateFrom string `json:"replicate_from,omitempty"`
+	// ReplicationLogSize is the number of batches kept for lagging replicas
+	ReplicationLogSize int `json:"replication_log_size,omitempty"`
+
+	// TenantsFile is JSON list of tenants with own metrics, keys and limits (empty - the default tenant only)
+	TenantsFile string `json:"tenants_file,omitempty"`
+
+	// WatchBufferSize is the number of change events buffered for every watch subscription (0 - watch is off)
+	WatchBufferSize int `json:"watch_buffer_size,omitempty"`
+
+	// FaultInjection wraps storage with faults set by /debug/faults, it is for tests and chaos drills only
+	FaultInjection bool `json:"fault_injection,omitempty"`
 }
 
 func InitAgentEnvConfig(config *AgentConfig) *AgentConfig {
@@ -246,6 +385,16 @@ func InitAgentEnvConfig(config *AgentConfig) *AgentConfig {
 		}
 	}
 
+	if labelsStr, ok := os.LookupEnv("LABELS"); ok {
+		if labels, err := ParseLabels(labelsStr); err == nil {
+			config.Labels = labels
+		}
+	}
+
+	if tenant, ok := os.LookupEnv("TENANT"); ok {
+		config.Tenant = tenant
+	}
+
 	return config
 }
 
@@ -255,11 +404,14 @@ const (
 	agentReportUsage = "The time in seconds when Agent sent Metric to the Server."
 	agentKey         = "Static key (for educational purposes) for hash generation"
 	agentCKUsage     = "Asymmetric encryption publick key"
+	agentLabelsUsage = "Labels attached to every Metric in `name=value,name=value` format"
+	agentTenantUsage = "Tenant of Agent metrics (empty value is the default tenant)"
 )
 
 func InitAgentFlagConfig(config *AgentConfig) *AgentConfig {
 	flag.StringVar(&config.Address, "a", config.Address, agentAddrUsage)
 	flag.StringVar(&config.Key, "k", config.Key, agentKey)
+	flag.StringVar(&config.Tenant, "tenant", config.Tenant, agentTenantUsage)
 
 	flag.Func("p", agentPollUsage, func(s string) error {
 		pollInterval, err := time.ParseDuration(s)
@@ -295,6 +447,16 @@ func InitAgentFlagConfig(config *AgentConfig) *AgentConfig {
 		return nil
 	})
 
+	flag.Func("l", agentLabelsUsage, func(s string) error {
+		labels, err := ParseLabels(s)
+
+		if err == nil {
+			config.Labels = labels
+		}
+
+		return err
+	})
+
 	return config
 }
 
@@ -305,6 +467,36 @@ func InitServerEnvConfig(config *ServerConfig) *ServerConfig {
 		config.StoreFile = storeFile
 	}
 
+	if storeBackend, ok := os.LookupEnv("STORE_BACKEND"); ok {
+		config.StoreBackend = storeBackend
+	}
+
+	if storeShardsStr, ok := os.LookupEnv("STORE_SHARDS"); ok {
+		if storeShards, err := strconv.Atoi(storeShardsStr); err == nil {
+			config.StoreShards = storeShards
+		}
+	}
+
+	if storeRetentionStr, ok := os.LookupEnv("STORE_RETENTION"); ok {
+		if storeRetention, err := strconv.Atoi(storeRetentionStr); err == nil {
+			config.StoreRetention = storeRetention
+		}
+	}
+
+	if storeCompression, ok := os.LookupEnv("STORE_COMPRESSION"); ok {
+		config.StoreCompression = storeCompression
+	}
+
+	if storeEncryptionKey, ok := os.LookupEnv("STORE_ENCRYPTION_KEY"); ok {
+		config.StoreEncryptionKey = storeEncryptionKey
+	}
+
+	if storeEncryptionMigrateStr, ok := os.LookupEnv("STORE_ENCRYPTION_MIGRATE"); ok {
+		if storeEncryptionMigrate, err := strconv.ParseBool(storeEncryptionMigrateStr); err == nil {
+			config.StoreEncryptionMigrate = storeEncryptionMigrate
+		}
+	}
+
 	if isRestoreStr, ok := os.LookupEnv("RESTORE"); ok {
 		if isRestore, err := strconv.ParseBool(isRestoreStr); err == nil {
 			config.IsRestore = isRestore
@@ -329,6 +521,12 @@ func InitServerEnvConfig(config *ServerConfig) *ServerConfig {
 		config.DataBaseDSN = dataBaseDSN
 	}
 
+	if cacheSizeStr, ok := os.LookupEnv("CACHE_SIZE"); ok {
+		if cacheSize, err := strconv.Atoi(cacheSizeStr); err == nil {
+			config.CacheSize = cacheSize
+		}
+	}
+
 	if cryptoKeyPath, ok := os.LookupEnv("CRYPTO_KEY"); ok {
 		key, err := readPrivateCryptoKey(cryptoKeyPath)
 		if err == nil {
@@ -340,27 +538,144 @@ func InitServerEnvConfig(config *ServerConfig) *ServerConfig {
 		config.TrustedSubnet = trustedSubnet
 	}
 
+	if walCompactIntervalStr, ok := os.LookupEnv("WAL_COMPACT_INTERVAL"); ok {
+		if walCompactInterval, err := time.ParseDuration(walCompactIntervalStr); err == nil {
+			config.WALCompactInterval = Duration{walCompactInterval}
+		}
+	}
+
+	if historySizeStr, ok := os.LookupEnv("HISTORY_SIZE"); ok {
+		if historySize, err := strconv.Atoi(historySizeStr); err == nil {
+			config.HistorySize = historySize
+		}
+	}
+
+	if historyRollups, ok := os.LookupEnv("HISTORY_ROLLUPS"); ok {
+		config.HistoryRollups = historyRollups
+	}
+
+	if rollupIntervalStr, ok := os.LookupEnv("ROLLUP_INTERVAL"); ok {
+		if rollupInterval, err := time.ParseDuration(rollupIntervalStr); err == nil {
+			config.RollupInterval = Duration{rollupInterval}
+		}
+	}
+
+	if metricTTLStr, ok := os.LookupEnv("METRIC_TTL"); ok {
+		if metricTTL, err := time.ParseDuration(metricTTLStr); err == nil {
+			config.MetricTTL = Duration{metricTTL}
+		}
+	}
+
+	if metricTTLRules, ok := os.LookupEnv("METRIC_TTL_RULES"); ok {
+		config.MetricTTLRules = metricTTLRules
+	}
+
+	if ttlCheckIntervalStr, ok := os.LookupEnv("TTL_CHECK_INTERVAL"); ok {
+		if ttlCheckInterval, err := time.ParseDuration(ttlCheckIntervalStr); err == nil {
+			config.TTLCheckInterval = Duration{ttlCheckInterval}
+		}
+	}
+
+	if rateWindowStr, ok := os.LookupEnv("RATE_WINDOW"); ok {
+		if rateWindow, err := time.ParseDuration(rateWindowStr); err == nil {
+			config.RateWindow = Duration{rateWindow}
+		}
+	}
+
+	if cumulativeCounters, ok := os.LookupEnv("CUMULATIVE_COUNTERS"); ok {
+		config.CumulativeCounters = cumulativeCounters
+	}
+
+	if replicationAddress, ok := os.LookupEnv("REPLICATION_ADDRESS"); ok {
+		config.ReplicationAddress = replicationAddress
+	}
+
+	if replicateFrom, ok := os.LookupEnv("REPLICATE_FROM"); ok {
+		config.ReplicateFrom = replicateFrom
+	}
+
+	if replicationLogSizeStr, ok := os.LookupEnv("REPLICATION_LOG_SIZE"); ok {
+		if replicationLogSize, err := strconv.Atoi(replicationLogSizeStr); err == nil {
+			config.ReplicationLogSize = replicationLogSize
+		}
+	}
+
+	if tenantsFile, ok := os.LookupEnv("TENANTS_FILE"); ok {
+		config.TenantsFile = tenantsFile
+	}
+
+	if watchBufferSizeStr, ok := os.LookupEnv("WATCH_BUFFER_SIZE"); ok {
+		if watchBufferSize, err := strconv.Atoi(watchBufferSizeStr); err == nil {
+			config.WatchBufferSize = watchBufferSize
+		}
+	}
+
+	if faultInjectionStr, ok := os.LookupEnv("FAULT_INJECTION"); ok {
+		if faultInjection, err := strconv.ParseBool(faultInjectionStr); err == nil {
+			config.FaultInjection = faultInjection
+		}
+	}
+
 	return config
 }
 
 const (
 	aUsage  = "Address to listen on"
 	fUsage  = "The name of the file in which Server will store Metric (Empty name turn off storing Metric)"
+	sbUsage = "Storage backend used without database: `memory` (with `STORE_FILE` backup) or `bolt` (`STORE_FILE` is bolt database)"
+	ssUsage = "The number of independently locked shards of `memory` storage"
+	srUsage = "The number of timestamped snapshots of `STORE_FILE` kept for restore fallback (value 0 turns snapshots off)"
+	scUsage = "Compression of `STORE_FILE` backup: `none`, `gzip` or `zstd`"
+	seUsage = "Hex encoded 32 bytes AES-256-GCM key encrypting `STORE_FILE` backup and write-ahead log (empty value turns encryption off)"
+	smUsage = "Bool value. `true` - Server with `STORE_ENCRYPTION_KEY` restores unencrypted `STORE_FILE` backup and write-ahead log (migration to encryption)"
 	rUsage  = "Bool value. `true` - At startup Server will try to load data from `STORE_FILE`. `false` - Server will create new `STORE_FILE` file in startup."
 	iUsage  = "The time in seconds after which the current server readings are reset to disk \n (value 0 — makes the recording synchronous)."
 	kUsage  = "Static key (for educational purposes) for hash generation"
 	dUsage  = "Database address to connect server with (for exemple postgres://zzman:@localhost:5432/postgres)"
 	ckUsage = "Asymmetric encryption private key"
+	csUsage = "The number of Metrics cached in front of database (value 0 - default - turns cache off)"
 	tUsage  = ""
+	hsUsage = "The number of points kept in history of every Metric (value 0 turns history off)"
+	hrUsage = "Database history tiers, e.g. `raw=24h,1m=720h,1h=8760h` (empty value turns history off)"
+	riUsage = "The time between rollups of database history"
+	wUsage  = "The time after which write-ahead log is compacted into `STORE_FILE` (used when `STORE_INTERVAL` is 0)"
+	mtUsage = "The time after which not updated Metric is evicted (value 0 turns eviction off)"
+	mrUsage = "Comma separated TTL overrides by Metric ID pattern, e.g. `Heap*=1h,Poll*=10m`"
+	tcUsage = "The time between checks for expired Metrics"
+	rwUsage = "The sliding window of counter rates (value 0 turns rates off)"
+	ccUsage = "Comma separated ID patterns of counters sent as total since Agent start, e.g. `Poll*,Requests`"
+	raUsage = "Address of gRPC listener for replicas (empty value turns replication off unless Server is gRPC)"
+	rfUsage = "Replication address of primary Server to follow (Server is a read-only replica until promoted)"
+	rlUsage = "The number of update batches kept for lagging replicas"
+	tfUsage = "JSON file with list of tenants: name, key, max_series, max_batch_size (empty value keeps the default tenant only)"
+	wbUsage = "The number of change events buffered for every watch subscriber, slower subscriber is disconnected (value 0 turns watch off)"
+	fiUsage = "Bool value. `true` - Server injects storage faults set by `/debug/faults` (for tests and chaos drills only)"
 )
 
 func InitServerFlagConfig(config *ServerConfig) *ServerConfig {
 	flag.StringVar(&config.Address, "a", config.Address, aUsage)
 	flag.StringVar(&config.StoreFile, "f", config.StoreFile, fUsage)
 	flag.BoolVar(&config.IsRestore, "r", config.IsRestore, rUsage)
+	flag.IntVar(&config.StoreRetention, "store-retention", config.StoreRetention, srUsage)
+	flag.StringVar(&config.StoreCompression, "store-compression", config.StoreCompression, scUsage)
+	flag.StringVar(&config.StoreEncryptionKey, "store-encryption-key", config.StoreEncryptionKey, seUsage)
+	flag.BoolVar(&config.StoreEncryptionMigrate, "store-encryption-migrate", config.StoreEncryptionMigrate, smUsage)
+	flag.StringVar(&config.StoreBackend, "store-backend", config.StoreBackend, sbUsage)
+	flag.IntVar(&config.StoreShards, "store-shards", config.StoreShards, ssUsage)
 	flag.StringVar(&config.Key, "k", config.Key, kUsage)
 	flag.StringVar(&config.DataBaseDSN, "d", config.DataBaseDSN, dUsage)
+	flag.IntVar(&config.CacheSize, "cache-size", config.CacheSize, csUsage)
 	flag.StringVar(&config.TrustedSubnet, "t", config.TrustedSubnet, tUsage)
+	flag.IntVar(&config.HistorySize, "history-size", config.HistorySize, hsUsage)
+	flag.StringVar(&config.HistoryRollups, "history-rollups", config.HistoryRollups, hrUsage)
+	flag.StringVar(&config.MetricTTLRules, "metric-ttl-rules", config.MetricTTLRules, mrUsage)
+	flag.StringVar(&config.CumulativeCounters, "cumulative-counters", config.CumulativeCounters, ccUsage)
+	flag.StringVar(&config.ReplicationAddress, "replication-address", config.ReplicationAddress, raUsage)
+	flag.StringVar(&config.ReplicateFrom, "replicate-from", config.ReplicateFrom, rfUsage)
+	flag.IntVar(&config.ReplicationLogSize, "replication-log-size", config.ReplicationLogSize, rlUsage)
+	flag.StringVar(&config.TenantsFile, "tenants-file", config.TenantsFile, tfUsage)
+	flag.IntVar(&config.WatchBufferSize, "watch-buffer-size", config.WatchBufferSize, wbUsage)
+	flag.BoolVar(&config.FaultInjection, "fault-injection", config.FaultInjection, fiUsage)
 
 	flag.Func("crypto-key", agentCKUsage, func(cryptoKeyPath string) error {
 		if cryptoKeyPath == "" {
@@ -386,6 +701,56 @@ func InitServerFlagConfig(config *ServerConfig) *ServerConfig {
 		return err
 	})
 
+	flag.Func("wal-compact-interval", wUsage, func(s string) error {
+		walCompactInterval, err := time.ParseDuration(s)
+
+		if err == nil {
+			config.WALCompactInterval.Duration = walCompactInterval
+		}
+
+		return err
+	})
+
+	flag.Func("rollup-interval", riUsage, func(s string) error {
+		rollupInterval, err := time.ParseDuration(s)
+
+		if err == nil {
+			config.RollupInterval.Duration = rollupInterval
+		}
+
+		return err
+	})
+
+	flag.Func("metric-ttl", mtUsage, func(s string) error {
+		metricTTL, err := time.ParseDuration(s)
+
+		if err == nil {
+			config.MetricTTL.Duration = metricTTL
+		}
+
+		return err
+	})
+
+	flag.Func("ttl-check-interval", tcUsage, func(s string) error {
+		ttlCheckInterval, err := time.ParseDuration(s)
+
+		if err == nil {
+			config.TTLCheckInterval.Duration = ttlCheckInterval
+		}
+
+		return err
+	})
+
+	flag.Func("rate-window", rwUsage, func(s string) error {
+		rateWindow, err := time.ParseDuration(s)
+
+		if err == nil {
+			config.RateWindow.Duration = rateWindow
+		}
+
+		return err
+	})
+
 	return config
 }
 
@@ -448,3 +813,5 @@ const (
 )
 
 var ErrUnknownServiceType = errors.New("unknown service type")
+
+var ErrUnknownStoreBackend = errors.New("unknown store backend")
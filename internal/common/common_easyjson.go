@@ -7,6 +7,7 @@ import (
 	easyjson "github.com/mailru/easyjson"
 	jlexer "github.com/mailru/easyjson/jlexer"
 	jwriter "github.com/mailru/easyjson/jwriter"
+	time "time"
 )
 
 // suppress unused package warning
@@ -70,6 +71,66 @@ func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon(in *
 				}
 				*out.Hash = string(in.String())
 			}
+		case "labels":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				in.Delim('{')
+				if !in.IsDelim('}') {
+					out.Labels = make(map[string]string)
+				} else {
+					out.Labels = nil
+				}
+				for !in.IsDelim('}') {
+					key := string(in.String())
+					in.WantColon()
+					var v1 string
+					v1 = string(in.String())
+					(out.Labels)[key] = v1
+					in.WantComma()
+				}
+				in.Delim('}')
+			}
+		case "histogram":
+			if in.IsNull() {
+				in.Skip()
+				out.Histogram = nil
+			} else {
+				if out.Histogram == nil {
+					out.Histogram = new(Histogram)
+				}
+				easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon1(in, out.Histogram)
+			}
+		case "summary":
+			if in.IsNull() {
+				in.Skip()
+				out.Summary = nil
+			} else {
+				if out.Summary == nil {
+					out.Summary = new(Summary)
+				}
+				easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon2(in, out.Summary)
+			}
+		case "version":
+			if in.IsNull() {
+				in.Skip()
+				out.Version = nil
+			} else {
+				if out.Version == nil {
+					out.Version = new(uint64)
+				}
+				*out.Version = uint64(in.Uint64())
+			}
+		case "precondition":
+			if in.IsNull() {
+				in.Skip()
+				out.Precondition = nil
+			} else {
+				if out.Precondition == nil {
+					out.Precondition = new(Precondition)
+				}
+				easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon3(in, out.Precondition)
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -109,6 +170,45 @@ func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon(out
 		out.RawString(prefix)
 		out.String(string(*in.Hash))
 	}
+	if len(in.Labels) != 0 {
+		const prefix string = ",\"labels\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('{')
+			v2First := true
+			for v2Name, v2Value := range in.Labels {
+				if v2First {
+					v2First = false
+				} else {
+					out.RawByte(',')
+				}
+				out.String(string(v2Name))
+				out.RawByte(':')
+				out.String(string(v2Value))
+			}
+			out.RawByte('}')
+		}
+	}
+	if in.Histogram != nil {
+		const prefix string = ",\"histogram\":"
+		out.RawString(prefix)
+		easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon1(out, *in.Histogram)
+	}
+	if in.Summary != nil {
+		const prefix string = ",\"summary\":"
+		out.RawString(prefix)
+		easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon2(out, *in.Summary)
+	}
+	if in.Version != nil {
+		const prefix string = ",\"version\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(*in.Version))
+	}
+	if in.Precondition != nil {
+		const prefix string = ",\"precondition\":"
+		out.RawString(prefix)
+		easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon3(out, *in.Precondition)
+	}
 	out.RawByte('}')
 }
 
@@ -135,3 +235,370 @@ func (v *Metric) UnmarshalJSON(data []byte) error {
 func (v *Metric) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon(l, v)
 }
+func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon3(in *jlexer.Lexer, out *Precondition) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "value":
+			if in.IsNull() {
+				in.Skip()
+				out.Value = nil
+			} else {
+				if out.Value == nil {
+					out.Value = new(float64)
+				}
+				*out.Value = float64(in.Float64())
+			}
+		case "version":
+			if in.IsNull() {
+				in.Skip()
+				out.Version = nil
+			} else {
+				if out.Version == nil {
+					out.Version = new(uint64)
+				}
+				*out.Version = uint64(in.Uint64())
+			}
+		case "timestamp":
+			if in.IsNull() {
+				in.Skip()
+				out.Timestamp = nil
+			} else {
+				if out.Timestamp == nil {
+					out.Timestamp = new(time.Time)
+				}
+				if data := in.Raw(); in.Ok() {
+					in.AddError((*out.Timestamp).UnmarshalJSON(data))
+				}
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon3(out *jwriter.Writer, in Precondition) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.Value != nil {
+		const prefix string = ",\"value\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.Float64(float64(*in.Value))
+	}
+	if in.Version != nil {
+		const prefix string = ",\"version\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Uint64(uint64(*in.Version))
+	}
+	if in.Timestamp != nil {
+		const prefix string = ",\"timestamp\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Raw((*in.Timestamp).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon2(in *jlexer.Lexer, out *Summary) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "quantiles":
+			if in.IsNull() {
+				in.Skip()
+				out.Quantiles = nil
+			} else {
+				in.Delim('[')
+				if out.Quantiles == nil {
+					if !in.IsDelim(']') {
+						out.Quantiles = make([]SummaryQuantile, 0, 4)
+					} else {
+						out.Quantiles = []SummaryQuantile{}
+					}
+				} else {
+					out.Quantiles = (out.Quantiles)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v3 SummaryQuantile
+					easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon4(in, &v3)
+					out.Quantiles = append(out.Quantiles, v3)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "sum":
+			out.Sum = float64(in.Float64())
+		case "count":
+			out.Count = uint64(in.Uint64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon2(out *jwriter.Writer, in Summary) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"quantiles\":"
+		out.RawString(prefix[1:])
+		if in.Quantiles == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v4, v5 := range in.Quantiles {
+				if v4 > 0 {
+					out.RawByte(',')
+				}
+				easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon4(out, v5)
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Count))
+	}
+	out.RawByte('}')
+}
+func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon4(in *jlexer.Lexer, out *SummaryQuantile) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "quantile":
+			out.Quantile = float64(in.Float64())
+		case "value":
+			out.Value = float64(in.Float64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon4(out *jwriter.Writer, in SummaryQuantile) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"quantile\":"
+		out.RawString(prefix[1:])
+		out.Float64(float64(in.Quantile))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Value))
+	}
+	out.RawByte('}')
+}
+func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon1(in *jlexer.Lexer, out *Histogram) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "buckets":
+			if in.IsNull() {
+				in.Skip()
+				out.Buckets = nil
+			} else {
+				in.Delim('[')
+				if out.Buckets == nil {
+					if !in.IsDelim(']') {
+						out.Buckets = make([]HistogramBucket, 0, 4)
+					} else {
+						out.Buckets = []HistogramBucket{}
+					}
+				} else {
+					out.Buckets = (out.Buckets)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v6 HistogramBucket
+					easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon5(in, &v6)
+					out.Buckets = append(out.Buckets, v6)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "sum":
+			out.Sum = float64(in.Float64())
+		case "count":
+			out.Count = uint64(in.Uint64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon1(out *jwriter.Writer, in Histogram) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"buckets\":"
+		out.RawString(prefix[1:])
+		if in.Buckets == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v7, v8 := range in.Buckets {
+				if v7 > 0 {
+					out.RawByte(',')
+				}
+				easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon5(out, v8)
+			}
+			out.RawByte(']')
+		}
+	}
+	{
+		const prefix string = ",\"sum\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Sum))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Count))
+	}
+	out.RawByte('}')
+}
+func easyjsonC803d3e7DecodeGithubComGermanVorDevopsPetProjectInternalCommon5(in *jlexer.Lexer, out *HistogramBucket) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "le":
+			out.UpperBound = float64(in.Float64())
+		case "count":
+			out.Count = uint64(in.Uint64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjsonC803d3e7EncodeGithubComGermanVorDevopsPetProjectInternalCommon5(out *jwriter.Writer, in HistogramBucket) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"le\":"
+		out.RawString(prefix[1:])
+		out.Float64(float64(in.UpperBound))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Count))
+	}
+	out.RawByte('}')
+}
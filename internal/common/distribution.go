@@ -0,0 +1,250 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrInvalidHistogram = errors.New("invalid histogram")
+	ErrInvalidSummary   = errors.New("invalid summary")
+	// ErrInvalidMetric is returned for metric without ID or without value of its type
+	ErrInvalidMetric = errors.New("invalid metric")
+)
+
+// HistogramBucket counts observations less or equal to UpperBound.
+// Buckets are cumulative (like Prometheus buckets), observations greater
+// than the last UpperBound are counted only by Histogram.Count.
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Histogram is a distribution of observations. Agents send observations made since
+// the previous report (like counter delta), Server sums histograms with the same buckets.
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Sum     float64           `json:"sum"`
+	Count   uint64            `json:"count"`
+}
+
+// SummaryQuantile is φ-quantile (0 ≤ φ ≤ 1) of observations.
+type SummaryQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// Summary is a set of quantiles calculated by Agent. Quantiles can not be merged,
+// so Server keeps the last summary (like gauge value).
+type Summary struct {
+	Quantiles []SummaryQuantile `json:"quantiles"`
+	Sum       float64           `json:"sum"`
+	Count     uint64            `json:"count"`
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Validate checks that bucket bounds are finite and increasing and counts are cumulative.
+func (h *Histogram) Validate() error {
+	if !isFinite(h.Sum) {
+		return fmt.Errorf("%w: sum is not finite", ErrInvalidHistogram)
+	}
+
+	for i, bucket := range h.Buckets {
+		if !isFinite(bucket.UpperBound) {
+			return fmt.Errorf("%w: bucket bound is not finite", ErrInvalidHistogram)
+		}
+
+		if i > 0 {
+			prev := h.Buckets[i-1]
+
+			if bucket.UpperBound <= prev.UpperBound {
+				return fmt.Errorf("%w: bucket bounds are not increasing", ErrInvalidHistogram)
+			}
+			if bucket.Count < prev.Count {
+				return fmt.Errorf("%w: bucket counts are not cumulative", ErrInvalidHistogram)
+			}
+		}
+	}
+
+	if len(h.Buckets) != 0 && h.Count < h.Buckets[len(h.Buckets)-1].Count {
+		return fmt.Errorf("%w: count is less than bucket count", ErrInvalidHistogram)
+	}
+
+	return nil
+}
+
+func (h *Histogram) sameBuckets(histogram *Histogram) bool {
+	if len(h.Buckets) != len(histogram.Buckets) {
+		return false
+	}
+
+	for i, bucket := range h.Buckets {
+		if bucket.UpperBound != histogram.Buckets[i].UpperBound {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Copy returns deep copy of the histogram.
+func (h *Histogram) Copy() *Histogram {
+	histogram := *h
+	histogram.Buckets = append([]HistogramBucket(nil), h.Buckets...)
+
+	return &histogram
+}
+
+// Merge returns new histogram with observations of both histograms. If bucket bounds
+// differ (Agent changed buckets), observations of h are dropped and copy of delta is returned.
+func (h *Histogram) Merge(delta *Histogram) *Histogram {
+	if h == nil || !h.sameBuckets(delta) {
+		return delta.Copy()
+	}
+
+	histogram := h.Copy()
+	histogram.Sum += delta.Sum
+	histogram.Count += delta.Count
+
+	for i := range histogram.Buckets {
+		histogram.Buckets[i].Count += delta.Buckets[i].Count
+	}
+
+	return histogram
+}
+
+// Diff returns observations which Merge adds to h to get histogram. If bucket bounds
+// differ (or h is nil), Merge replaces h, so copy of histogram is returned.
+// Counts less than counts of h are treated as no new observations.
+func (h *Histogram) Diff(histogram *Histogram) *Histogram {
+	if h == nil || !h.sameBuckets(histogram) {
+		return histogram.Copy()
+	}
+
+	diff := histogram.Copy()
+	diff.Sum -= h.Sum
+	diff.Count = subCount(histogram.Count, h.Count)
+
+	for i := range diff.Buckets {
+		diff.Buckets[i].Count = subCount(histogram.Buckets[i].Count, h.Buckets[i].Count)
+	}
+
+	return diff
+}
+
+func subCount(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+
+	return a - b
+}
+
+// String returns text form of the histogram used for hash and html view.
+func (h *Histogram) String() string {
+	buckets := make([]string, len(h.Buckets))
+	for i, bucket := range h.Buckets {
+		buckets[i] = fmt.Sprintf("%f:%d", bucket.UpperBound, bucket.Count)
+	}
+
+	return fmt.Sprintf("sum=%f count=%d buckets=[%s]", h.Sum, h.Count, strings.Join(buckets, " "))
+}
+
+// Validate checks that quantiles are within [0, 1] and increasing.
+func (s *Summary) Validate() error {
+	if !isFinite(s.Sum) {
+		return fmt.Errorf("%w: sum is not finite", ErrInvalidSummary)
+	}
+
+	for i, quantile := range s.Quantiles {
+		if !(quantile.Quantile >= 0 && quantile.Quantile <= 1) {
+			return fmt.Errorf("%w: quantile is out of [0, 1]", ErrInvalidSummary)
+		}
+
+		if !isFinite(quantile.Value) {
+			return fmt.Errorf("%w: quantile value is not finite", ErrInvalidSummary)
+		}
+
+		if i > 0 && quantile.Quantile <= s.Quantiles[i-1].Quantile {
+			return fmt.Errorf("%w: quantiles are not increasing", ErrInvalidSummary)
+		}
+	}
+
+	return nil
+}
+
+// Copy returns deep copy of the summary.
+func (s *Summary) Copy() *Summary {
+	summary := *s
+	summary.Quantiles = append([]SummaryQuantile(nil), s.Quantiles...)
+
+	return &summary
+}
+
+// String returns text form of the summary used for hash and html view.
+func (s *Summary) String() string {
+	quantiles := make([]string, len(s.Quantiles))
+	for i, quantile := range s.Quantiles {
+		quantiles[i] = fmt.Sprintf("%f:%f", quantile.Quantile, quantile.Value)
+	}
+
+	return fmt.Sprintf("sum=%f count=%d quantiles=[%s]", s.Sum, s.Count, strings.Join(quantiles, " "))
+}
+
+// Validate checks ID (not empty, without characters of series key syntax), labels, precondition and value of the metric.
+func (m *Metric) Validate() error {
+	if m.ID == "" {
+		return fmt.Errorf("%w: id is missing", ErrInvalidMetric)
+	}
+
+	if strings.ContainsAny(m.ID, seriesKeyChars) {
+		return fmt.Errorf("%w: id %q contains one of %s", ErrInvalidMetric, m.ID, seriesKeyChars)
+	}
+
+	if err := ValidateLabels(m.Labels); err != nil {
+		return err
+	}
+
+	if err := m.validatePrecondition(); err != nil {
+		return err
+	}
+
+	switch m.MType {
+	case GaugeMetricName:
+		if m.Value == nil {
+			return fmt.Errorf("%w: gauge value is missing", ErrInvalidMetric)
+		}
+	case CounterMetricName:
+		if m.Delta == nil {
+			return fmt.Errorf("%w: counter delta is missing", ErrInvalidMetric)
+		}
+	case HistogramMetricName:
+		if m.Histogram == nil {
+			return fmt.Errorf("%w: value is missing", ErrInvalidHistogram)
+		}
+
+		return m.Histogram.Validate()
+	case SummaryMetricName:
+		if m.Summary == nil {
+			return fmt.Errorf("%w: value is missing", ErrInvalidSummary)
+		}
+
+		return m.Summary.Validate()
+	}
+
+	return nil
+}
+
+// IsValidationError reports if err is returned by Metric.Validate.
+func IsValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidMetric) ||
+		errors.Is(err, ErrInvalidLabel) ||
+		errors.Is(err, ErrInvalidHistogram) ||
+		errors.Is(err, ErrInvalidSummary) ||
+		errors.Is(err, ErrInvalidPrecondition)
+}
@@ -0,0 +1,95 @@
+package common_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/bmizerany/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricValidate(t *testing.T) {
+	value := float64(1)
+	metric := common.Metric{ID: "cpu", MType: common.GaugeMetricName, Labels: map[string]string{"host": "a"}, Value: &value}
+	require.NoError(t, metric.Validate())
+
+	// IDs with characters of series key would collide with labeled series, e.g. `cpu{host="a"}`
+	for _, id := range []string{"", `cpu{host="a"}`, "cpu{", "cpu}", `cpu"`, "cpu=a", "cpu,mem"} {
+		metric := common.Metric{ID: id, MType: common.GaugeMetricName, Value: &value}
+
+		err := metric.Validate()
+		require.ErrorIs(t, err, common.ErrInvalidMetric, id)
+		assert.Equal(t, true, common.IsValidationError(err))
+	}
+
+	metric = common.Metric{ID: "cpu", MType: common.GaugeMetricName, Labels: map[string]string{"host-name": "a"}, Value: &value}
+	require.ErrorIs(t, metric.Validate(), common.ErrInvalidLabel)
+
+	metric = common.Metric{ID: "PollCount", MType: common.CounterMetricName}
+	require.ErrorIs(t, metric.Validate(), common.ErrInvalidMetric)
+
+	metric = common.Metric{ID: "Latency", MType: common.HistogramMetricName}
+	require.ErrorIs(t, metric.Validate(), common.ErrInvalidHistogram)
+}
+
+func TestHistogram(t *testing.T) {
+	histogram := &common.Histogram{
+		Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 1}, {UpperBound: 2, Count: 3}},
+		Sum:     4,
+		Count:   4,
+	}
+	require.NoError(t, histogram.Validate())
+
+	invalid := []*common.Histogram{
+		{Sum: math.NaN()},
+		{Buckets: []common.HistogramBucket{{UpperBound: math.Inf(1)}}},
+		{Buckets: []common.HistogramBucket{{UpperBound: 2}, {UpperBound: 1}}, Count: 0},
+		{Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 2}, {UpperBound: 2, Count: 1}}, Count: 2},
+		{Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 2}}, Count: 1},
+	}
+	for _, h := range invalid {
+		require.ErrorIs(t, h.Validate(), common.ErrInvalidHistogram)
+	}
+
+	merged := histogram.Merge(histogram)
+	assert.Equal(t, uint64(8), merged.Count)
+	assert.Equal(t, float64(8), merged.Sum)
+	assert.Equal(t, uint64(6), merged.Buckets[1].Count)
+	// merge does not change histograms
+	assert.Equal(t, uint64(4), histogram.Count)
+
+	diff := histogram.Diff(merged)
+	assert.Equal(t, histogram, diff)
+
+	// other buckets replace the histogram
+	other := &common.Histogram{Buckets: []common.HistogramBucket{{UpperBound: 5, Count: 1}}, Sum: 5, Count: 1}
+	assert.Equal(t, other, histogram.Merge(other))
+	assert.Equal(t, other, histogram.Diff(other))
+
+	var empty *common.Histogram
+	assert.Equal(t, histogram, empty.Merge(histogram))
+}
+
+func TestSummary(t *testing.T) {
+	summary := &common.Summary{
+		Quantiles: []common.SummaryQuantile{{Quantile: 0.5, Value: 1}, {Quantile: 0.99, Value: 2}},
+		Sum:       10,
+		Count:     5,
+	}
+	require.NoError(t, summary.Validate())
+
+	copied := summary.Copy()
+	copied.Quantiles[0].Value = 3
+	assert.Equal(t, float64(1), summary.Quantiles[0].Value)
+
+	invalid := []*common.Summary{
+		{Sum: math.Inf(-1)},
+		{Quantiles: []common.SummaryQuantile{{Quantile: 1.5}}},
+		{Quantiles: []common.SummaryQuantile{{Quantile: 0.5, Value: math.NaN()}}},
+		{Quantiles: []common.SummaryQuantile{{Quantile: 0.9}, {Quantile: 0.5}}},
+	}
+	for _, s := range invalid {
+		require.ErrorIs(t, s.Validate(), common.ErrInvalidSummary)
+	}
+}
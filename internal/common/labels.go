@@ -0,0 +1,257 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidLabel     = errors.New("invalid label")
+	ErrInvalidSeriesKey = errors.New("invalid series key")
+	ErrInvalidMatcher   = errors.New("invalid label matcher")
+)
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// seriesKeyChars are characters of series key syntax, ID with them may collide with key of labeled series.
+const seriesKeyChars = `{}"=,`
+
+// ValidateLabels checks that every label name is `[a-zA-Z_][a-zA-Z0-9_]*`.
+func ValidateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNameRegexp.MatchString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidLabel, name)
+		}
+	}
+
+	return nil
+}
+
+// SeriesKey identifies metric series by ID and labels: `id{name="value",...}` with sorted label names.
+// Series without labels is identified by ID only.
+func SeriesKey(id string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return id
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(id)
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[name]))
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+// ParseSeriesKey splits key built by SeriesKey into ID and labels.
+func ParseSeriesKey(key string) (string, map[string]string, error) {
+	i := strings.IndexByte(key, '{')
+	if i < 0 {
+		return key, nil, nil
+	}
+
+	if !strings.HasSuffix(key, "}") {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+	}
+
+	labels := make(map[string]string)
+	body := key[i+1 : len(key)-1]
+
+	for body != "" {
+		name, rest, ok := strings.Cut(body, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+		}
+
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidSeriesKey, key)
+		}
+		value, _ := strconv.Unquote(quoted)
+
+		labels[strings.TrimSpace(name)] = value
+
+		body = strings.TrimPrefix(strings.TrimSpace(rest[len(quoted):]), ",")
+	}
+
+	if err := ValidateLabels(labels); err != nil {
+		return "", nil, err
+	}
+
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	return key[:i], labels, nil
+}
+
+// ParseLabels parses labels in `name=value,name=value` format (used by configs).
+func ParseLabels(str string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for _, labelStr := range strings.Split(str, ",") {
+		labelStr = strings.TrimSpace(labelStr)
+		if labelStr == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(labelStr, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLabel, labelStr)
+		}
+
+		labels[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return labels, ValidateLabels(labels)
+}
+
+// Key returns series key of the metric.
+func (m *Metric) Key() string {
+	return SeriesKey(m.ID, m.Labels)
+}
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// LabelMatcher selects series by label value. Missing label is matched as empty value.
+type LabelMatcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+
+	re *regexp.Regexp
+}
+
+func NewLabelMatcher(name string, matchType MatchType, value string) (*LabelMatcher, error) {
+	matcher := &LabelMatcher{
+		Name:  name,
+		Type:  matchType,
+		Value: value,
+	}
+
+	switch matchType {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, err)
+		}
+
+		matcher.re = re
+	default:
+		return nil, fmt.Errorf("%w: unknown match type %q", ErrInvalidMatcher, matchType)
+	}
+
+	return matcher, nil
+}
+
+func (m *LabelMatcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+
+	return false
+}
+
+func (m *LabelMatcher) String() string {
+	return m.Name + string(m.Type) + strconv.Quote(m.Value)
+}
+
+// ParseLabelMatchers parses selector like `{host="a",region=~"eu-.*",env!="test"}` (braces are optional).
+func ParseLabelMatchers(selector string) ([]*LabelMatcher, error) {
+	body := strings.TrimSpace(selector)
+	if strings.HasPrefix(body, "{") {
+		if !strings.HasSuffix(body, "}") {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, selector)
+		}
+
+		body = strings.TrimSpace(body[1 : len(body)-1])
+	}
+
+	matchers := make([]*LabelMatcher, 0)
+
+	for body != "" {
+		opIndex := strings.IndexAny(body, "=!")
+		if opIndex < 0 || opIndex+1 >= len(body) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, selector)
+		}
+
+		name := strings.TrimSpace(body[:opIndex])
+		if !labelNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, selector)
+		}
+
+		matchType := MatchType(body[opIndex : opIndex+1])
+		switch body[opIndex : opIndex+2] {
+		case "!=", "=~", "!~":
+			matchType = MatchType(body[opIndex : opIndex+2])
+		}
+
+		rest := strings.TrimSpace(body[opIndex+len(matchType):])
+
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, selector)
+		}
+		value, _ := strconv.Unquote(quoted)
+
+		matcher, err := NewLabelMatcher(name, matchType, value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+
+		body = strings.TrimSpace(rest[len(quoted):])
+		if body != "" {
+			if !strings.HasPrefix(body, ",") {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidMatcher, selector)
+			}
+
+			body = strings.TrimSpace(body[1:])
+		}
+	}
+
+	return matchers, nil
+}
+
+// MatchLabels reports if labels satisfy all matchers.
+func MatchLabels(matchers []*LabelMatcher, labels map[string]string) bool {
+	for _, matcher := range matchers {
+		if !matcher.Matches(labels[matcher.Name]) {
+			return false
+		}
+	}
+
+	return true
+}
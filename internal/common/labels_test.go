@@ -0,0 +1,65 @@
+package common_test
+
+import (
+	"testing"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/bmizerany/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesKey(t *testing.T) {
+	assert.Equal(t, "cpu", common.SeriesKey("cpu", nil))
+
+	key := common.SeriesKey("cpu", map[string]string{"host": "a", "core": `"0"`})
+	assert.Equal(t, `cpu{core="\"0\"",host="a"}`, key)
+
+	id, labels, err := common.ParseSeriesKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", id)
+	assert.Equal(t, map[string]string{"host": "a", "core": `"0"`}, labels)
+
+	id, labels, err = common.ParseSeriesKey("cpu")
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", id)
+	assert.Equal(t, map[string]string(nil), labels)
+
+	for _, key := range []string{`cpu{host="a"`, `cpu{host}`, `cpu{host=a}`, `cpu{1host="a"}`} {
+		_, _, err := common.ParseSeriesKey(key)
+		require.Error(t, err, key)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := common.ParseLabels(" host = a, region=eu ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "a", "region": "eu"}, labels)
+
+	_, err = common.ParseLabels("host")
+	require.ErrorIs(t, err, common.ErrInvalidLabel)
+
+	_, err = common.ParseLabels("host-name=a")
+	require.ErrorIs(t, err, common.ErrInvalidLabel)
+}
+
+func TestLabelMatchers(t *testing.T) {
+	matchers, err := common.ParseLabelMatchers(`{host="a", region=~"eu-.*", env!="test", zone!~"b"}`)
+	require.NoError(t, err)
+	assert.Equal(t, 4, len(matchers))
+	assert.Equal(t, `region=~"eu-.*"`, matchers[1].String())
+
+	assert.Equal(t, true, common.MatchLabels(matchers, map[string]string{"host": "a", "region": "eu-west"}))
+	assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "a", "region": "us-east"}))
+	assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "a", "region": "eu-west", "env": "test"}))
+	assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "a", "region": "eu-west", "zone": "b"}))
+
+	// missing label is matched as empty value
+	matchers, err = common.ParseLabelMatchers(`env=""`)
+	require.NoError(t, err)
+	assert.Equal(t, true, common.MatchLabels(matchers, nil))
+
+	for _, selector := range []string{`{host="a"`, `host=a`, `host="a" env="b"`, `1host="a"`, `host=~"("`} {
+		_, err := common.ParseLabelMatchers(selector)
+		require.ErrorIs(t, err, common.ErrInvalidMatcher, selector)
+	}
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidPrecondition = errors.New("invalid precondition")
+
+// Precondition makes update of gauge conditional, the update is rejected
+// if any of set fields does not match the stored gauge.
+type Precondition struct {
+	Value     *float64   `json:"value,omitempty"`     // ожидаемое текущее значение gauge
+	Version   *uint64    `json:"version,omitempty"`   // ожидаемая версия gauge, 0 если gauge еще не сохранен
+	Timestamp *time.Time `json:"timestamp,omitempty"` // время значения, обновление применяется только если оно новее сохраненного
+}
+
+// String returns text form of the precondition used for hash, empty for nil precondition.
+func (p *Precondition) String() string {
+	if p == nil {
+		return ""
+	}
+
+	str := ":if"
+	if p.Value != nil {
+		str += fmt.Sprintf(" value=%f", *p.Value)
+	}
+	if p.Version != nil {
+		str += fmt.Sprintf(" version=%d", *p.Version)
+	}
+	if p.Timestamp != nil {
+		str += " timestamp=" + p.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	return str
+}
+
+// validatePrecondition checks that only gauge updates are conditional.
+func (m *Metric) validatePrecondition() error {
+	if m.Precondition == nil {
+		return nil
+	}
+
+	if m.MType != GaugeMetricName {
+		return fmt.Errorf("%w: %s metric can not be updated conditionally", ErrInvalidPrecondition, m.MType)
+	}
+
+	if m.Precondition.Value != nil && !isFinite(*m.Precondition.Value) {
+		return fmt.Errorf("%w: value is not finite", ErrInvalidPrecondition)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	snapshotInfix      = ".snapshot-"
+	snapshotTimeLayout = "20060102T150405.000000000"
+)
+
+// BackupConfig describes where Storage backups are written.
+type BackupConfig struct {
+	FilePath string
+	// Retention is the number of timestamped snapshots kept next to FilePath (0 turns snapshots off)
+	Retention int
+	// Codec compresses and encrypts backups and write-ahead log (nil - plain JSON)
+	Codec *BackupCodec
+	// Metrics times snapshot and write of every backup (nil - backups are not timed)
+	Metrics *StorageMetrics
+}
+
+func snapshotFilePath(backupFilePath string, timestamp time.Time) string {
+	return backupFilePath + snapshotInfix + timestamp.UTC().Format(snapshotTimeLayout)
+}
+
+// listSnapshots returns paths of timestamped snapshots of backupFilePath, newest first.
+func listSnapshots(backupFilePath string) ([]string, error) {
+	paths, err := filepath.Glob(backupFilePath + snapshotInfix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	// fixed width time layout keeps lexicographical and chronological orders the same
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	return paths, nil
+}
+
+// hasBackup reports whether backup file or any of its snapshots exists.
+func hasBackup(backupFilePath string) bool {
+	if _, err := os.Stat(backupFilePath); !errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+
+	snapshots, _ := listSnapshots(backupFilePath)
+
+	return len(snapshots) != 0
+}
+
+// writeFileAtomic writes data to temporary file in the same directory, syncs it
+// and renames it to path, so path always contains either old or new data.
+func writeFileAtomic(path string, data []byte) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmpFile, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpFile.Name(), path); err != nil {
+		return err
+	}
+
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer dirFile.Close()
+
+	return dirFile.Sync()
+}
+
+// writeBackupFiles atomically writes backup file encoded by config codec and, if retention is on,
+// a timestamped snapshot removing the ones out of retention.
+func writeBackupFiles(config BackupConfig, data []byte) error {
+	data, err := config.Codec.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(config.FilePath, data); err != nil {
+		return err
+	}
+
+	if config.Retention <= 0 {
+		return nil
+	}
+
+	if err := writeFileAtomic(snapshotFilePath(config.FilePath, time.Now()), data); err != nil {
+		return err
+	}
+
+	snapshots, err := listSnapshots(config.FilePath)
+	if err != nil {
+		return err
+	}
+
+	for i := config.Retention; i < len(snapshots); i++ {
+		if err := os.Remove(snapshots[i]); err != nil {
+			log.Println("Could not remove old snapshot", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/jackc/pgx/v4"
+)
+
+// CollapseMetrics merges metrics of the same series like Storage does: gauges and summaries
+// keep the last value, counters are summed, histograms are merged. Result is sorted by type
+// and series key, so concurrent transactions lock rows in the same order.
+func CollapseMetrics(metricsList []common.Metric) []common.Metric {
+	indexes := make(map[string]int, len(metricsList))
+	metrics := make([]common.Metric, 0, len(metricsList))
+
+	for _, metric := range metricsList {
+		key := historyKey(metric.MType, metric.Key())
+
+		i, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(metrics)
+			metrics = append(metrics, metric)
+			continue
+		}
+
+		switch metric.MType {
+		case common.CounterMetricName:
+			delta := *metrics[i].Delta + *metric.Delta
+			metrics[i].Delta = &delta
+		case common.HistogramMetricName:
+			metrics[i].Histogram = metrics[i].Histogram.Merge(metric.Histogram)
+		default:
+			metrics[i] = metric
+		}
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].MType != metrics[j].MType {
+			return metrics[i].MType < metrics[j].MType
+		}
+
+		return metrics[i].Key() < metrics[j].Key()
+	})
+
+	return metrics
+}
+
+// mergeStoredHistograms locks saved histograms of metrics with one pipelined batch
+// and replaces histograms of metrics with merged ones.
+func mergeStoredHistograms(ctx context.Context, tx pgx.Tx, metrics []common.Metric) error {
+	batch := &pgx.Batch{}
+	indexes := make([]int, 0)
+
+	for i, metric := range metrics {
+		if metric.MType == common.HistogramMetricName {
+			batch.Queue(selectDataForUpdateSQL, metric.Key(), metric.MType)
+			indexes = append(indexes, i)
+		}
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for _, i := range indexes {
+		var data []byte
+		if err := results.QueryRow().Scan(&data); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		prev := &StorageMetric{}
+		if err := decodeData(common.HistogramMetricName, data, prev); err != nil {
+			return err
+		}
+
+		metrics[i].Histogram = prev.Histogram.Merge(metrics[i].Histogram)
+	}
+
+	return results.Close()
+}
+
+// queueUpsert queues statement saving valid metric, histogram should be merged with the saved one.
+func queueUpsert(batch *pgx.Batch, metric common.Metric) error {
+	switch metric.MType {
+	case common.GaugeMetricName:
+		batch.Queue(insertValueSQL, metric.Key(), metric.MType, *metric.Value)
+	case common.CounterMetricName:
+		batch.Queue(insertDeltaSQL, metric.Key(), metric.MType, *metric.Delta)
+	case common.HistogramMetricName:
+		data, err := json.Marshal(metric.Histogram)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(insertDataSQL, metric.Key(), metric.MType, data)
+	case common.SummaryMetricName:
+		data, err := json.Marshal(metric.Summary)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(insertDataSQL, metric.Key(), metric.MType, data)
+	default:
+		return newUnknownMetricTypeError(metric.MType)
+	}
+
+	return nil
+}
+
+// UpdateMetrics saves all metrics in a single transaction, nothing is saved if any of metrics is not valid.
+// Metrics of the same series are collapsed (see CollapseMetrics) and saved with pipelined batches:
+// one round trip to lock saved histograms (if there are any) and one for all the upserts.
+// Batch with preconditions is not collapsed and is saved sequentially, so every precondition
+// sees the gauge updated by the previous metrics of the batch.
+func (stor *StorageV2) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
+
+	if hasPreconditions(metricsList) {
+		return stor.UpdateMetricsSequential(ctx, metricsList)
+	}
+
+	metrics := CollapseMetrics(metricsList)
+
+	tx, err := stor.dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := mergeStoredHistograms(ctx, tx, metrics); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, metric := range metrics {
+		if err := queueUpsert(batch, metric); err != nil {
+			return err
+		}
+
+		if stor.rollups != nil {
+			batch.Queue(insertHistorySQL, metric.Key(), metric.MType)
+		}
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return err
+		}
+	}
+
+	if err := results.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
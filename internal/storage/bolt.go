@@ -0,0 +1,607 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// boltMetricTypes have buckets of metrics by series key named by metric type
+	boltMetricTypes = []string{
+		common.GaugeMetricName,
+		common.CounterMetricName,
+		common.HistogramMetricName,
+		common.SummaryMetricName,
+	}
+	boltCounterBucket   = []byte(common.CounterMetricName)
+	boltHistogramBucket = []byte(common.HistogramMetricName)
+	// boltHistoryBucket contains bucket of points for every metric,
+	// point key is big endian unix nano timestamp
+	boltHistoryBucket = []byte("history")
+	// boltUpdatedBucket keeps big endian unix nano time of the last update of every metric by historyKey
+	boltUpdatedBucket = []byte("updated")
+	// boltVersionBucket keeps big endian version and unix nano value timestamp (0 if not set) of gauges by historyKey,
+	// gauge saved before versioning has version 1
+	boltVersionBucket = []byte("versions")
+)
+
+// BoltStorage keeps metrics in embedded bbolt key-value database file.
+type BoltStorage struct {
+	db          *bolt.DB
+	historySize int
+}
+
+func encodeUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+
+	return b
+}
+
+func encodeGauge(value float64) []byte {
+	return encodeUint64(math.Float64bits(value))
+}
+
+func decodeGauge(b []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// boltGaugeState reads state of gauge with series key in tx.
+func boltGaugeState(tx *bolt.Tx, key string) gaugeState {
+	v := tx.Bucket([]byte(common.GaugeMetricName)).Get([]byte(key))
+	if v == nil {
+		return gaugeState{}
+	}
+
+	state := gaugeState{exists: true, value: decodeGauge(v), version: 1}
+
+	if meta := tx.Bucket(boltVersionBucket).Get([]byte(historyKey(common.GaugeMetricName, key))); meta != nil {
+		state.version = binary.BigEndian.Uint64(meta[:8])
+		if timestamp := int64(binary.BigEndian.Uint64(meta[8:])); timestamp != 0 {
+			state.timestamp = time.Unix(0, timestamp)
+		}
+	}
+
+	return state
+}
+
+func encodeGaugeState(state gaugeState) []byte {
+	var timestamp int64
+	if !state.timestamp.IsZero() {
+		timestamp = state.timestamp.UnixNano()
+	}
+
+	return append(encodeUint64(state.version), encodeUint64(uint64(timestamp))...)
+}
+
+func encodeCounter(delta int64) []byte {
+	return encodeUint64(uint64(delta))
+}
+
+func decodeCounter(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// decodeValue sets value of mType encoded by updateMetric to storageMetric,
+// histograms and summaries are kept as JSON.
+func decodeValue(mType string, b []byte, storageMetric *StorageMetric) error {
+	switch mType {
+	case common.GaugeMetricName:
+		storageMetric.Value = decodeGauge(b)
+	case common.CounterMetricName:
+		storageMetric.Delta = decodeCounter(b)
+	case common.HistogramMetricName:
+		storageMetric.Histogram = &common.Histogram{}
+		return json.Unmarshal(b, storageMetric.Histogram)
+	case common.SummaryMetricName:
+		storageMetric.Summary = &common.Summary{}
+		return json.Unmarshal(b, storageMetric.Summary)
+	}
+
+	return nil
+}
+
+func InitBolt(filePath string) (*BoltStorage, error) {
+	db, err := bolt.Open(filePath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltHistoryBucket, boltUpdatedBucket, boltVersionBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		for _, mType := range boltMetricTypes {
+			if _, err := tx.CreateBucketIfNotExists([]byte(mType)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Printf("Opened bolt database %s successfully\n", filePath)
+
+	return &BoltStorage{
+		db:          db,
+		historySize: DefaultHistorySize,
+	}, nil
+}
+
+// SetHistorySize changes the number of points kept for every metric,
+// non positive size turns history off.
+func (stor *BoltStorage) SetHistorySize(size int) {
+	stor.historySize = size
+}
+
+func (stor *BoltStorage) Close() {
+	if err := stor.db.Close(); err != nil {
+		log.Println("Could not close bolt database", err)
+	}
+}
+
+func (stor *BoltStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// ForEachMetrics passes through all metrics in database and call handler
+func (stor *BoltStorage) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
+	return stor.db.View(func(tx *bolt.Tx) error {
+		for _, mType := range boltMetricTypes {
+			err := tx.Bucket([]byte(mType)).ForEach(func(k, v []byte) error {
+				storageMetric := newStorageMetric(mType, string(k))
+				if err := decodeValue(mType, v, storageMetric); err != nil {
+					return err
+				}
+
+				handler(storageMetric)
+
+				return ctx.Err()
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (stor *BoltStorage) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	var storageMetric *StorageMetric
+
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		return nil, newUnknownMetricTypeError(mType)
+	}
+
+	err := stor.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(mType)).Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+
+		storageMetric = newStorageMetric(mType, id)
+		if mType == common.GaugeMetricName {
+			storageMetric.Version = boltGaugeState(tx, id).version
+		}
+
+		return decodeValue(mType, v, storageMetric)
+	})
+
+	return storageMetric, err
+}
+
+// pushHistory appends point to the metric history and drops the oldest points out of historySize.
+// Bucket sequence holds the number of points in history.
+func (stor *BoltStorage) pushHistory(tx *bolt.Tx, mType, id string, timestamp time.Time, v []byte) error {
+	if stor.historySize <= 0 {
+		return nil
+	}
+
+	b, err := tx.Bucket(boltHistoryBucket).CreateBucketIfNotExists([]byte(historyKey(mType, id)))
+	if err != nil {
+		return err
+	}
+
+	key := encodeUint64(uint64(timestamp.UnixNano()))
+	size := b.Sequence()
+
+	if b.Get(key) == nil {
+		size++
+	}
+
+	if err = b.Put(key, v); err != nil {
+		return err
+	}
+
+	c := b.Cursor()
+	for size > uint64(stor.historySize) {
+		if k, _ := c.First(); k == nil {
+			break
+		}
+
+		if err = c.Delete(); err != nil {
+			return err
+		}
+		size--
+	}
+
+	return b.SetSequence(size)
+}
+
+func (stor *BoltStorage) updateMetric(tx *bolt.Tx, metric common.Metric, timestamp time.Time) error {
+	var v []byte
+
+	key := metric.Key()
+
+	switch metric.MType {
+	case common.GaugeMetricName:
+		v = encodeGauge(*metric.Value)
+
+		state := boltGaugeState(tx, key).next(metric)
+		versionKey := []byte(historyKey(metric.MType, key))
+		if err := tx.Bucket(boltVersionBucket).Put(versionKey, encodeGaugeState(state)); err != nil {
+			return err
+		}
+	case common.CounterMetricName:
+		delta := *metric.Delta
+		if prev := tx.Bucket(boltCounterBucket).Get([]byte(key)); prev != nil {
+			delta += decodeCounter(prev)
+		}
+
+		v = encodeCounter(delta)
+	case common.HistogramMetricName:
+		histogram := metric.Histogram
+		if prev := tx.Bucket(boltHistogramBucket).Get([]byte(key)); prev != nil {
+			prevMetric := &StorageMetric{}
+			if err := decodeValue(metric.MType, prev, prevMetric); err != nil {
+				return err
+			}
+
+			histogram = prevMetric.Histogram.Merge(histogram)
+		}
+
+		var err error
+		if v, err = json.Marshal(histogram); err != nil {
+			return err
+		}
+	case common.SummaryMetricName:
+		var err error
+		if v, err = json.Marshal(metric.Summary); err != nil {
+			return err
+		}
+	default:
+		return newUnknownMetricTypeError(metric.MType)
+	}
+
+	if err := tx.Bucket([]byte(metric.MType)).Put([]byte(key), v); err != nil {
+		return err
+	}
+
+	updatedKey := []byte(historyKey(metric.MType, key))
+	if err := tx.Bucket(boltUpdatedBucket).Put(updatedKey, encodeUint64(uint64(timestamp.UnixNano()))); err != nil {
+		return err
+	}
+
+	return stor.pushHistory(tx, metric.MType, key, timestamp, v)
+}
+
+func (stor *BoltStorage) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+// UpdateMetrics saves all metrics in a single transaction,
+// nothing is saved if any of metrics is not valid or any of preconditions is not satisfied.
+func (stor *BoltStorage) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
+
+	timestamp := time.Now()
+
+	return stor.db.Update(func(tx *bolt.Tx) error {
+		if hasPreconditions(metricsList) {
+			err := checkPreconditions(metricsList, func(key string) gaugeState {
+				return boltGaugeState(tx, key)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, metric := range metricsList {
+			if err := stor.updateMetric(tx, metric, timestamp); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (stor *BoltStorage) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		return nil, newUnknownMetricTypeError(mType)
+	}
+
+	var points []*HistoryPoint
+
+	err := stor.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltHistoryBucket).Bucket([]byte(historyKey(mType, id)))
+		if b == nil {
+			return nil
+		}
+
+		points = make([]*HistoryPoint, 0)
+
+		c := b.Cursor()
+
+		var k, v []byte
+		if from.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(encodeUint64(uint64(from.UnixNano())))
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			point := &HistoryPoint{
+				Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(k))),
+			}
+
+			if !to.IsZero() && point.Timestamp.After(to) {
+				break
+			}
+
+			value := &StorageMetric{}
+			if err := decodeValue(mType, v, value); err != nil {
+				return err
+			}
+
+			point.Value = value.Value
+			point.Delta = value.Delta
+			point.Histogram = value.Histogram
+			point.Summary = value.Summary
+
+			points = append(points, point)
+		}
+
+		return nil
+	})
+
+	return Downsample(points, from, step), err
+}
+
+func (stor *BoltStorage) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted := make([]*StorageMetric, 0)
+
+	err := stor.db.Update(func(tx *bolt.Tx) error {
+		updatedBucket := tx.Bucket(boltUpdatedBucket)
+
+		for _, mType := range boltMetricTypes {
+			b := tx.Bucket([]byte(mType))
+
+			// keys are collected first, bucket must not be changed while iterating over it
+			expired := make([]*StorageMetric, 0)
+			err := b.ForEach(func(k, _ []byte) error {
+				storageMetric := newStorageMetric(mType, string(k))
+
+				var updatedAt time.Time
+				if v := updatedBucket.Get([]byte(historyKey(mType, string(k)))); v != nil {
+					updatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+				}
+
+				if policy.IsExpired(storageMetric.ID, updatedAt, now) {
+					expired = append(expired, storageMetric)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, storageMetric := range expired {
+				if _, err := deleteBoltMetric(tx, mType, storageMetric.Key()); err != nil {
+					return err
+				}
+
+				evicted = append(evicted, storageMetric)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return evicted, nil
+}
+
+// deleteBoltMetric deletes the metric with its update time and history, false if there is no such metric.
+func deleteBoltMetric(tx *bolt.Tx, mType, seriesKey string) (bool, error) {
+	b := tx.Bucket([]byte(mType))
+	if b.Get([]byte(seriesKey)) == nil {
+		return false, nil
+	}
+
+	key := []byte(historyKey(mType, seriesKey))
+
+	if err := b.Delete([]byte(seriesKey)); err != nil {
+		return false, err
+	}
+	if err := tx.Bucket(boltUpdatedBucket).Delete(key); err != nil {
+		return false, err
+	}
+	if err := tx.Bucket(boltVersionBucket).Delete(key); err != nil {
+		return false, err
+	}
+
+	historyBucket := tx.Bucket(boltHistoryBucket)
+	if historyBucket.Bucket(key) != nil {
+		if err := historyBucket.DeleteBucket(key); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (stor *BoltStorage) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		return false, newUnknownMetricTypeError(mType)
+	}
+
+	deleted := false
+
+	err := stor.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		deleted, err = deleteBoltMetric(tx, mType, id)
+
+		return err
+	})
+
+	return deleted, err
+}
+
+func (stor *BoltStorage) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted := make([]*StorageMetric, 0)
+
+	err := stor.db.Update(func(tx *bolt.Tx) error {
+		for _, mType := range boltMetricTypes {
+			if filter.MType != "" && mType != filter.MType {
+				continue
+			}
+
+			// keys are collected first, bucket must not be changed while iterating over it
+			selected := make([]*StorageMetric, 0)
+
+			prefix := []byte(filter.IDPrefix)
+			c := tx.Bucket([]byte(mType)).Cursor()
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				if storageMetric := newStorageMetric(mType, string(k)); filter.Match(storageMetric) {
+					selected = append(selected, storageMetric)
+				}
+			}
+
+			for _, storageMetric := range selected {
+				if _, err := deleteBoltMetric(tx, mType, storageMetric.Key()); err != nil {
+					return err
+				}
+			}
+
+			deleted = append(deleted, selected...)
+		}
+
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// ListMetrics reads buckets of metric types in listing order from the cursor position.
+func (stor *BoltStorage) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	after, hasCursor, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	mTypes := append([]string(nil), boltMetricTypes...)
+	sort.Strings(mTypes)
+
+	metrics := make([]*StorageMetric, 0)
+	full := func() bool {
+		return query.Limit > 0 && len(metrics) > query.Limit
+	}
+
+	err = stor.db.View(func(tx *bolt.Tx) error {
+		for _, mType := range mTypes {
+			if query.Filter.MType != "" && mType != query.Filter.MType {
+				continue
+			}
+			if hasCursor && mType < after.mType {
+				continue
+			}
+
+			seek := []byte(query.Filter.IDPrefix)
+			if hasCursor && mType == after.mType && after.key >= query.Filter.IDPrefix {
+				seek = []byte(after.key)
+			}
+
+			c := tx.Bucket([]byte(mType)).Cursor()
+			for k, v := c.Seek(seek); k != nil && !full(); k, v = c.Next() {
+				if !bytes.HasPrefix(k, []byte(query.Filter.IDPrefix)) {
+					break
+				}
+				if hasCursor && mType == after.mType && string(k) <= after.key {
+					continue
+				}
+
+				storageMetric := newStorageMetric(mType, string(k))
+				if !query.Filter.Match(storageMetric) {
+					continue
+				}
+				if err := decodeValue(mType, v, storageMetric); err != nil {
+					return err
+				}
+
+				metrics = append(metrics, storageMetric)
+
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			if full() {
+				break
+			}
+		}
+
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newMetricsPage(metrics, query.Limit), nil
+}
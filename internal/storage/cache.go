@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+// CacheStats is hit, miss and eviction statistics of CacheStorageWrapper.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+}
+
+type cacheEntry struct {
+	key    string
+	metric *StorageMetric
+}
+
+// CacheStorageWrapper keeps the least recently read metrics of the storage in memory.
+// Updated and evicted metrics are removed from the cache after they are saved to the storage.
+// Writes of other Servers to the same database are not seen, so the cache is turned on explicitly.
+type CacheStorageWrapper struct {
+	StorageInterface
+
+	capacity int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// version is changed by every invalidation, metric read from the storage
+	// is not cached if it could be changed while it was read
+	version uint64
+	stats   CacheStats
+}
+
+func WithCache(stor StorageInterface, capacity int) *CacheStorageWrapper {
+	return &CacheStorageWrapper{
+		StorageInterface: stor,
+		capacity:         capacity,
+		entries:          make(map[string]*list.Element),
+		lru:              list.New(),
+	}
+}
+
+// Stats returns statistics since the cache creation.
+func (stor *CacheStorageWrapper) Stats() CacheStats {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	stats := stor.stats
+	stats.Size = stor.lru.Len()
+	stats.Capacity = stor.capacity
+
+	return stats
+}
+
+func (stor *CacheStorageWrapper) get(key string) (*StorageMetric, uint64, bool) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	element, ok := stor.entries[key]
+	if !ok {
+		stor.stats.Misses++
+		return nil, stor.version, false
+	}
+
+	stor.stats.Hits++
+	stor.lru.MoveToFront(element)
+
+	return element.Value.(*cacheEntry).metric.Copy(), stor.version, true
+}
+
+func (stor *CacheStorageWrapper) put(key string, metric *StorageMetric, version uint64) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	if version != stor.version || stor.capacity <= 0 {
+		return
+	}
+
+	if element, ok := stor.entries[key]; ok {
+		element.Value.(*cacheEntry).metric = metric.Copy()
+		stor.lru.MoveToFront(element)
+		return
+	}
+
+	stor.entries[key] = stor.lru.PushFront(&cacheEntry{key: key, metric: metric.Copy()})
+
+	for stor.lru.Len() > stor.capacity {
+		oldest := stor.lru.Back()
+		stor.lru.Remove(oldest)
+		delete(stor.entries, oldest.Value.(*cacheEntry).key)
+		stor.stats.Evictions++
+	}
+}
+
+func (stor *CacheStorageWrapper) invalidate(keys []string) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	stor.version++
+
+	for _, key := range keys {
+		if element, ok := stor.entries[key]; ok {
+			stor.lru.Remove(element)
+			delete(stor.entries, key)
+		}
+	}
+}
+
+// GetMetric returns deep copy of cached metric or reads it from the storage.
+// Missing metrics are not cached.
+func (stor *CacheStorageWrapper) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	key := historyKey(mType, id)
+
+	metric, version, ok := stor.get(key)
+	if ok {
+		return metric, nil
+	}
+
+	metric, err := stor.StorageInterface.GetMetric(ctx, mType, id)
+	if err != nil || metric == nil {
+		return metric, err
+	}
+
+	stor.put(key, metric, version)
+
+	return metric, nil
+}
+
+func (stor *CacheStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+func (stor *CacheStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	keys := make([]string, len(metricsList))
+	for i, metric := range metricsList {
+		keys[i] = historyKey(metric.MType, metric.Key())
+	}
+
+	// failed update may be partially saved
+	defer stor.invalidate(keys)
+
+	return stor.StorageInterface.UpdateMetrics(ctx, metricsList)
+}
+
+func (stor *CacheStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+
+	keys := make([]string, len(evicted))
+	for i, sm := range evicted {
+		keys[i] = historyKey(sm.MType, sm.Key())
+	}
+	stor.invalidate(keys)
+
+	return evicted, err
+}
+
+func (stor *CacheStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	defer stor.invalidate([]string{historyKey(mType, id)})
+
+	return stor.StorageInterface.DeleteMetric(ctx, mType, id)
+}
+
+func (stor *CacheStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+
+	keys := make([]string, len(deleted))
+	for i, sm := range deleted {
+		keys[i] = historyKey(sm.MType, sm.Key())
+	}
+	stor.invalidate(keys)
+
+	return deleted, err
+}
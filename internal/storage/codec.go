@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	ErrUnknownBackupFormat = errors.New("unknown backup format")
+	// ErrBackupKeyRequired is returned for encrypted backup read without encryption key
+	ErrBackupKeyRequired = errors.New("backup is encrypted, store encryption key is required")
+	// ErrBackupDecrypt is returned for backup encrypted with another key or changed after encryption
+	ErrBackupDecrypt = errors.New("backup can not be decrypted with store encryption key")
+	// ErrBackupNotEncrypted is returned for unencrypted backup read with encryption key unless AllowUnencrypted is called
+	ErrBackupNotEncrypted = errors.New("backup is not encrypted, store encryption migration is required to read it")
+)
+
+type BackupCompression string
+
+const (
+	BackupCompressionNone BackupCompression = "none"
+	BackupCompressionGzip BackupCompression = "gzip"
+	BackupCompressionZstd BackupCompression = "zstd"
+)
+
+// backupMagic starts encoded backup, backup without it is plain JSON of BackupObject.
+const backupMagic = "DMBK"
+
+// backupHeaderSize is the size of header: backupMagic, format version, compression and encryption ids.
+// Header is authenticated by encryption, so it can not be changed without decryption error.
+const backupHeaderSize = len(backupMagic) + 3
+
+const (
+	backupFormatVersion byte = 1
+
+	compressionNoneID byte = 0
+	compressionGzipID byte = 1
+	compressionZstdID byte = 2
+
+	encryptionNoneID   byte = 0
+	encryptionAESGCMID byte = 1
+)
+
+// BackupCodec compresses and encrypts backup files and write-ahead log records.
+// Nil codec writes plain JSON, codec without the key reads plain and compressed backups,
+// codec with the key reads only encrypted backups (unencrypted ones too after AllowUnencrypted).
+type BackupCodec struct {
+	compression BackupCompression
+	// aead is nil if backups are not encrypted
+	aead cipher.AEAD
+	// allowUnencrypted makes codec with the key read unencrypted backups
+	allowUnencrypted bool
+}
+
+// NewBackupCodec builds codec with compression (none, gzip, zstd, empty is none) and AES-256-GCM encryption
+// with hex encoded 32 bytes key (empty key turns encryption off).
+func NewBackupCodec(compression string, encryptionKey string) (*BackupCodec, error) {
+	codec := &BackupCodec{compression: BackupCompression(compression)}
+
+	switch codec.compression {
+	case "":
+		codec.compression = BackupCompressionNone
+	case BackupCompressionNone, BackupCompressionGzip, BackupCompressionZstd:
+	default:
+		return nil, fmt.Errorf("unknown backup compression %q", compression)
+	}
+
+	if encryptionKey == "" {
+		return codec, nil
+	}
+
+	key, err := hex.DecodeString(encryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("store encryption key should be 32 bytes in hex (64 characters)")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	return codec, nil
+}
+
+// AllowUnencrypted makes codec with the key read unencrypted backups and write-ahead log records,
+// so backups written before the key was set are migrated (they are written encrypted).
+func (codec *BackupCodec) AllowUnencrypted() {
+	codec.allowUnencrypted = true
+}
+
+// checkUnencrypted returns ErrBackupNotEncrypted if codec with the key does not read unencrypted content.
+func (codec *BackupCodec) checkUnencrypted() error {
+	if codec.Encrypted() && !codec.allowUnencrypted {
+		return ErrBackupNotEncrypted
+	}
+
+	return nil
+}
+
+// Encrypted reports if codec encrypts backups.
+func (codec *BackupCodec) Encrypted() bool {
+	return codec != nil && codec.aead != nil
+}
+
+func (codec *BackupCodec) compressionID() byte {
+	if codec == nil {
+		return compressionNoneID
+	}
+
+	switch codec.compression {
+	case BackupCompressionGzip:
+		return compressionGzipID
+	case BackupCompressionZstd:
+		return compressionZstdID
+	default:
+		return compressionNoneID
+	}
+}
+
+func compress(id byte, data []byte) ([]byte, error) {
+	switch id {
+	case compressionGzipID:
+		buf := &bytes.Buffer{}
+		writer := gzip.NewWriter(buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case compressionZstdID:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+
+		return encoder.EncodeAll(data, nil), nil
+	default:
+		return data, nil
+	}
+}
+
+func decompress(id byte, data []byte) ([]byte, error) {
+	switch id {
+	case compressionNoneID:
+		return data, nil
+	case compressionGzipID:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+	case compressionZstdID:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+
+		return decoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("%w: compression %d", ErrUnknownBackupFormat, id)
+	}
+}
+
+// seal encrypts data with random nonce put before the ciphertext, additionalData is authenticated.
+func (codec *BackupCodec) seal(data, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, codec.aead.NonceSize(), codec.aead.NonceSize()+len(data)+codec.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return codec.aead.Seal(nonce, nonce, data, additionalData), nil
+}
+
+func (codec *BackupCodec) open(data, additionalData []byte) ([]byte, error) {
+	if !codec.Encrypted() {
+		return nil, ErrBackupKeyRequired
+	}
+
+	if len(data) < codec.aead.NonceSize() {
+		return nil, ErrBackupDecrypt
+	}
+
+	nonce, ciphertext := data[:codec.aead.NonceSize()], data[codec.aead.NonceSize():]
+
+	plain, err := codec.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrBackupDecrypt
+	}
+
+	return plain, nil
+}
+
+// Encode returns backup file content of JSON data: header, then compressed and encrypted data.
+// Nil codec returns data as is.
+func (codec *BackupCodec) Encode(data []byte) ([]byte, error) {
+	if codec == nil {
+		return data, nil
+	}
+
+	header := []byte(backupMagic)
+	header = append(header, backupFormatVersion, codec.compressionID(), encryptionNoneID)
+	if codec.Encrypted() {
+		header[backupHeaderSize-1] = encryptionAESGCMID
+	}
+
+	payload, err := compress(codec.compressionID(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec.Encrypted() {
+		if payload, err = codec.seal(payload, header); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(header, payload...), nil
+}
+
+// Decode returns JSON data of backup file content detecting its format by header.
+func (codec *BackupCodec) Decode(content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(content, []byte(backupMagic)) {
+		if err := codec.checkUnencrypted(); err != nil {
+			return nil, err
+		}
+
+		return content, nil
+	}
+
+	if len(content) < backupHeaderSize {
+		return nil, fmt.Errorf("%w: short header", ErrUnknownBackupFormat)
+	}
+
+	header, payload := content[:backupHeaderSize], content[backupHeaderSize:]
+	version, compressionID, encryptionID := header[len(backupMagic)], header[len(backupMagic)+1], header[len(backupMagic)+2]
+
+	if version != backupFormatVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrUnknownBackupFormat, version)
+	}
+
+	switch encryptionID {
+	case encryptionNoneID:
+		if err := codec.checkUnencrypted(); err != nil {
+			return nil, err
+		}
+	case encryptionAESGCMID:
+		var err error
+		if payload, err = codec.open(payload, header); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: encryption %d", ErrUnknownBackupFormat, encryptionID)
+	}
+
+	return decompress(compressionID, payload)
+}
+
+// walRecordPrefix starts encrypted write-ahead log record, plain record is JSON object.
+// Encrypted record is the prefix, record sequence number, walSequenceSeparator and base64 of sealed JSON.
+const (
+	walRecordPrefix      = "!"
+	walSequenceSeparator = ':'
+)
+
+// recordHeader is the beginning of encrypted record line, it is authenticated by the encryption,
+// so the record can not be moved to another place of the log.
+func recordHeader(sequence uint64) []byte {
+	header := strconv.AppendUint([]byte(walRecordPrefix), sequence, 10)
+
+	return append(header, walSequenceSeparator)
+}
+
+// EncodeRecord returns write-ahead log line of JSON record with the sequence number without line break.
+// Records are encrypted only, every record is too small to be compressed.
+func (codec *BackupCodec) EncodeRecord(record []byte, sequence uint64) ([]byte, error) {
+	if !codec.Encrypted() {
+		return record, nil
+	}
+
+	header := recordHeader(sequence)
+
+	sealed, err := codec.seal(record, header)
+	if err != nil {
+		return nil, err
+	}
+
+	line := make([]byte, len(header)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(line, header)
+	base64.StdEncoding.Encode(line[len(header):], sealed)
+
+	return line, nil
+}
+
+// DecodeRecord returns JSON record of write-ahead log line without line break and its sequence number
+// authenticated by the encryption (0 for unencrypted record).
+func (codec *BackupCodec) DecodeRecord(line []byte) ([]byte, uint64, error) {
+	if !bytes.HasPrefix(line, []byte(walRecordPrefix)) {
+		if err := codec.checkUnencrypted(); err != nil {
+			return nil, 0, err
+		}
+
+		return line, 0, nil
+	}
+
+	separator := bytes.IndexByte(line, walSequenceSeparator)
+	if separator < 0 {
+		return nil, 0, fmt.Errorf("%w: record without sequence number", ErrUnknownBackupFormat)
+	}
+
+	sequence, err := strconv.ParseUint(string(line[len(walRecordPrefix):separator]), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUnknownBackupFormat, err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(line[separator+1:]))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUnknownBackupFormat, err)
+	}
+
+	record, err := codec.open(sealed, line[:separator+1])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return record, sequence, nil
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+const (
+	// DumpFormat identifies dump files
+	DumpFormat = "devops-metrics-dump"
+	// DumpVersion is the version of dump format written by Dump
+	DumpVersion = 1
+	// restoreBatchSize is the number of metrics saved by Restore at once
+	restoreBatchSize = 1000
+)
+
+var ErrDumpFormat = errors.New("not a metrics dump")
+
+// DumpHeader is the first line of dump file, every next line is a Metric.
+type DumpHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RestoreMode string
+
+const (
+	// RestoreMerge adds counters and histograms of dump to the stored ones, gauges and summaries are overwritten
+	RestoreMerge RestoreMode = "merge"
+	// RestoreOverwrite sets every metric to its value in dump, restoring the same dump twice changes nothing
+	RestoreOverwrite RestoreMode = "overwrite"
+)
+
+func ParseRestoreMode(str string) (RestoreMode, error) {
+	switch mode := RestoreMode(str); mode {
+	case RestoreMerge, RestoreOverwrite:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown restore mode %q", str)
+	}
+}
+
+// dumpMetric converts stored metric to Metric of dump.
+func dumpMetric(sm *StorageMetric) common.Metric {
+	metric := common.Metric{
+		ID:     sm.ID,
+		MType:  sm.MType,
+		Labels: sm.Labels,
+	}
+
+	switch sm.MType {
+	case common.GaugeMetricName:
+		value := sm.Value
+		metric.Value = &value
+	case common.CounterMetricName:
+		delta := sm.Delta
+		metric.Delta = &delta
+	case common.HistogramMetricName:
+		metric.Histogram = sm.Histogram
+	case common.SummaryMetricName:
+		metric.Summary = sm.Summary
+	}
+
+	return metric
+}
+
+// Dump writes all the metrics of stor to w as JSON lines after DumpHeader and returns the number of metrics.
+// Derived metrics (rate) are not dumped.
+func Dump(ctx context.Context, stor StorageInterface, w io.Writer) (int, error) {
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+
+	err := encoder.Encode(DumpHeader{
+		Format:    DumpFormat,
+		Version:   DumpVersion,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var encodeErr error
+
+	err = stor.ForEachMetrics(ctx, func(sm *StorageMetric) {
+		if encodeErr != nil || sm.MType == common.RateMetricName {
+			return
+		}
+
+		metric := dumpMetric(sm)
+		if encodeErr = encoder.Encode(&metric); encodeErr == nil {
+			count++
+		}
+	})
+	if err != nil {
+		return count, err
+	}
+	if encodeErr != nil {
+		return count, encodeErr
+	}
+
+	return count, writer.Flush()
+}
+
+// restoreBatch saves metrics of dump to stor.
+func restoreBatch(ctx context.Context, stor StorageInterface, metrics []common.Metric, mode RestoreMode) error {
+	if mode == RestoreOverwrite {
+		for i, metric := range metrics {
+			local, err := stor.GetMetric(ctx, metric.MType, metric.Key())
+			if err != nil {
+				return err
+			}
+
+			dumped := &StorageMetric{
+				ID:        metric.ID,
+				MType:     metric.MType,
+				Labels:    metric.Labels,
+				Histogram: metric.Histogram,
+				Summary:   metric.Summary,
+			}
+			if metric.Delta != nil {
+				dumped.Delta = *metric.Delta
+			}
+			if metric.Value != nil {
+				dumped.Value = *metric.Value
+			}
+
+			metrics[i] = snapshotDelta(local, dumped)
+		}
+	}
+
+	return stor.UpdateMetrics(ctx, CollapseMetrics(metrics))
+}
+
+// Restore reads dump written by Dump from r, saves its metrics to stor by mode and returns the number of metrics.
+func Restore(ctx context.Context, stor StorageInterface, r io.Reader, mode RestoreMode) (int, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	header := DumpHeader{}
+	if err := decoder.Decode(&header); err != nil || header.Format != DumpFormat {
+		return 0, ErrDumpFormat
+	}
+
+	if header.Version > DumpVersion {
+		return 0, fmt.Errorf("%w: version %d is not supported", ErrDumpFormat, header.Version)
+	}
+
+	count := 0
+	batch := make([]common.Metric, 0, restoreBatchSize)
+
+	for {
+		metric := common.Metric{}
+
+		err := decoder.Decode(&metric)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("%w: metric %d: %v", ErrDumpFormat, count+len(batch)+1, err)
+		}
+
+		batch = append(batch, metric)
+
+		if len(batch) == restoreBatchSize {
+			if err := restoreBatch(ctx, stor, batch, mode); err != nil {
+				return count, err
+			}
+
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) != 0 {
+		if err := restoreBatch(ctx, stor, batch, mode); err != nil {
+			return count, err
+		}
+
+		count += len(batch)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+var (
+	// ErrInjectedFault is returned by FaultStorageWrapper instead of the storage result
+	ErrInjectedFault = errors.New("injected storage fault")
+	// ErrInjectedTimeout is returned by FaultStorageWrapper for operation which hung for FaultConfig.Timeout
+	ErrInjectedTimeout = errors.New("injected storage timeout")
+)
+
+// DefaultFaultTimeout is the time injected timeout hangs if FaultConfig.Timeout is not set.
+const DefaultFaultTimeout = 10 * time.Second
+
+// FaultConfig describes faults injected into storage calls. Zero config injects nothing.
+// Rates are probabilities from 0 to 1 checked for every call.
+type FaultConfig struct {
+	// Methods are names of StorageInterface methods with faults (empty - all methods)
+	Methods []string `json:"methods,omitempty"`
+	// Latency is added before every call, LatencyJitter adds random time from 0 to it
+	Latency       common.Duration `json:"latency"`
+	LatencyJitter common.Duration `json:"latency_jitter"`
+	// ErrorRate calls fail with ErrInjectedFault without reaching the storage
+	ErrorRate float64 `json:"error_rate"`
+	// TimeoutRate calls hang for Timeout (or until the request is canceled) and fail with ErrInjectedTimeout
+	TimeoutRate float64         `json:"timeout_rate"`
+	Timeout     common.Duration `json:"timeout"`
+	// PartialBatchRate batches of UpdateMetrics save a part of metrics and fail with ErrInjectedFault
+	PartialBatchRate float64 `json:"partial_batch_rate"`
+	// Seed makes faults repeatable (0 - random seed)
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// Validate checks rates, durations and method names of the config.
+func (config *FaultConfig) Validate() error {
+	for _, method := range config.Methods {
+		if _, ok := storageMethods[method]; !ok {
+			return fmt.Errorf("unknown storage method %q", method)
+		}
+	}
+
+	for name, rate := range map[string]float64{
+		"error_rate":         config.ErrorRate,
+		"timeout_rate":       config.TimeoutRate,
+		"partial_batch_rate": config.PartialBatchRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s should be from 0 to 1", name)
+		}
+	}
+
+	if config.Latency.Duration < 0 || config.LatencyJitter.Duration < 0 || config.Timeout.Duration < 0 {
+		return errors.New("latency, latency_jitter and timeout should not be negative")
+	}
+
+	return nil
+}
+
+// FaultStats is the number of faults injected since the config was set.
+type FaultStats struct {
+	Calls          uint64 `json:"calls"`
+	Delayed        uint64 `json:"delayed"`
+	Errors         uint64 `json:"errors"`
+	Timeouts       uint64 `json:"timeouts"`
+	PartialBatches uint64 `json:"partial_batches"`
+}
+
+// FaultState is the current config and statistics of FaultStorageWrapper.
+type FaultState struct {
+	Config FaultConfig `json:"config"`
+	Stats  FaultStats  `json:"stats"`
+}
+
+// FaultStorageWrapper injects latency, errors, timeouts and partial batch failures into storage calls
+// to test how server and agents cope with slow and failing storage. Faults are changed at runtime by SetConfig.
+type FaultStorageWrapper struct {
+	StorageInterface
+
+	mutex   sync.Mutex
+	config  FaultConfig
+	methods map[string]struct{}
+	rnd     *rand.Rand
+	stats   FaultStats
+}
+
+// WithFaults wraps stor with fault injection, no faults are injected until SetConfig.
+func WithFaults(stor StorageInterface) *FaultStorageWrapper {
+	return &FaultStorageWrapper{
+		StorageInterface: stor,
+		rnd:              rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetConfig replaces injected faults and resets statistics.
+func (stor *FaultStorageWrapper) SetConfig(config FaultConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	methods := make(map[string]struct{}, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[method] = struct{}{}
+	}
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	stor.config = config
+	stor.methods = methods
+	stor.rnd = rand.New(rand.NewSource(seed))
+	stor.stats = FaultStats{}
+
+	return nil
+}
+
+// State returns the current config and statistics.
+func (stor *FaultStorageWrapper) State() FaultState {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	return FaultState{Config: stor.config, Stats: stor.stats}
+}
+
+// fault is the faults chosen for a call.
+type fault struct {
+	delay   time.Duration
+	err     bool
+	timeout time.Duration
+	// partial is the number of batch metrics saved before failure, -1 if batch does not fail
+	partial int
+}
+
+// nextFault chooses faults of method call, batchSize is the number of metrics of UpdateMetrics.
+func (stor *FaultStorageWrapper) nextFault(method string, batchSize int) (fault, bool) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	f := fault{partial: -1}
+
+	if len(stor.methods) != 0 {
+		if _, ok := stor.methods[method]; !ok {
+			return f, false
+		}
+	}
+
+	config := stor.config
+	stor.stats.Calls++
+
+	f.delay = config.Latency.Duration
+	if config.LatencyJitter.Duration > 0 {
+		f.delay += time.Duration(stor.rnd.Int63n(int64(config.LatencyJitter.Duration) + 1))
+	}
+	if f.delay > 0 {
+		stor.stats.Delayed++
+	}
+
+	switch {
+	case config.TimeoutRate > 0 && stor.rnd.Float64() < config.TimeoutRate:
+		f.timeout = config.Timeout.Duration
+		if f.timeout == 0 {
+			f.timeout = DefaultFaultTimeout
+		}
+		stor.stats.Timeouts++
+	case config.ErrorRate > 0 && stor.rnd.Float64() < config.ErrorRate:
+		f.err = true
+		stor.stats.Errors++
+	case batchSize > 1 && config.PartialBatchRate > 0 && stor.rnd.Float64() < config.PartialBatchRate:
+		f.partial = 1 + stor.rnd.Intn(batchSize-1)
+		stor.stats.PartialBatches++
+	}
+
+	return f, true
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// inject delays the call and returns error of injected failure, batch which should fail partially
+// is returned as the number of metrics to save (-1 - the whole batch).
+func (stor *FaultStorageWrapper) inject(ctx context.Context, method string, batchSize int) (int, error) {
+	f, ok := stor.nextFault(method, batchSize)
+	if !ok {
+		return -1, nil
+	}
+
+	if err := sleep(ctx, f.delay); err != nil {
+		return -1, err
+	}
+
+	if f.timeout > 0 {
+		if err := sleep(ctx, f.timeout); err != nil {
+			return -1, err
+		}
+
+		return -1, fmt.Errorf("%w: %s", ErrInjectedTimeout, method)
+	}
+
+	if f.err {
+		return -1, fmt.Errorf("%w: %s", ErrInjectedFault, method)
+	}
+
+	return f.partial, nil
+}
+
+func (stor *FaultStorageWrapper) ForEachMetrics(ctx context.Context, h func(*StorageMetric)) error {
+	if _, err := stor.inject(ctx, "ForEachMetrics", 0); err != nil {
+		return err
+	}
+
+	return stor.StorageInterface.ForEachMetrics(ctx, h)
+}
+
+func (stor *FaultStorageWrapper) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	if _, err := stor.inject(ctx, "GetMetric", 0); err != nil {
+		return nil, err
+	}
+
+	return stor.StorageInterface.GetMetric(ctx, mType, id)
+}
+
+func (stor *FaultStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	if _, err := stor.inject(ctx, "UpdateMetric", 0); err != nil {
+		return err
+	}
+
+	return stor.StorageInterface.UpdateMetric(ctx, metric)
+}
+
+// UpdateMetrics saves the first metrics of batch failed partially, like storage which is not transactional.
+func (stor *FaultStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	partial, err := stor.inject(ctx, "UpdateMetrics", len(metricsList))
+	if err != nil {
+		return err
+	}
+
+	if partial < 0 {
+		return stor.StorageInterface.UpdateMetrics(ctx, metricsList)
+	}
+
+	if err := stor.StorageInterface.UpdateMetrics(ctx, metricsList[:partial]); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: UpdateMetrics saved %d of %d metrics", ErrInjectedFault, partial, len(metricsList))
+}
+
+func (stor *FaultStorageWrapper) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	if _, err := stor.inject(ctx, "GetMetricHistory", 0); err != nil {
+		return nil, err
+	}
+
+	return stor.StorageInterface.GetMetricHistory(ctx, mType, id, from, to, step)
+}
+
+func (stor *FaultStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	if _, err := stor.inject(ctx, "EvictExpired", 0); err != nil {
+		return nil, err
+	}
+
+	return stor.StorageInterface.EvictExpired(ctx, policy, now)
+}
+
+func (stor *FaultStorageWrapper) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	if _, err := stor.inject(ctx, "ListMetrics", 0); err != nil {
+		return nil, err
+	}
+
+	return stor.StorageInterface.ListMetrics(ctx, query)
+}
+
+func (stor *FaultStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	if _, err := stor.inject(ctx, "DeleteMetric", 0); err != nil {
+		return false, err
+	}
+
+	return stor.StorageInterface.DeleteMetric(ctx, mType, id)
+}
+
+func (stor *FaultStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	if _, err := stor.inject(ctx, "DeleteMetrics", 0); err != nil {
+		return nil, err
+	}
+
+	return stor.StorageInterface.DeleteMetrics(ctx, filter)
+}
+
+func (stor *FaultStorageWrapper) Ping(ctx context.Context) error {
+	if _, err := stor.inject(ctx, "Ping", 0); err != nil {
+		return err
+	}
+
+	return stor.StorageInterface.Ping(ctx)
+}
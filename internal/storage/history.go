@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+// DefaultHistorySize is the number of points kept for every metric
+// by the in-memory Storage unless SetHistorySize is called.
+const DefaultHistorySize = 3600
+
+var ErrHistoryNotSupported = errors.New("metric history is not supported by the storage")
+
+// HistoryPoint is a single timestamped state of a metric.
+// For counters Delta holds the accumulated value after the update,
+// for histograms Histogram holds the accumulated distribution.
+// Min and Max are set for points aggregated by StorageV2, Value is average then.
+type HistoryPoint struct {
+	Timestamp time.Time
+	Delta     int64
+	Value     float64
+	Min       *float64
+	Max       *float64
+	Histogram *common.Histogram
+	Summary   *common.Summary
+}
+
+// history is a ring buffer of metric points ordered by time, it grows up to capacity
+// as points are pushed, so metrics with a few updates do not hold the whole ring.
+type history struct {
+	points   []HistoryPoint
+	capacity int
+	start    int
+}
+
+func newHistory(capacity int) *history {
+	return &history{
+		capacity: capacity,
+	}
+}
+
+func (h *history) push(point HistoryPoint) {
+	if h.capacity <= 0 {
+		return
+	}
+
+	if len(h.points) < h.capacity {
+		h.points = append(h.points, point)
+		return
+	}
+
+	h.points[h.start] = point
+	h.start = (h.start + 1) % len(h.points)
+}
+
+func (h *history) forEach(handler func(*HistoryPoint)) {
+	for i := range h.points {
+		handler(&h.points[(h.start+i)%len(h.points)])
+	}
+}
+
+// query returns copies of the points within [from, to].
+// Zero from or to means the range is not bounded from that side.
+func (h *history) query(from, to time.Time, step time.Duration) []*HistoryPoint {
+	points := make([]*HistoryPoint, 0)
+
+	h.forEach(func(p *HistoryPoint) {
+		if !from.IsZero() && p.Timestamp.Before(from) {
+			return
+		}
+		if !to.IsZero() && p.Timestamp.After(to) {
+			return
+		}
+
+		point := *p
+		points = append(points, &point)
+	})
+
+	return Downsample(points, from, step)
+}
+
+// Downsample splits time ordered points into windows of step length starting from `from`
+// (or from the first point truncated to step when from is zero) and keeps the last point
+// of every window stamped with the window start. Non positive step returns points as is.
+func Downsample(points []*HistoryPoint, from time.Time, step time.Duration) []*HistoryPoint {
+	if step <= 0 || len(points) == 0 {
+		return points
+	}
+
+	origin := from
+	if origin.IsZero() {
+		origin = points[0].Timestamp.Truncate(step)
+	}
+
+	res := make([]*HistoryPoint, 0)
+
+	for _, p := range points {
+		windowStart := origin.Add(p.Timestamp.Sub(origin) / step * step)
+
+		point := *p
+		point.Timestamp = windowStart
+
+		if len(res) != 0 && res[len(res)-1].Timestamp.Equal(windowStart) {
+			res[len(res)-1] = &point
+		} else {
+			res = append(res, &point)
+		}
+	}
+
+	return res
+}
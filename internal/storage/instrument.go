@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+// DefaultLatencyBuckets are upper bounds (seconds) of latency histograms of StorageMetrics.
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Names of self-metrics returned by StorageMetrics.Metrics.
+const (
+	StorageLatencyMetric  = "StorageLatency"
+	StorageErrorsMetric   = "StorageErrors"
+	StorageInFlightMetric = "StorageInFlight"
+	BackupLatencyMetric   = "BackupLatency"
+	BackupErrorsMetric    = "BackupErrors"
+)
+
+// Backup phases timed by StorageMetrics: snapshot holds the storage lock, write marshals, encodes and writes files.
+const (
+	backupSnapshotPhase = "snapshot"
+	backupWritePhase    = "write"
+)
+
+// operationStats is latency and errors of storage method or backup phase, updated atomically.
+type operationStats struct {
+	sumNanos uint64
+	errors   uint64
+	inFlight int64
+	// buckets are not cumulative, the last one counts observations greater than the last bound
+	buckets []uint64
+}
+
+func (stats *operationStats) observe(bounds []float64, d time.Duration, err error) {
+	seconds := d.Seconds()
+
+	i := sort.SearchFloat64s(bounds, seconds)
+	atomic.AddUint64(&stats.buckets[i], 1)
+	atomic.AddUint64(&stats.sumNanos, uint64(d))
+
+	if err != nil {
+		atomic.AddUint64(&stats.errors, 1)
+	}
+}
+
+func (stats *operationStats) histogram(bounds []float64) *common.Histogram {
+	histogram := &common.Histogram{
+		Buckets: make([]common.HistogramBucket, len(bounds)),
+		Sum:     time.Duration(atomic.LoadUint64(&stats.sumNanos)).Seconds(),
+	}
+
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += atomic.LoadUint64(&stats.buckets[i])
+		histogram.Buckets[i] = common.HistogramBucket{UpperBound: bound, Count: cumulative}
+	}
+	histogram.Count = cumulative + atomic.LoadUint64(&stats.buckets[len(bounds)])
+
+	return histogram
+}
+
+// StorageMetrics collects latency, errors and in-flight calls of storage methods and backup phases.
+// It never locks, so it can be put in front of storage on the hot path.
+type StorageMetrics struct {
+	bounds  []float64
+	methods map[string]*operationStats
+	backups map[string]*operationStats
+}
+
+// NewStorageMetrics creates StorageMetrics with latency histograms of bucket bounds in seconds
+// (DefaultLatencyBuckets if bounds is empty).
+func NewStorageMetrics(bounds []float64) *StorageMetrics {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+
+	newStats := func() *operationStats {
+		return &operationStats{buckets: make([]uint64, len(bounds)+1)}
+	}
+
+	m := &StorageMetrics{
+		bounds:  bounds,
+		methods: make(map[string]*operationStats, len(storageMethods)),
+		backups: map[string]*operationStats{
+			backupSnapshotPhase: newStats(),
+			backupWritePhase:    newStats(),
+		},
+	}
+
+	for method := range storageMethods {
+		m.methods[method] = newStats()
+	}
+
+	return m
+}
+
+// start marks call of storage method in flight, returned func records its latency and error.
+func (m *StorageMetrics) start(method string) func(err error) {
+	stats := m.methods[method]
+	atomic.AddInt64(&stats.inFlight, 1)
+	start := time.Now()
+
+	return func(err error) {
+		stats.observe(m.bounds, time.Since(start), err)
+		atomic.AddInt64(&stats.inFlight, -1)
+	}
+}
+
+// startBackup starts timing of backup phase, nil StorageMetrics records nothing.
+func (m *StorageMetrics) startBackup(phase string) func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+
+	stats := m.backups[phase]
+	start := time.Now()
+
+	return func(err error) {
+		stats.observe(m.bounds, time.Since(start), err)
+	}
+}
+
+// Metrics returns collected statistics as Metrics ordered by ID and labels:
+// StorageLatency histogram (seconds), StorageErrors counter and StorageInFlight gauge with `method` label
+// of every storage method, BackupLatency histogram and BackupErrors counter with `phase` label.
+func (m *StorageMetrics) Metrics() []common.Metric {
+	metrics := make([]common.Metric, 0, 3*len(m.methods)+2*len(m.backups))
+
+	for method, stats := range m.methods {
+		labels := map[string]string{"method": method}
+
+		errorsCount := int64(atomic.LoadUint64(&stats.errors))
+		inFlight := float64(atomic.LoadInt64(&stats.inFlight))
+
+		metrics = append(metrics,
+			common.Metric{ID: StorageLatencyMetric, MType: common.HistogramMetricName, Labels: labels, Histogram: stats.histogram(m.bounds)},
+			common.Metric{ID: StorageErrorsMetric, MType: common.CounterMetricName, Labels: labels, Delta: &errorsCount},
+			common.Metric{ID: StorageInFlightMetric, MType: common.GaugeMetricName, Labels: labels, Value: &inFlight},
+		)
+	}
+
+	for phase, stats := range m.backups {
+		labels := map[string]string{"phase": phase}
+
+		errorsCount := int64(atomic.LoadUint64(&stats.errors))
+
+		metrics = append(metrics,
+			common.Metric{ID: BackupLatencyMetric, MType: common.HistogramMetricName, Labels: labels, Histogram: stats.histogram(m.bounds)},
+			common.Metric{ID: BackupErrorsMetric, MType: common.CounterMetricName, Labels: labels, Delta: &errorsCount},
+		)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+
+		return metrics[i].Key() < metrics[j].Key()
+	})
+
+	return metrics
+}
+
+// InstrumentedStorageWrapper records latency, errors and in-flight calls of every storage method to StorageMetrics.
+// Every returned error is counted, including rejected updates (validation, precondition, tenant limit).
+type InstrumentedStorageWrapper struct {
+	StorageInterface
+	metrics *StorageMetrics
+}
+
+func WithInstrumentation(stor StorageInterface, metrics *StorageMetrics) *InstrumentedStorageWrapper {
+	return &InstrumentedStorageWrapper{
+		StorageInterface: stor,
+		metrics:          metrics,
+	}
+}
+
+func (stor *InstrumentedStorageWrapper) Metrics() *StorageMetrics {
+	return stor.metrics
+}
+
+func (stor *InstrumentedStorageWrapper) ForEachMetrics(ctx context.Context, h func(*StorageMetric)) error {
+	done := stor.metrics.start("ForEachMetrics")
+
+	err := stor.StorageInterface.ForEachMetrics(ctx, h)
+	done(err)
+
+	return err
+}
+
+func (stor *InstrumentedStorageWrapper) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	done := stor.metrics.start("GetMetric")
+
+	metric, err := stor.StorageInterface.GetMetric(ctx, mType, id)
+	done(err)
+
+	return metric, err
+}
+
+func (stor *InstrumentedStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	done := stor.metrics.start("UpdateMetric")
+
+	err := stor.StorageInterface.UpdateMetric(ctx, metric)
+	done(err)
+
+	return err
+}
+
+func (stor *InstrumentedStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	done := stor.metrics.start("UpdateMetrics")
+
+	err := stor.StorageInterface.UpdateMetrics(ctx, metricsList)
+	done(err)
+
+	return err
+}
+
+func (stor *InstrumentedStorageWrapper) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	done := stor.metrics.start("GetMetricHistory")
+
+	points, err := stor.StorageInterface.GetMetricHistory(ctx, mType, id, from, to, step)
+	done(err)
+
+	return points, err
+}
+
+func (stor *InstrumentedStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	done := stor.metrics.start("EvictExpired")
+
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+	done(err)
+
+	return evicted, err
+}
+
+func (stor *InstrumentedStorageWrapper) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	done := stor.metrics.start("ListMetrics")
+
+	page, err := stor.StorageInterface.ListMetrics(ctx, query)
+	done(err)
+
+	return page, err
+}
+
+func (stor *InstrumentedStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	done := stor.metrics.start("DeleteMetric")
+
+	deleted, err := stor.StorageInterface.DeleteMetric(ctx, mType, id)
+	done(err)
+
+	return deleted, err
+}
+
+func (stor *InstrumentedStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	done := stor.metrics.start("DeleteMetrics")
+
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+	done(err)
+
+	return deleted, err
+}
+
+func (stor *InstrumentedStorageWrapper) Ping(ctx context.Context) error {
+	done := stor.metrics.start("Ping")
+
+	err := stor.StorageInterface.Ping(ctx)
+	done(err)
+
+	return err
+}
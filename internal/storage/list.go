@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// MetricsFilter selects metrics by type, ID (without labels) and labels. Empty fields select all metrics.
+type MetricsFilter struct {
+	MType    string
+	IDPrefix string
+	// IDRegexp matches the whole ID
+	IDRegexp *regexp.Regexp
+	Matchers []*common.LabelMatcher
+}
+
+// NewMetricsFilter builds filter from request params: metric type, ID prefix, ID regexp and label matchers
+// (`{host="a",region=~"eu-.*"}`).
+func NewMetricsFilter(mType, idPrefix, idRegexp, match string) (*MetricsFilter, error) {
+	filter := &MetricsFilter{
+		MType:    mType,
+		IDPrefix: idPrefix,
+	}
+
+	switch mType {
+	case "":
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	case common.RateMetricName:
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidListQuery, newUnknownMetricTypeError(mType))
+	}
+
+	if idRegexp != "" {
+		re, err := regexp.Compile("^(?:" + idRegexp + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidListQuery, err)
+		}
+
+		filter.IDRegexp = re
+	}
+
+	matchers, err := common.ParseLabelMatchers(match)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidListQuery, err)
+	}
+	filter.Matchers = matchers
+
+	return filter, nil
+}
+
+// Match reports if metric is selected by the filter.
+func (filter *MetricsFilter) Match(sm *StorageMetric) bool {
+	if filter.MType != "" && sm.MType != filter.MType {
+		return false
+	}
+
+	if !strings.HasPrefix(sm.ID, filter.IDPrefix) {
+		return false
+	}
+
+	if filter.IDRegexp != nil && !filter.IDRegexp.MatchString(sm.ID) {
+		return false
+	}
+
+	return common.MatchLabels(filter.Matchers, sm.Labels)
+}
+
+// ListQuery is a page request of ListMetrics. Zero Limit returns all the metrics after Cursor.
+type ListQuery struct {
+	Filter MetricsFilter
+	Limit  int
+	// Cursor is NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// MetricsPage is metrics ordered by type and series key, NextCursor is empty on the last page.
+type MetricsPage struct {
+	Metrics    []*StorageMetric
+	NextCursor string
+}
+
+// listPosition is position of metric in the listing order.
+type listPosition struct {
+	mType string
+	key   string
+}
+
+func positionOf(sm *StorageMetric) listPosition {
+	return listPosition{mType: sm.MType, key: sm.Key()}
+}
+
+func (p listPosition) less(position listPosition) bool {
+	if p.mType != position.mType {
+		return p.mType < position.mType
+	}
+
+	return p.key < position.key
+}
+
+func encodeCursor(p listPosition) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p.mType + "\x00" + p.key))
+}
+
+// decodeCursor returns position after which the page starts, false for the first page.
+func decodeCursor(cursor string) (listPosition, bool, error) {
+	if cursor == "" {
+		return listPosition{}, false, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listPosition{}, false, fmt.Errorf("%w: bad cursor", ErrInvalidListQuery)
+	}
+
+	mType, key, ok := strings.Cut(string(data), "\x00")
+	if !ok {
+		return listPosition{}, false, fmt.Errorf("%w: bad cursor", ErrInvalidListQuery)
+	}
+
+	return listPosition{mType: mType, key: key}, true, nil
+}
+
+// newMetricsPage sorts metrics and cuts them by limit.
+func newMetricsPage(metrics []*StorageMetric, limit int) *MetricsPage {
+	sort.Slice(metrics, func(i, j int) bool {
+		return positionOf(metrics[i]).less(positionOf(metrics[j]))
+	})
+
+	page := &MetricsPage{Metrics: metrics}
+
+	if limit > 0 && len(metrics) > limit {
+		page.Metrics = metrics[:limit]
+		page.NextCursor = encodeCursor(positionOf(metrics[limit-1]))
+	}
+
+	return page
+}
+
+// metricsHeap is max-heap of metrics by listing position.
+type metricsHeap []*StorageMetric
+
+func (h metricsHeap) Len() int           { return len(h) }
+func (h metricsHeap) Less(i, j int) bool { return positionOf(h[j]).less(positionOf(h[i])) }
+func (h metricsHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *metricsHeap) Push(x interface{}) {
+	*h = append(*h, x.(*StorageMetric))
+}
+
+func (h *metricsHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
+}
+
+// listByForEach lists metrics of forEach keeping in memory at most limit+1 of them.
+func listByForEach(
+	ctx context.Context,
+	forEach func(context.Context, func(*StorageMetric)) error,
+	query ListQuery,
+) (*MetricsPage, error) {
+	after, hasCursor, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make(metricsHeap, 0)
+
+	err = forEach(ctx, func(sm *StorageMetric) {
+		if ctx.Err() != nil || !query.Filter.Match(sm) {
+			return
+		}
+
+		position := positionOf(sm)
+		if hasCursor && !after.less(position) {
+			return
+		}
+
+		if query.Limit <= 0 {
+			metrics = append(metrics, sm)
+			return
+		}
+
+		if len(metrics) <= query.Limit {
+			heap.Push(&metrics, sm)
+		} else if position.less(positionOf(metrics[0])) {
+			metrics[0] = sm
+			heap.Fix(&metrics, 0)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return newMetricsPage(metrics, query.Limit), nil
+}
+
+// mergeMetricsPages merges pages of the same query from different sources keeping the page limit.
+func mergeMetricsPages(page, other *MetricsPage, limit int) *MetricsPage {
+	if len(other.Metrics) == 0 {
+		return page
+	}
+	if len(page.Metrics) == 0 {
+		return other
+	}
+
+	hasMore := page.NextCursor != "" || other.NextCursor != ""
+
+	metrics := make([]*StorageMetric, 0, len(page.Metrics)+len(other.Metrics))
+	metrics = append(append(metrics, page.Metrics...), other.Metrics...)
+
+	merged := newMetricsPage(metrics, limit)
+
+	// the page is full, metrics after it may be in the sources
+	if hasMore && merged.NextCursor == "" {
+		merged.NextCursor = encodeCursor(positionOf(merged.Metrics[len(merged.Metrics)-1]))
+	}
+
+	return merged
+}
+
+const (
+	// listChunkSize is the number of rows read from database at once while filling a page
+	listChunkSize = 1000
+
+	// SELECT id, mType, delta, value, data FROM metrics
+	// WHERE ($1 = '' OR mType = $1) AND (mType COLLATE "C", id COLLATE "C") > ($2, $3) AND id LIKE $4
+	// ORDER BY mType COLLATE "C", id COLLATE "C" LIMIT $5
+	listMetricsSQL = "SELECT id, mType, delta, value, data FROM metrics " +
+		"WHERE ($1 = '' OR mType = $1) AND (mType COLLATE \"C\", id COLLATE \"C\") > ($2, $3) AND id LIKE $4 " +
+		"ORDER BY mType COLLATE \"C\", id COLLATE \"C\" LIMIT $5"
+)
+
+// likePrefix returns LIKE pattern of strings with prefix.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// ListMetrics reads metrics in chunks after the cursor, type and ID prefix are filtered by database,
+// ID regexp and labels are filtered while reading.
+func (stor *StorageV2) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	after, _, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := listChunkSize
+	if query.Limit >= chunkSize {
+		chunkSize = query.Limit + 1
+	}
+
+	metrics := make([]*StorageMetric, 0)
+
+	for {
+		rows, err := stor.dbPool.Query(
+			ctx,
+			listMetricsSQL,
+			query.Filter.MType,
+			after.mType,
+			after.key,
+			likePrefix(query.Filter.IDPrefix),
+			chunkSize,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		scanned := 0
+		for rows.Next() {
+			storageMetric, err := scanMetric(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			scanned++
+			after = positionOf(storageMetric)
+
+			if query.Filter.Match(storageMetric) {
+				metrics = append(metrics, storageMetric)
+			}
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		if scanned < chunkSize || (query.Limit > 0 && len(metrics) > query.Limit) {
+			break
+		}
+	}
+
+	return newMetricsPage(metrics, query.Limit), nil
+}
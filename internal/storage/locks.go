@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// seriesLockStripes is the number of locks series keys are spread over.
+const seriesLockStripes = 64
+
+// seriesLocks serialize operations on the same series without a global lock:
+// series keys are spread over a fixed number of mutexes by hash.
+type seriesLocks struct {
+	stripes [seriesLockStripes]sync.Mutex
+}
+
+// lock locks series keys until returned func is called.
+func (l *seriesLocks) lock(keys []string) func() {
+	indexes := make([]int, 0, len(keys))
+	seen := make(map[int]struct{}, len(keys))
+
+	for _, key := range keys {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		i := int(h.Sum32() % seriesLockStripes)
+
+		if _, ok := seen[i]; !ok {
+			seen[i] = struct{}{}
+			indexes = append(indexes, i)
+		}
+	}
+
+	// locks are taken in the same order by every caller
+	sort.Ints(indexes)
+
+	return l.lockStripes(indexes)
+}
+
+// lockAll locks every series until returned func is called.
+func (l *seriesLocks) lockAll() func() {
+	indexes := make([]int, seriesLockStripes)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	return l.lockStripes(indexes)
+}
+
+func (l *seriesLocks) lockStripes(indexes []int) func() {
+	for _, i := range indexes {
+		l.stripes[i].Lock()
+	}
+
+	return func() {
+		for _, i := range indexes {
+			l.stripes[i].Unlock()
+		}
+	}
+}
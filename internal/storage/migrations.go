@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationsLockKey is a key of pg_advisory_lock held while migrations are applied,
+// so concurrently started servers do not migrate the same database together.
+const migrationsLockKey = 7270417
+
+const (
+	// CREATE TABLE IF NOT EXISTS schema_migrations (
+	// version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now()
+	// );
+	createMigrationsTableSQL = "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		"version bigint PRIMARY KEY, " +
+		"name text NOT NULL, " +
+		"applied_at timestamptz NOT NULL DEFAULT now()" +
+		");"
+
+	// SELECT version, applied_at FROM schema_migrations
+	selectMigrationsSQL = "SELECT version, applied_at FROM schema_migrations"
+
+	// INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+	insertMigrationSQL = "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)"
+
+	// DELETE FROM schema_migrations WHERE version=$1
+	deleteMigrationSQL = "DELETE FROM schema_migrations WHERE version=$1"
+)
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrMigrationFile = errors.New("invalid migration file")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	*Migration
+	// AppliedAt is nil for pending migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations reads embedded migrations/{version}_{name}.(up|down).sql files
+// and returns them ordered by version.
+func LoadMigrations() ([]*Migration, error) {
+	entries, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsMap := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationFile, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		sqlBytes, err := migrationsFS.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := migrationsMap[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			migrationsMap[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has several names", ErrMigrationFile, version)
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(sqlBytes)
+		} else {
+			migration.Down = string(sqlBytes)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(migrationsMap))
+	for _, migration := range migrationsMap {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up migration", ErrMigrationFile, migration.Version)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+type Migrator struct {
+	dbPool     *pgxpool.Pool
+	migrations []*Migration
+}
+
+func NewMigrator(dbPool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		dbPool:     dbPool,
+		migrations: migrations,
+	}, nil
+}
+
+// withLock runs handler on a single connection holding migrations advisory lock.
+func (m *Migrator) withLock(ctx context.Context, handler func(*pgxpool.Conn) error) error {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+
+	if _, err = conn.Exec(ctx, createMigrationsTableSQL); err != nil {
+		return err
+	}
+
+	return handler(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, selectMigrationsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func runMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record func(pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	if err = record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Up applies all pending migrations, every one in its own transaction.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, migration.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, insertMigrationSQL, migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("Migration %d_%s is applied\n", migration.Version, migration.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the last `steps` applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			err := runMigration(ctx, conn, migration.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, deleteMigrationSQL, migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("Migration %d_%s is reverted\n", migration.Version, migration.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status returns all known migrations with time they were applied at.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	statuses := make([]*MigrationStatus, 0, len(m.migrations))
+
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := &MigrationStatus{Migration: migration}
+
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS metrics (
+    id text UNIQUE,
+    mType text,
+    delta bigint DEFAULT 0,
+    value double precision DEFAULT 0
+);
@@ -0,0 +1,3 @@
+-- fails if a gauge and a counter share the same id
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics ADD CONSTRAINT metrics_id_key UNIQUE (id);
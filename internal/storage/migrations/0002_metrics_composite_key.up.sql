@@ -0,0 +1,3 @@
+-- gauge and counter with the same id are different metrics
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_id_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_pkey PRIMARY KEY (id, mType);
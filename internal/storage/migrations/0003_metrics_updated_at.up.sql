@@ -0,0 +1,2 @@
+-- time of the last update is used to evict stale metrics by TTL
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
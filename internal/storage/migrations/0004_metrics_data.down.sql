@@ -0,0 +1,2 @@
+DELETE FROM metrics WHERE data IS NOT NULL;
+ALTER TABLE metrics DROP COLUMN IF EXISTS data;
@@ -0,0 +1,2 @@
+-- histogram and summary values are kept as JSON
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS data jsonb;
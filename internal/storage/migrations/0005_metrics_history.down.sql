@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS metrics_rollups;
+DROP TABLE IF EXISTS metrics_history;
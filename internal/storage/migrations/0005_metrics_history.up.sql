@@ -0,0 +1,27 @@
+-- raw points of metrics, every update saves the state of the metric after the update
+CREATE TABLE IF NOT EXISTS metrics_history (
+    id text NOT NULL,
+    mType text NOT NULL,
+    ts timestamptz NOT NULL,
+    delta bigint,
+    value double precision,
+    data jsonb
+);
+CREATE INDEX IF NOT EXISTS metrics_history_series_idx ON metrics_history (id, mType, ts);
+CREATE INDEX IF NOT EXISTS metrics_history_ts_idx ON metrics_history (ts);
+
+-- points aggregated by resolution (seconds): the last delta and data, avg, min and max of value
+CREATE TABLE IF NOT EXISTS metrics_rollups (
+    id text NOT NULL,
+    mType text NOT NULL,
+    resolution bigint NOT NULL,
+    ts timestamptz NOT NULL,
+    delta bigint,
+    value double precision,
+    min double precision,
+    max double precision,
+    count bigint NOT NULL,
+    data jsonb,
+    PRIMARY KEY (id, mType, resolution, ts)
+);
+CREATE INDEX IF NOT EXISTS metrics_rollups_ts_idx ON metrics_rollups (resolution, ts);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS metrics_listing_idx;
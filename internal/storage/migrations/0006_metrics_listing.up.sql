@@ -0,0 +1,2 @@
+-- metrics are listed by type and series key in byte order
+CREATE INDEX IF NOT EXISTS metrics_listing_idx ON metrics (mType COLLATE "C", id COLLATE "C");
@@ -0,0 +1,2 @@
+ALTER TABLE metrics DROP COLUMN IF EXISTS value_timestamp;
+ALTER TABLE metrics DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- gauges are versioned for conditional updates, value_timestamp is the time of the value set by client
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 0;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS value_timestamp timestamptz;
+UPDATE metrics SET version = 1 WHERE mType = 'gauge';
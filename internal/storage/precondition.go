@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// gaugeState is the stored state of gauge checked by common.Precondition,
+// version is incremented by every update and is 0 for missing gauge.
+type gaugeState struct {
+	exists    bool
+	value     float64
+	version   uint64
+	timestamp time.Time
+}
+
+// next returns state of the gauge after metric is saved.
+func (state gaugeState) next(metric common.Metric) gaugeState {
+	state.exists = true
+	state.value = *metric.Value
+	state.version++
+
+	if metric.Precondition != nil && metric.Precondition.Timestamp != nil {
+		state.timestamp = *metric.Precondition.Timestamp
+	}
+
+	return state
+}
+
+// checkPrecondition returns ErrPreconditionFailed if state of gauge with series key
+// does not match the precondition. Gauge saved without timestamp is older than any timestamp.
+func checkPrecondition(key string, precondition *common.Precondition, state gaugeState) error {
+	if precondition == nil {
+		return nil
+	}
+
+	if precondition.Version != nil && *precondition.Version != state.version {
+		return fmt.Errorf("%w: gauge %s has version %d", ErrPreconditionFailed, key, state.version)
+	}
+
+	if precondition.Value != nil && (!state.exists || state.value != *precondition.Value) {
+		return fmt.Errorf("%w: gauge %s has another value", ErrPreconditionFailed, key)
+	}
+
+	if precondition.Timestamp != nil && !state.timestamp.IsZero() && !precondition.Timestamp.After(state.timestamp) {
+		return fmt.Errorf("%w: gauge %s has newer value of %s", ErrPreconditionFailed, key, state.timestamp)
+	}
+
+	return nil
+}
+
+// hasPreconditions reports if any of metrics is conditional.
+func hasPreconditions(metricsList []common.Metric) bool {
+	for _, metric := range metricsList {
+		if metric.Precondition != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPreconditions checks preconditions of metrics in order of metricsList, so precondition
+// sees gauge updated by the previous metrics of the batch. stateOf returns the stored gauge state.
+func checkPreconditions(metricsList []common.Metric, stateOf func(key string) gaugeState) error {
+	states := make(map[string]gaugeState)
+
+	for _, metric := range metricsList {
+		if metric.MType != common.GaugeMetricName {
+			continue
+		}
+
+		key := metric.Key()
+
+		state, ok := states[key]
+		if !ok {
+			state = stateOf(key)
+		}
+
+		if err := checkPrecondition(key, metric.Precondition, state); err != nil {
+			return err
+		}
+
+		states[key] = state.next(metric)
+	}
+
+	return nil
+}
+
+// withoutPreconditions returns copy of metrics without preconditions, value timestamps are kept.
+func withoutPreconditions(metricsList []common.Metric) []common.Metric {
+	metrics := make([]common.Metric, len(metricsList))
+	copy(metrics, metricsList)
+
+	for i, metric := range metrics {
+		if metric.Precondition == nil {
+			continue
+		}
+
+		metrics[i].Precondition = nil
+		if metric.Precondition.Timestamp != nil {
+			metrics[i].Precondition = &common.Precondition{Timestamp: metric.Precondition.Timestamp}
+		}
+	}
+
+	return metrics
+}
+
+const (
+	// UPDATE metrics SET value = $3, version = version + 1, value_timestamp = COALESCE($6, value_timestamp), updated_at = now()
+	// WHERE id = $1 AND mType = $2
+	// AND ($4::double precision IS NULL OR value = $4) AND ($5::bigint IS NULL OR version = $5)
+	// AND ($6::timestamptz IS NULL OR value_timestamp IS NULL OR value_timestamp < $6)
+	updateValueIfSQL = "UPDATE metrics " +
+		"SET value = $3, version = version + 1, value_timestamp = COALESCE($6, value_timestamp), updated_at = now() " +
+		"WHERE id = $1 AND mType = $2 " +
+		"AND ($4::double precision IS NULL OR value = $4) AND ($5::bigint IS NULL OR version = $5) " +
+		"AND ($6::timestamptz IS NULL OR value_timestamp IS NULL OR value_timestamp < $6)"
+
+	// INSERT INTO metrics (id, mType, value, version, value_timestamp, updated_at)
+	// VALUES ($1, $2, $3, 1, $5, now())
+	// ON CONFLICT (id, mType) DO UPDATE SET value = EXCLUDED.value, version = metrics.version + 1,
+	// value_timestamp = COALESCE(EXCLUDED.value_timestamp, metrics.value_timestamp), updated_at = EXCLUDED.updated_at
+	// WHERE ($4::bigint IS NULL OR metrics.version = $4)
+	// AND ($5::timestamptz IS NULL OR metrics.value_timestamp IS NULL OR metrics.value_timestamp < $5)
+	upsertValueIfSQL = "INSERT INTO metrics (id, mType, value, version, value_timestamp, updated_at) " +
+		"VALUES ($1, $2, $3, 1, $5, now()) " +
+		"ON CONFLICT (id, mType) DO UPDATE SET value = EXCLUDED.value, version = metrics.version + 1, " +
+		"value_timestamp = COALESCE(EXCLUDED.value_timestamp, metrics.value_timestamp), updated_at = EXCLUDED.updated_at " +
+		"WHERE ($4::bigint IS NULL OR metrics.version = $4) " +
+		"AND ($5::timestamptz IS NULL OR metrics.value_timestamp IS NULL OR metrics.value_timestamp < $5)"
+)
+
+// upsertGaugeIf saves gauge in tx only if its precondition is satisfied. Precondition which needs
+// the stored gauge (value or non zero version) updates it, otherwise the gauge is inserted or updated.
+func upsertGaugeIf(ctx context.Context, tx pgx.Tx, metric common.Metric) error {
+	precondition := metric.Precondition
+
+	var version *int64
+	if precondition.Version != nil {
+		v := int64(*precondition.Version)
+		version = &v
+	}
+
+	sql, args := upsertValueIfSQL, []interface{}{metric.Key(), metric.MType, *metric.Value, version, precondition.Timestamp}
+	if precondition.Value != nil || (version != nil && *version != 0) {
+		sql = updateValueIfSQL
+		args = []interface{}{metric.Key(), metric.MType, *metric.Value, precondition.Value, version, precondition.Timestamp}
+	}
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: gauge %s", ErrPreconditionFailed, metric.Key())
+	}
+
+	return nil
+}
@@ -0,0 +1,447 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+const DefaultRateWindow = time.Minute
+
+type rateSample struct {
+	timestamp time.Time
+	increment int64
+}
+
+type counterRate struct {
+	// samples within the window ordered by time
+	samples []rateSample
+
+	// last value of cumulative counter
+	lastValue    int64
+	hasLastValue bool
+}
+
+// RateTracker computes per-second rate of counters over sliding window and converts
+// values of cumulative counters (total since Agent start) to deltas detecting counter resets.
+type RateTracker struct {
+	window     time.Duration
+	cumulative []string
+
+	mutex  sync.Mutex
+	rates  map[string]*counterRate
+	resets uint64
+
+	// keyLocks serialize read, conversion and save of cumulative counters with the same series key
+	keyLocks seriesLocks
+}
+
+// NewRateTracker builds tracker with rate window and comma separated ID patterns
+// (path.Match syntax, e.g. `Poll*,Requests`) of counters sent as cumulative values.
+func NewRateTracker(window time.Duration, cumulativeStr string) (*RateTracker, error) {
+	tracker := &RateTracker{
+		window: window,
+		rates:  make(map[string]*counterRate),
+	}
+
+	for _, pattern := range strings.Split(cumulativeStr, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("cumulative counter pattern %q: %w", pattern, err)
+		}
+
+		tracker.cumulative = append(tracker.cumulative, pattern)
+	}
+
+	return tracker, nil
+}
+
+// IsCumulative reports if counter with id (without labels) is sent as cumulative value.
+func (t *RateTracker) IsCumulative(id string) bool {
+	for _, pattern := range t.cumulative {
+		if ok, _ := path.Match(pattern, id); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *RateTracker) get(key string) *counterRate {
+	rate, ok := t.rates[key]
+	if !ok {
+		rate = &counterRate{}
+		t.rates[key] = rate
+	}
+
+	return rate
+}
+
+// cumulativeValue is value of cumulative counter staged by the update, it is committed to the tracker
+// after the update is saved.
+type cumulativeValue struct {
+	key   string
+	value int64
+	reset bool
+}
+
+// stageDelta converts cumulative value of the counter to increment since the previous value without changing
+// the tracker. Value less than the previous one means the counter was reset (Agent restarted), so the whole value
+// is the increment. The first value after Server start is the increment only if the counter is not stored yet
+// (stored is nil), otherwise it is a baseline. previous is the value staged earlier in the same batch (or nil).
+func (t *RateTracker) stageDelta(key string, value int64, stored *StorageMetric, previous *cumulativeValue) (int64, cumulativeValue) {
+	lastValue, hasLastValue := int64(0), false
+	if previous != nil {
+		lastValue, hasLastValue = previous.value, true
+	} else {
+		t.mutex.Lock()
+		if rate, ok := t.rates[key]; ok && rate.hasLastValue {
+			lastValue, hasLastValue = rate.lastValue, true
+		}
+		t.mutex.Unlock()
+	}
+
+	staged := cumulativeValue{key: key, value: value}
+
+	delta := value
+	switch {
+	case !hasLastValue && stored != nil:
+		delta = 0
+	case hasLastValue && value >= lastValue:
+		delta = value - lastValue
+	case hasLastValue:
+		staged.reset = true
+		log.Printf("Counter %s is reset from %d to %d\n", key, lastValue, value)
+	}
+
+	return delta, staged
+}
+
+// commit makes staged values the last values of their counters.
+func (t *RateTracker) commit(staged []cumulativeValue) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, value := range staged {
+		rate := t.get(value.key)
+		rate.lastValue = value.value
+		rate.hasLastValue = true
+
+		if value.reset {
+			t.resets++
+		}
+	}
+}
+
+// ToDelta converts cumulative value of the counter to increment since the previous value (see stageDelta)
+// and makes it the last value of the counter.
+func (t *RateTracker) ToDelta(key string, value int64, stored *StorageMetric) int64 {
+	delta, staged := t.stageDelta(key, value, stored, nil)
+	t.commit([]cumulativeValue{staged})
+
+	return delta
+}
+
+// Observe records increment of the counter made at the moment at.
+func (t *RateTracker) Observe(key string, increment int64, at time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rate := t.get(key)
+	rate.samples = append(rate.samples, rateSample{timestamp: at, increment: increment})
+	rate.prune(at.Add(-t.window))
+}
+
+func (r *counterRate) prune(from time.Time) {
+	i := 0
+	for i < len(r.samples) && r.samples[i].timestamp.Before(from) {
+		i++
+	}
+
+	r.samples = r.samples[i:]
+}
+
+// value returns rate of samples made after from. Increment of the first sample
+// was made before it, so rate is increments of the rest divided by time between the first and the last.
+func (r *counterRate) value(from time.Time) float64 {
+	r.prune(from)
+
+	if len(r.samples) < 2 {
+		return 0
+	}
+
+	var sum int64
+	for _, sample := range r.samples[1:] {
+		sum += sample.increment
+	}
+
+	first, last := r.samples[0], r.samples[len(r.samples)-1]
+
+	return float64(sum) / last.timestamp.Sub(first.timestamp).Seconds()
+}
+
+// Rate returns per-second rate of the counter at the moment now. The second result is false
+// if the counter was not updated since Server start.
+func (t *RateTracker) Rate(key string, now time.Time) (float64, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rate, ok := t.rates[key]
+	if !ok {
+		return 0, false
+	}
+
+	return rate.value(now.Add(-t.window)), true
+}
+
+// ForEachRate calls handler with every tracked counter and its rate at the moment now.
+func (t *RateTracker) ForEachRate(now time.Time, handler func(key string, rate float64)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key, rate := range t.rates {
+		handler(key, rate.value(now.Add(-t.window)))
+	}
+}
+
+// Resets returns the number of detected counter resets.
+func (t *RateTracker) Resets() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.resets
+}
+
+// Forget removes the counter from tracker.
+func (t *RateTracker) Forget(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.rates, key)
+}
+
+// RateStorageWrapper tracks rates of counters saved to the storage and exposes them
+// as read-only metrics of common.RateMetricName type.
+type RateStorageWrapper struct {
+	StorageInterface
+	tracker *RateTracker
+}
+
+func WithRates(stor StorageInterface, tracker *RateTracker) *RateStorageWrapper {
+	return &RateStorageWrapper{
+		StorageInterface: stor,
+		tracker:          tracker,
+	}
+}
+
+func (stor *RateStorageWrapper) Tracker() *RateTracker {
+	return stor.tracker
+}
+
+func (stor *RateStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+func (stor *RateStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
+
+	metrics := make([]common.Metric, len(metricsList))
+	copy(metrics, metricsList)
+
+	isCumulative := func(metric common.Metric) bool {
+		return metric.MType == common.CounterMetricName && metric.Delta != nil && stor.tracker.IsCumulative(metric.ID)
+	}
+
+	keys := make([]string, 0)
+	for _, metric := range metrics {
+		if isCumulative(metric) {
+			keys = append(keys, metric.Key())
+		}
+	}
+
+	// conversion of cumulative value depends on the stored counter and the last value,
+	// so they do not change until the converted delta is saved
+	unlock := stor.tracker.keyLocks.lock(keys)
+	defer unlock()
+
+	staged := make([]cumulativeValue, 0, len(keys))
+	stagedIndex := make(map[string]int, len(keys))
+
+	for i, metric := range metrics {
+		if !isCumulative(metric) {
+			continue
+		}
+
+		key := metric.Key()
+
+		var previous *cumulativeValue
+		var stored *StorageMetric
+		if j, ok := stagedIndex[key]; ok {
+			previous = &staged[j]
+		} else {
+			var err error
+			if stored, err = stor.StorageInterface.GetMetric(ctx, metric.MType, key); err != nil {
+				return err
+			}
+		}
+
+		delta, value := stor.tracker.stageDelta(key, *metric.Delta, stored, previous)
+		metrics[i].Delta = &delta
+
+		stagedIndex[key] = len(staged)
+		staged = append(staged, value)
+	}
+
+	// values of failed update stay staged, the retried update converts them again
+	if err := stor.StorageInterface.UpdateMetrics(ctx, metrics); err != nil {
+		return err
+	}
+
+	stor.tracker.commit(staged)
+
+	now := time.Now()
+	for _, metric := range metrics {
+		if metric.MType == common.CounterMetricName && metric.Delta != nil {
+			stor.tracker.Observe(metric.Key(), *metric.Delta, now)
+		}
+	}
+
+	return nil
+}
+
+// ObserveReplicated tracks changes applied from primary below the wrapper (see ReplicationStorageWrapper.OnApply):
+// counter deltas are observed at the batch time (primary has converted cumulative values already)
+// and deleted counters are forgotten.
+func (stor *RateStorageWrapper) ObserveReplicated(batch ReplicationBatch) {
+	for _, metric := range batch.Metrics {
+		if metric.MType == common.CounterMetricName && metric.Delta != nil {
+			stor.tracker.Observe(metric.Key(), *metric.Delta, batch.Timestamp)
+		}
+	}
+
+	for _, sm := range batch.Deleted {
+		if sm.MType == common.CounterMetricName {
+			stor.tracker.Forget(sm.Key())
+		}
+	}
+}
+
+func (stor *RateStorageWrapper) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	if mType != common.RateMetricName {
+		return stor.StorageInterface.GetMetric(ctx, mType, id)
+	}
+
+	rate, ok := stor.tracker.Rate(id, time.Now())
+	if !ok {
+		return nil, nil
+	}
+
+	storageMetric := newStorageMetric(mType, id)
+	storageMetric.Value = rate
+
+	return storageMetric, nil
+}
+
+func (stor *RateStorageWrapper) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
+	if err := stor.StorageInterface.ForEachMetrics(ctx, handler); err != nil {
+		return err
+	}
+
+	stor.tracker.ForEachRate(time.Now(), func(key string, rate float64) {
+		storageMetric := newStorageMetric(common.RateMetricName, key)
+		storageMetric.Value = rate
+
+		handler(storageMetric)
+	})
+
+	return nil
+}
+
+func (stor *RateStorageWrapper) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	if mType == common.RateMetricName {
+		return nil, ErrHistoryNotSupported
+	}
+
+	return stor.StorageInterface.GetMetricHistory(ctx, mType, id, from, to, step)
+}
+
+func (stor *RateStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+
+	for _, sm := range evicted {
+		if sm.MType == common.CounterMetricName {
+			stor.tracker.Forget(sm.Key())
+		}
+	}
+
+	return evicted, err
+}
+
+func (stor *RateStorageWrapper) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	page := &MetricsPage{}
+	if query.Filter.MType != common.RateMetricName {
+		var err error
+		if page, err = stor.StorageInterface.ListMetrics(ctx, query); err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Filter.MType != "" && query.Filter.MType != common.RateMetricName {
+		return page, nil
+	}
+
+	ratesPage, err := listByForEach(ctx, func(ctx context.Context, handler func(*StorageMetric)) error {
+		stor.tracker.ForEachRate(time.Now(), func(key string, rate float64) {
+			storageMetric := newStorageMetric(common.RateMetricName, key)
+			storageMetric.Value = rate
+
+			handler(storageMetric)
+		})
+
+		return nil
+	}, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeMetricsPages(page, ratesPage, query.Limit), nil
+}
+
+func (stor *RateStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	deleted, err := stor.StorageInterface.DeleteMetric(ctx, mType, id)
+	if deleted && mType == common.CounterMetricName {
+		stor.tracker.Forget(id)
+	}
+
+	return deleted, err
+}
+
+func (stor *RateStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+
+	for _, sm := range deleted {
+		if sm.MType == common.CounterMetricName {
+			stor.tracker.Forget(sm.Key())
+		}
+	}
+
+	return deleted, err
+}
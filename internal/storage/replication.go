@@ -0,0 +1,472 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+// DefaultReplicationLogSize is the number of batches kept for replicas.
+const DefaultReplicationLogSize = 10000
+
+var ErrReadOnlyReplica = errors.New("server is a read-only replica")
+
+// ReplicationBatch is a batch of metrics saved to the storage of primary or a batch of deleted series.
+type ReplicationBatch struct {
+	Sequence  uint64
+	Timestamp time.Time
+	Metrics   []common.Metric
+	// Deleted are type and series key of deleted metrics
+	Deleted []*StorageMetric
+}
+
+// ReplicationStorageWrapper logs saved batches for replicas. Log is identified by epoch
+// (set at creation, replica takes epoch of its primary), batches are numbered by sequence within epoch.
+// Writes of the same series are serialized to keep the same order of their batches in the storage and in the log,
+// the log mutex is held only to append the batch.
+// Replica storage rejects writes except batches applied from primary until it is promoted.
+type ReplicationStorageWrapper struct {
+	StorageInterface
+
+	// series are locked by writes from the storage write till the log append
+	series seriesLocks
+
+	mutex    sync.Mutex
+	epoch    uint64
+	sequence uint64
+	// batches is a ring of the last batches ordered by sequence
+	batches  []ReplicationBatch
+	start    int
+	size     int
+	readOnly bool
+	// notify is closed and replaced when a batch is logged
+	notify chan struct{}
+
+	// snapshotSeen are series keys of parts of snapshot at snapshotEpoch and snapshotSequence applied so far
+	snapshotEpoch    uint64
+	snapshotSequence uint64
+	snapshotSeen     map[string]struct{}
+
+	// applied is called with changes of primary saved by Apply and ApplySnapshot, it is set by OnApply
+	applied func(ReplicationBatch)
+}
+
+func WithReplicationLog(stor StorageInterface, capacity int, readOnly bool) *ReplicationStorageWrapper {
+	if capacity <= 0 {
+		capacity = DefaultReplicationLogSize
+	}
+
+	return &ReplicationStorageWrapper{
+		StorageInterface: stor,
+		epoch:            newEpoch(0),
+		batches:          make([]ReplicationBatch, capacity),
+		readOnly:         readOnly,
+		notify:           make(chan struct{}),
+	}
+}
+
+// OnApply sets handler of changes applied from primary, so wrappers above the log (which applied changes bypass)
+// track them too. It should be called before replica starts following primary.
+func (stor *ReplicationStorageWrapper) OnApply(handler func(ReplicationBatch)) {
+	stor.applied = handler
+}
+
+// notifyApplied passes changes applied from primary to OnApply handler.
+func (stor *ReplicationStorageWrapper) notifyApplied(batch ReplicationBatch) {
+	if stor.applied != nil {
+		stor.applied(batch)
+	}
+}
+
+// Position returns epoch and sequence of the last logged batch.
+func (stor *ReplicationStorageWrapper) Position() (uint64, uint64) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	return stor.epoch, stor.sequence
+}
+
+func (stor *ReplicationStorageWrapper) IsReadOnly() bool {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	return stor.readOnly
+}
+
+// Promote makes replica storage writable and starts a new epoch of the log. Other replicas of the old primary
+// may have applied batches the promoted replica has not, so every follower of the new epoch starts from a snapshot.
+func (stor *ReplicationStorageWrapper) Promote() {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	if !stor.readOnly {
+		return
+	}
+
+	stor.readOnly = false
+	stor.epoch = newEpoch(stor.epoch)
+	stor.start = 0
+	stor.size = 0
+}
+
+// newEpoch returns epoch of the log created now which differs from previous.
+func newEpoch(previous uint64) uint64 {
+	epoch := uint64(time.Now().UnixNano())
+	if epoch == previous {
+		epoch++
+	}
+
+	return epoch
+}
+
+// pushLocked adds batch to the log and wakes up waiting replicas, should be called under mutex.
+func (stor *ReplicationStorageWrapper) pushLocked(batch ReplicationBatch) {
+	capacity := len(stor.batches)
+
+	if stor.size < capacity {
+		stor.batches[(stor.start+stor.size)%capacity] = batch
+		stor.size++
+	} else {
+		stor.batches[stor.start] = batch
+		stor.start = (stor.start + 1) % capacity
+	}
+
+	stor.sequence = batch.Sequence
+
+	close(stor.notify)
+	stor.notify = make(chan struct{})
+}
+
+func (stor *ReplicationStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+// push appends batch to the log with the next sequence.
+func (stor *ReplicationStorageWrapper) push(batch ReplicationBatch) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	batch.Sequence = stor.sequence + 1
+	batch.Timestamp = time.Now()
+
+	stor.pushLocked(batch)
+}
+
+// lockMetrics locks series of metrics until returned func is called.
+func (stor *ReplicationStorageWrapper) lockMetrics(metricsList []common.Metric) func() {
+	keys := make([]string, len(metricsList))
+	for i, metric := range metricsList {
+		keys[i] = historyKey(metric.MType, metric.Key())
+	}
+
+	return stor.series.lock(keys)
+}
+
+func (stor *ReplicationStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	if stor.IsReadOnly() {
+		return ErrReadOnlyReplica
+	}
+
+	unlock := stor.lockMetrics(metricsList)
+	defer unlock()
+
+	if err := stor.StorageInterface.UpdateMetrics(ctx, metricsList); err != nil {
+		return err
+	}
+
+	if len(metricsList) != 0 {
+		// preconditions are checked by primary, replicas keep only value timestamps
+		stor.push(ReplicationBatch{Metrics: withoutPreconditions(metricsList)})
+	}
+
+	return nil
+}
+
+func (stor *ReplicationStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	if stor.IsReadOnly() {
+		return false, ErrReadOnlyReplica
+	}
+
+	unlock := stor.series.lock([]string{historyKey(mType, id)})
+	defer unlock()
+
+	deleted, err := stor.StorageInterface.DeleteMetric(ctx, mType, id)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+
+	stor.push(ReplicationBatch{Deleted: []*StorageMetric{newStorageMetric(mType, id)}})
+
+	return deleted, nil
+}
+
+// DeleteMetrics locks every series, because deleted series are known only after deletion.
+func (stor *ReplicationStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	if stor.IsReadOnly() {
+		return nil, ErrReadOnlyReplica
+	}
+
+	unlock := stor.series.lockAll()
+	defer unlock()
+
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+
+	// deletion may fail after some metrics are deleted
+	if len(deleted) != 0 {
+		stor.push(ReplicationBatch{Deleted: deleted})
+	}
+
+	return deleted, err
+}
+
+// EvictExpired evicts metrics on primary and logs them as deleted, so replicas drop them too.
+// Replica evicts nothing itself, it deletes metrics evicted by primary. Every series is locked,
+// because evicted series are known only after eviction.
+func (stor *ReplicationStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	if stor.IsReadOnly() {
+		return nil, ErrReadOnlyReplica
+	}
+
+	unlock := stor.series.lockAll()
+	defer unlock()
+
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+
+	// eviction may fail after some metrics are evicted
+	if len(evicted) != 0 {
+		stor.push(ReplicationBatch{Deleted: evicted})
+	}
+
+	return evicted, err
+}
+
+// Since returns logged batches after sequence of epoch and channel closed when the next batch is logged.
+// False means the batches are not in the log anymore (or epoch differs) and replica needs a snapshot.
+func (stor *ReplicationStorageWrapper) Since(epoch, sequence uint64) ([]ReplicationBatch, bool, <-chan struct{}) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	if epoch != stor.epoch || sequence > stor.sequence {
+		return nil, false, stor.notify
+	}
+
+	if sequence == stor.sequence {
+		return nil, true, stor.notify
+	}
+
+	if stor.size == 0 || sequence+1 < stor.batches[stor.start].Sequence {
+		return nil, false, stor.notify
+	}
+
+	capacity := len(stor.batches)
+	offset := int(sequence + 1 - stor.batches[stor.start].Sequence)
+
+	batches := make([]ReplicationBatch, 0, stor.size-offset)
+	for i := offset; i < stor.size; i++ {
+		batches = append(batches, stor.batches[(stor.start+i)%capacity])
+	}
+
+	return batches, true, stor.notify
+}
+
+// Timestamp returns time when batch with sequence was logged, false if it is not in the log.
+func (stor *ReplicationStorageWrapper) Timestamp(sequence uint64) (time.Time, bool) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	if stor.size == 0 || sequence < stor.batches[stor.start].Sequence || sequence > stor.sequence {
+		return time.Time{}, false
+	}
+
+	offset := int(sequence - stor.batches[stor.start].Sequence)
+
+	return stor.batches[(stor.start+offset)%len(stor.batches)].Timestamp, true
+}
+
+// Snapshot passes all the metrics of the storage to handler and returns position of the log
+// they are consistent with. Metrics are copied while writes wait and passed to handler after writes continue,
+// so slow handler (replica stream) does not block writes. The log is not locked by snapshot.
+func (stor *ReplicationStorageWrapper) Snapshot(ctx context.Context, handler func(*StorageMetric)) (uint64, uint64, error) {
+	unlock := stor.series.lockAll()
+
+	epoch, sequence := stor.Position()
+
+	snapshot := make([]*StorageMetric, 0)
+	err := stor.StorageInterface.ForEachMetrics(ctx, func(sm *StorageMetric) {
+		snapshot = append(snapshot, sm)
+	})
+
+	unlock()
+
+	if err != nil {
+		return epoch, sequence, err
+	}
+
+	for _, sm := range snapshot {
+		handler(sm)
+	}
+
+	return epoch, sequence, nil
+}
+
+// Apply saves batch of primary to the storage and the log.
+func (stor *ReplicationStorageWrapper) Apply(ctx context.Context, epoch uint64, batch ReplicationBatch) error {
+	keys := make([]string, 0, len(batch.Metrics)+len(batch.Deleted))
+	for _, metric := range batch.Metrics {
+		keys = append(keys, historyKey(metric.MType, metric.Key()))
+	}
+	for _, sm := range batch.Deleted {
+		keys = append(keys, historyKey(sm.MType, sm.Key()))
+	}
+
+	unlock := stor.series.lock(keys)
+	defer unlock()
+
+	if len(batch.Metrics) != 0 {
+		if err := stor.StorageInterface.UpdateMetrics(ctx, batch.Metrics); err != nil {
+			return err
+		}
+	}
+
+	for _, sm := range batch.Deleted {
+		if _, err := stor.StorageInterface.DeleteMetric(ctx, sm.MType, sm.Key()); err != nil {
+			return err
+		}
+	}
+
+	stor.notifyApplied(batch)
+
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	stor.epoch = epoch
+	stor.pushLocked(batch)
+
+	return nil
+}
+
+// snapshotDelta returns metric which brings local metric to the state of snapshot metric:
+// counter delta and histogram observations are the difference with local ones.
+func snapshotDelta(local, snapshot *StorageMetric) common.Metric {
+	metric := common.Metric{
+		ID:     snapshot.ID,
+		MType:  snapshot.MType,
+		Labels: snapshot.Labels,
+	}
+
+	switch snapshot.MType {
+	case common.GaugeMetricName:
+		value := snapshot.Value
+		metric.Value = &value
+	case common.CounterMetricName:
+		delta := snapshot.Delta
+		if local != nil {
+			delta -= local.Delta
+		}
+		metric.Delta = &delta
+	case common.HistogramMetricName:
+		metric.Histogram = snapshot.Histogram
+		if local != nil {
+			metric.Histogram = local.Histogram.Diff(snapshot.Histogram)
+		}
+	case common.SummaryMetricName:
+		metric.Summary = snapshot.Summary
+	}
+
+	return metric
+}
+
+// ApplySnapshot brings metrics of the storage to the state of primary snapshot. Snapshot may be
+// applied in parts, position is set by the last part. Series of the parts are tracked, so the last part
+// deletes local metrics missing in the snapshot.
+func (stor *ReplicationStorageWrapper) ApplySnapshot(
+	ctx context.Context,
+	snapshot []*StorageMetric,
+	epoch, sequence uint64,
+	last bool,
+) error {
+	keys := make([]string, len(snapshot))
+	for i, sm := range snapshot {
+		keys[i] = historyKey(sm.MType, sm.Key())
+	}
+
+	// the last part reads all the local metrics
+	var unlock func()
+	if last {
+		unlock = stor.series.lockAll()
+	} else {
+		unlock = stor.series.lock(keys)
+	}
+	defer unlock()
+
+	metrics := make([]common.Metric, 0, len(snapshot))
+	for _, sm := range snapshot {
+		local, err := stor.StorageInterface.GetMetric(ctx, sm.MType, sm.Key())
+		if err != nil {
+			return err
+		}
+
+		metrics = append(metrics, snapshotDelta(local, sm))
+	}
+
+	if err := stor.StorageInterface.UpdateMetrics(ctx, metrics); err != nil {
+		return err
+	}
+
+	seen := stor.trackSnapshot(epoch, sequence, keys)
+	if !last {
+		stor.notifyApplied(ReplicationBatch{Timestamp: time.Now(), Metrics: metrics})
+		return nil
+	}
+
+	missing := make([]*StorageMetric, 0)
+	err := stor.StorageInterface.ForEachMetrics(ctx, func(sm *StorageMetric) {
+		if _, ok := seen[historyKey(sm.MType, sm.Key())]; !ok {
+			missing = append(missing, sm)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sm := range missing {
+		if _, err := stor.StorageInterface.DeleteMetric(ctx, sm.MType, sm.Key()); err != nil {
+			return err
+		}
+	}
+
+	stor.notifyApplied(ReplicationBatch{Timestamp: time.Now(), Metrics: metrics, Deleted: missing})
+
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	stor.epoch = epoch
+	stor.sequence = sequence
+	stor.start = 0
+	stor.size = 0
+	stor.snapshotSeen = nil
+
+	return nil
+}
+
+// trackSnapshot adds series keys of snapshot part to the series of snapshot at position and returns them.
+// Part of another snapshot (replica reconnected meanwhile) starts tracking anew.
+func (stor *ReplicationStorageWrapper) trackSnapshot(epoch, sequence uint64, keys []string) map[string]struct{} {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	if stor.snapshotSeen == nil || stor.snapshotEpoch != epoch || stor.snapshotSequence != sequence {
+		stor.snapshotEpoch = epoch
+		stor.snapshotSequence = sequence
+		stor.snapshotSeen = make(map[string]struct{}, len(keys))
+	}
+
+	for _, key := range keys {
+		stor.snapshotSeen[key] = struct{}{}
+	}
+
+	return stor.snapshotSeen
+}
@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// DefaultHistoryRollups keeps raw points for a day, 1-minute rollups for 30 days and 1-hour rollups for a year.
+const DefaultHistoryRollups = "raw=24h,1m=720h,1h=8760h"
+
+// RollupTier keeps points aggregated by Resolution for Retention.
+type RollupTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// RollupPolicy sets how long StorageV2 keeps raw points of metrics history
+// and rollup tiers ordered by resolution.
+type RollupPolicy struct {
+	Raw   time.Duration
+	Tiers []RollupTier
+}
+
+// ParseRollupPolicy parses tiers in `raw=retention,resolution=retention` format, e.g. `raw=24h,1m=720h,1h=8760h`.
+// Every tier resolution is a multiple of the previous one (rollups are computed from the previous tier)
+// and raw points are kept at least for the first resolution.
+func ParseRollupPolicy(str string) (*RollupPolicy, error) {
+	policy := &RollupPolicy{}
+
+	for _, tierStr := range strings.Split(str, ",") {
+		tierStr = strings.TrimSpace(tierStr)
+		if tierStr == "" {
+			continue
+		}
+
+		resolutionStr, retentionStr, ok := strings.Cut(tierStr, "=")
+		if !ok {
+			return nil, fmt.Errorf("rollup tier %q: resolution=retention expected", tierStr)
+		}
+
+		retention, err := time.ParseDuration(strings.TrimSpace(retentionStr))
+		if err != nil {
+			return nil, fmt.Errorf("rollup tier %q: %w", tierStr, err)
+		}
+
+		if strings.TrimSpace(resolutionStr) == "raw" {
+			policy.Raw = retention
+			continue
+		}
+
+		resolution, err := time.ParseDuration(strings.TrimSpace(resolutionStr))
+		if err != nil {
+			return nil, fmt.Errorf("rollup tier %q: %w", tierStr, err)
+		}
+
+		if resolution < time.Second || resolution%time.Second != 0 {
+			return nil, fmt.Errorf("rollup tier %q: resolution is not whole seconds", tierStr)
+		}
+		if retention < resolution {
+			return nil, fmt.Errorf("rollup tier %q: retention is less than resolution", tierStr)
+		}
+
+		policy.Tiers = append(policy.Tiers, RollupTier{Resolution: resolution, Retention: retention})
+	}
+
+	if policy.Raw <= 0 {
+		return nil, fmt.Errorf("rollup policy %q: raw retention is required", str)
+	}
+
+	sort.Slice(policy.Tiers, func(i, j int) bool {
+		return policy.Tiers[i].Resolution < policy.Tiers[j].Resolution
+	})
+
+	for i, tier := range policy.Tiers {
+		if i == 0 {
+			if policy.Raw < tier.Resolution {
+				return nil, fmt.Errorf("rollup policy %q: raw retention is less than %s", str, tier.Resolution)
+			}
+			continue
+		}
+
+		prev := policy.Tiers[i-1]
+		if tier.Resolution%prev.Resolution != 0 {
+			return nil, fmt.Errorf("rollup policy %q: %s is not a multiple of %s", str, tier.Resolution, prev.Resolution)
+		}
+		if prev.Retention < tier.Resolution {
+			return nil, fmt.Errorf("rollup policy %q: %s retention is less than %s", str, prev.Resolution, tier.Resolution)
+		}
+	}
+
+	return policy, nil
+}
+
+// TierFor returns the finest tier which keeps points since from (at now) and which resolution is not greater
+// than step, nil means raw points (step is zero, raw points are kept since from or step is less than any resolution).
+// If no tier keeps points since from (or from is zero), the coarsest tier with resolution not greater than step
+// is returned as it is kept for the longest time.
+func (p *RollupPolicy) TierFor(step time.Duration, from, now time.Time) *RollupTier {
+	if step <= 0 {
+		return nil
+	}
+
+	keeps := func(retention time.Duration) bool {
+		return !from.IsZero() && !from.Before(now.Add(-retention))
+	}
+
+	if keeps(p.Raw) {
+		return nil
+	}
+
+	var tier *RollupTier
+
+	// tiers are ordered by resolution
+	for i := range p.Tiers {
+		if p.Tiers[i].Resolution > step {
+			break
+		}
+
+		tier = &p.Tiers[i]
+		if keeps(tier.Retention) {
+			break
+		}
+	}
+
+	return tier
+}
+
+const (
+	// SELECT EXISTS (SELECT 1 FROM metrics WHERE id=$1 AND mType=$2)
+	selectMetricExistsSQL = "SELECT EXISTS (SELECT 1 FROM metrics WHERE id=$1 AND mType=$2)"
+
+	// INSERT INTO metrics_history (id, mType, ts, delta, value, data)
+	// SELECT id, mType, updated_at, delta, value, data FROM metrics WHERE id=$1 AND mType=$2
+	insertHistorySQL = "INSERT INTO metrics_history (id, mType, ts, delta, value, data) " +
+		"SELECT id, mType, updated_at, delta, value, data FROM metrics WHERE id=$1 AND mType=$2"
+
+	// SELECT ts, delta, value, data FROM metrics_history
+	// WHERE id=$1 AND mType=$2 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4)
+	// ORDER BY ts
+	selectHistorySQL = "SELECT ts, delta, value, data FROM metrics_history " +
+		"WHERE id=$1 AND mType=$2 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4) " +
+		"ORDER BY ts"
+
+	// SELECT bucket, (array_agg(delta ORDER BY ts DESC))[1], avg(value), min(value), max(value),
+	// (array_agg(data ORDER BY ts DESC))[1]
+	// FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $5) * $5) AS bucket FROM metrics_history
+	// WHERE id=$1 AND mType=$2 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4)) AS points
+	// GROUP BY bucket ORDER BY bucket
+	selectHistoryByStepSQL = "SELECT bucket, (array_agg(delta ORDER BY ts DESC))[1], avg(value), min(value), max(value), " +
+		"(array_agg(data ORDER BY ts DESC))[1] " +
+		"FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $5) * $5) AS bucket FROM metrics_history " +
+		"WHERE id=$1 AND mType=$2 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4)) AS points " +
+		"GROUP BY bucket ORDER BY bucket"
+
+	// SELECT bucket, (array_agg(delta ORDER BY ts DESC))[1], sum(value * count) / sum(count), min(min), max(max),
+	// (array_agg(data ORDER BY ts DESC))[1]
+	// FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $6) * $6) AS bucket FROM metrics_rollups
+	// WHERE id=$1 AND mType=$2 AND resolution=$5 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4)) AS points
+	// GROUP BY bucket ORDER BY bucket
+	selectRollupsByStepSQL = "SELECT bucket, (array_agg(delta ORDER BY ts DESC))[1], sum(value * count) / sum(count), min(min), max(max), " +
+		"(array_agg(data ORDER BY ts DESC))[1] " +
+		"FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $6) * $6) AS bucket FROM metrics_rollups " +
+		"WHERE id=$1 AND mType=$2 AND resolution=$5 AND ($3::timestamptz IS NULL OR ts >= $3) AND ($4::timestamptz IS NULL OR ts <= $4)) AS points " +
+		"GROUP BY bucket ORDER BY bucket"
+
+	// SELECT max(ts) FROM metrics_rollups WHERE resolution=$1
+	selectLastRollupSQL = "SELECT max(ts) FROM metrics_rollups WHERE resolution=$1"
+
+	// INSERT INTO metrics_rollups (id, mType, resolution, ts, delta, value, min, max, count, data)
+	// SELECT id, mType, $1, bucket, (array_agg(delta ORDER BY ts DESC))[1], avg(value), min(value), max(value), count(*),
+	// (array_agg(data ORDER BY ts DESC))[1]
+	// FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $1) * $1) AS bucket FROM metrics_history
+	// WHERE ts >= $2 AND ts < $3) AS points
+	// GROUP BY id, mType, bucket
+	// ON CONFLICT (id, mType, resolution, ts) DO UPDATE SET delta = EXCLUDED.delta, value = EXCLUDED.value,
+	// min = EXCLUDED.min, max = EXCLUDED.max, count = EXCLUDED.count, data = EXCLUDED.data
+	rollupHistorySQL = "INSERT INTO metrics_rollups (id, mType, resolution, ts, delta, value, min, max, count, data) " +
+		"SELECT id, mType, $1, bucket, (array_agg(delta ORDER BY ts DESC))[1], avg(value), min(value), max(value), count(*), " +
+		"(array_agg(data ORDER BY ts DESC))[1] " +
+		"FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $1) * $1) AS bucket FROM metrics_history " +
+		"WHERE ts >= $2 AND ts < $3) AS points " +
+		"GROUP BY id, mType, bucket " +
+		"ON CONFLICT (id, mType, resolution, ts) DO UPDATE SET delta = EXCLUDED.delta, value = EXCLUDED.value, " +
+		"min = EXCLUDED.min, max = EXCLUDED.max, count = EXCLUDED.count, data = EXCLUDED.data"
+
+	// INSERT INTO metrics_rollups (id, mType, resolution, ts, delta, value, min, max, count, data)
+	// SELECT id, mType, $1, bucket, (array_agg(delta ORDER BY ts DESC))[1], sum(value * count) / sum(count), min(min), max(max),
+	// sum(count), (array_agg(data ORDER BY ts DESC))[1]
+	// FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $1) * $1) AS bucket FROM metrics_rollups
+	// WHERE resolution=$4 AND ts >= $2 AND ts < $3) AS points
+	// GROUP BY id, mType, bucket
+	// ON CONFLICT (id, mType, resolution, ts) DO UPDATE SET ...
+	rollupRollupsSQL = "INSERT INTO metrics_rollups (id, mType, resolution, ts, delta, value, min, max, count, data) " +
+		"SELECT id, mType, $1, bucket, (array_agg(delta ORDER BY ts DESC))[1], sum(value * count) / sum(count), min(min), max(max), " +
+		"sum(count), (array_agg(data ORDER BY ts DESC))[1] " +
+		"FROM (SELECT *, to_timestamp(floor(extract(epoch FROM ts) / $1) * $1) AS bucket FROM metrics_rollups " +
+		"WHERE resolution=$4 AND ts >= $2 AND ts < $3) AS points " +
+		"GROUP BY id, mType, bucket " +
+		"ON CONFLICT (id, mType, resolution, ts) DO UPDATE SET delta = EXCLUDED.delta, value = EXCLUDED.value, " +
+		"min = EXCLUDED.min, max = EXCLUDED.max, count = EXCLUDED.count, data = EXCLUDED.data"
+
+	// DELETE FROM metrics_history WHERE ts < $1
+	deleteHistoryBeforeSQL = "DELETE FROM metrics_history WHERE ts < $1"
+
+	// DELETE FROM metrics_rollups WHERE resolution=$1 AND ts < $2
+	deleteRollupsBeforeSQL = "DELETE FROM metrics_rollups WHERE resolution=$1 AND ts < $2"
+
+	// DELETE FROM metrics_history WHERE id=$1 AND mType=$2
+	deleteHistorySQL = "DELETE FROM metrics_history WHERE id=$1 AND mType=$2"
+
+	// DELETE FROM metrics_rollups WHERE id=$1 AND mType=$2
+	deleteRollupsSQL = "DELETE FROM metrics_rollups WHERE id=$1 AND mType=$2"
+)
+
+// SetRollupPolicy turns on metrics history of StorageV2. Nil policy turns history off.
+func (stor *StorageV2) SetRollupPolicy(policy *RollupPolicy) {
+	stor.rollups = policy
+}
+
+// pushHistory saves the state of upserted metric as a raw history point.
+func (stor *StorageV2) pushHistory(ctx context.Context, tx pgx.Tx, key, mType string) error {
+	if stor.rollups == nil {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, insertHistorySQL, key, mType)
+
+	return err
+}
+
+// deleteHistory deletes raw points and rollups of the metric.
+func deleteHistory(ctx context.Context, tx pgx.Tx, key, mType string) error {
+	if _, err := tx.Exec(ctx, deleteHistorySQL, key, mType); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, deleteRollupsSQL, key, mType)
+
+	return err
+}
+
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// GetMetricHistory reads raw points if step is less than any rollup resolution,
+// otherwise the coarsest rollup tier which resolution fits the step. Points are aggregated
+// by windows of step aligned to Unix epoch: gauge Value is average with Min and Max,
+// counter, histogram and summary keep the last state of the window.
+func (stor *StorageV2) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	if stor.rollups == nil {
+		return nil, ErrHistoryNotSupported
+	}
+
+	var rows pgx.Rows
+	var err error
+
+	tier := stor.rollups.TierFor(step, from, time.Now())
+	switch {
+	case step <= 0:
+		rows, err = stor.dbPool.Query(ctx, selectHistorySQL, id, mType, nullableTime(from), nullableTime(to))
+	case tier == nil:
+		rows, err = stor.dbPool.Query(ctx, selectHistoryByStepSQL,
+			id, mType, nullableTime(from), nullableTime(to), step.Seconds())
+	default:
+		rows, err = stor.dbPool.Query(ctx, selectRollupsByStepSQL,
+			id, mType, nullableTime(from), nullableTime(to), int64(tier.Resolution.Seconds()), step.Seconds())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]*HistoryPoint, 0)
+	for rows.Next() {
+		point := &HistoryPoint{}
+		storageMetric := &StorageMetric{}
+
+		var delta *int64
+		var value *float64
+		var data []byte
+
+		if step <= 0 {
+			err = rows.Scan(&point.Timestamp, &delta, &value, &data)
+		} else {
+			err = rows.Scan(&point.Timestamp, &delta, &value, &point.Min, &point.Max, &data)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delta != nil {
+			point.Delta = *delta
+		}
+		if value != nil {
+			point.Value = *value
+		}
+		if err = decodeData(mType, data, storageMetric); err != nil {
+			return nil, err
+		}
+		point.Histogram = storageMetric.Histogram
+		point.Summary = storageMetric.Summary
+
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// missing series has no history, existing series may have no points within the range
+	if len(points) == 0 {
+		var exists bool
+		if err := stor.dbPool.QueryRow(ctx, selectMetricExistsSQL, id, mType).Scan(&exists); err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, nil
+		}
+	}
+
+	return points, nil
+}
+
+// Rollup aggregates closed windows of every tier from the previous tier (or raw points)
+// and deletes points and rollups older than their retention at the moment now.
+func (stor *StorageV2) Rollup(ctx context.Context, now time.Time) error {
+	if stor.rollups == nil {
+		return nil
+	}
+
+	for i, tier := range stor.rollups.Tiers {
+		resolution := int64(tier.Resolution.Seconds())
+
+		var last *time.Time
+		if err := stor.dbPool.QueryRow(ctx, selectLastRollupSQL, resolution).Scan(&last); err != nil {
+			return err
+		}
+
+		// the last window is recomputed, it could be aggregated before all the points of previous tier were
+		var from time.Time
+		if last != nil {
+			from = *last
+		}
+		to := now.Truncate(tier.Resolution)
+
+		var err error
+		if i == 0 {
+			_, err = stor.dbPool.Exec(ctx, rollupHistorySQL, resolution, from, to)
+		} else {
+			prevResolution := int64(stor.rollups.Tiers[i-1].Resolution.Seconds())
+			_, err = stor.dbPool.Exec(ctx, rollupRollupsSQL, resolution, from, to, prevResolution)
+		}
+		if err != nil {
+			return fmt.Errorf("rollup %s: %w", tier.Resolution, err)
+		}
+	}
+
+	if _, err := stor.dbPool.Exec(ctx, deleteHistoryBeforeSQL, now.Add(-stor.rollups.Raw)); err != nil {
+		return err
+	}
+
+	for _, tier := range stor.rollups.Tiers {
+		_, err := stor.dbPool.Exec(ctx, deleteRollupsBeforeSQL, int64(tier.Resolution.Seconds()), now.Add(-tier.Retention))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StartRollups runs Rollup every interval until returned function is called.
+func (stor *StorageV2) StartRollups(interval time.Duration) func() {
+	return startTicker(interval, func() {
+		if err := stor.Rollup(context.Background(), time.Now()); err != nil {
+			log.Println("Could not rollup metrics history", err)
+		}
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/GermanVor/devops-pet-project/internal/common"
@@ -16,47 +18,155 @@ import (
 )
 
 type StorageMetric struct {
-	ID    string
-	MType string
-	Delta int64
-	Value float64
+	ID        string
+	MType     string
+	Delta     int64
+	Value     float64
+	Histogram *common.Histogram
+	Summary   *common.Summary
+	Labels    map[string]string
+	// Version of gauge is incremented by every update, see common.Precondition
+	Version uint64
 }
 
+// Key returns series key of the metric.
+func (sm *StorageMetric) Key() string {
+	return common.SeriesKey(sm.ID, sm.Labels)
+}
+
+// Copy returns deep copy of the metric: labels, histogram and summary are not shared.
+func (sm *StorageMetric) Copy() *StorageMetric {
+	metric := *sm
+
+	if sm.Labels != nil {
+		metric.Labels = make(map[string]string, len(sm.Labels))
+		for name, value := range sm.Labels {
+			metric.Labels[name] = value
+		}
+	}
+	if sm.Histogram != nil {
+		metric.Histogram = sm.Histogram.Copy()
+	}
+	if sm.Summary != nil {
+		metric.Summary = sm.Summary.Copy()
+	}
+
+	return &metric
+}
+
+// setKey sets ID and labels of the metric by series key.
+// Key which is not a valid series key is used as ID as is.
+func (sm *StorageMetric) setKey(key string) {
+	sm.ID = key
+	sm.Labels = nil
+
+	if id, labels, err := common.ParseSeriesKey(key); err == nil {
+		sm.ID = id
+		sm.Labels = labels
+	}
+}
+
+// newStorageMetric creates metric of series key, storages keep metrics by series key.
+func newStorageMetric(mType, key string) *StorageMetric {
+	storageMetric := &StorageMetric{MType: mType}
+	storageMetric.setKey(key)
+
+	return storageMetric
+}
+
+// StorageInterface keeps metrics by type and series key (common.SeriesKey),
+// id arguments of methods are series keys.
 type StorageInterface interface {
 	ForEachMetrics(context.Context, func(*StorageMetric)) error
 	GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error)
 	UpdateMetric(ctx context.Context, metric common.Metric) error
 	UpdateMetrics(ctx context.Context, metricsList []common.Metric) error
+	// GetMetricHistory returns points of the metric within [from, to] downsampled by step
+	// (zero step returns all the points). Missing metric gives nil points and nil error.
+	GetMetricHistory(
+		ctx context.Context,
+		mType string,
+		id string,
+		from, to time.Time,
+		step time.Duration,
+	) ([]*HistoryPoint, error)
+	// EvictExpired removes metrics expired by policy at the moment now and returns them.
+	EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error)
+	// ListMetrics returns page of metrics selected by query filter ordered by type and series key.
+	ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error)
+	// DeleteMetric removes the metric with its history, false if there is no such metric.
+	DeleteMetric(ctx context.Context, mType string, id string) (bool, error)
+	// DeleteMetrics removes metrics selected by filter with their history and returns them (type and series key).
+	DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error)
 	Ping(ctx context.Context) error
 }
 
+// storageMethods are names of StorageInterface methods, wrappers use them to select and label calls.
+var storageMethods = map[string]struct{}{
+	"ForEachMetrics":   {},
+	"GetMetric":        {},
+	"UpdateMetric":     {},
+	"UpdateMetrics":    {},
+	"GetMetricHistory": {},
+	"EvictExpired":     {},
+	"ListMetrics":      {},
+	"DeleteMetric":     {},
+	"DeleteMetrics":    {},
+	"Ping":             {},
+}
+
 type StorageV2 struct {
-	dbPool *pgxpool.Pool
+	dbPool  *pgxpool.Pool
+	rollups *RollupPolicy
 }
 
 const (
-	// INSERT INTO metrics (id, mType, delta)
-	// VALUES ($1, $2, $3)
-	// ON CONFLICT (id) DO UPDATE SET delta = metrics.delta EXCLUDED.delta;
-	insertDeltaSQL = "INSERT INTO metrics (id, mType, delta) " +
-		"VALUES ($1, $2, $3) " +
-		"ON CONFLICT (id) DO UPDATE SET delta = metrics.delta + EXCLUDED.delta;"
-
-	// INSERT INTO metrics (id, mType, value)
-	// VALUES ($1, $2, $3)
-	// ON CONFLICT (id) DO UPDATE SET value = EXCLUDED.value;"
-	insertValueSQL = "INSERT INTO metrics (id, mType, value) " +
-		"VALUES ($1, $2, $3) " +
-		"ON CONFLICT (id) DO UPDATE SET value = EXCLUDED.value;"
-
-	// SELECT delta FROM metrics WHERE id=$1
-	selectDeltaSQL = "SELECT delta FROM metrics WHERE id=$1"
-
-	// SELECT value FROM metrics WHERE id=$1
-	selectValueSQL = "SELECT value FROM metrics WHERE id=$1"
-
-	// SELECT id, mType, delta, value FROM metrics
-	selectDeltaValueSQL = "SELECT id, mType, delta, value FROM metrics"
+	// INSERT INTO metrics (id, mType, delta, updated_at)
+	// VALUES ($1, $2, $3, now())
+	// ON CONFLICT (id, mType) DO UPDATE SET delta = metrics.delta + EXCLUDED.delta, updated_at = EXCLUDED.updated_at;
+	insertDeltaSQL = "INSERT INTO metrics (id, mType, delta, updated_at) " +
+		"VALUES ($1, $2, $3, now()) " +
+		"ON CONFLICT (id, mType) DO UPDATE SET delta = metrics.delta + EXCLUDED.delta, updated_at = EXCLUDED.updated_at;"
+
+	// INSERT INTO metrics (id, mType, value, version, updated_at)
+	// VALUES ($1, $2, $3, 1, now())
+	// ON CONFLICT (id, mType) DO UPDATE SET value = EXCLUDED.value, version = metrics.version + 1,
+	// updated_at = EXCLUDED.updated_at;
+	insertValueSQL = "INSERT INTO metrics (id, mType, value, version, updated_at) " +
+		"VALUES ($1, $2, $3, 1, now()) " +
+		"ON CONFLICT (id, mType) DO UPDATE SET value = EXCLUDED.value, version = metrics.version + 1, " +
+		"updated_at = EXCLUDED.updated_at;"
+
+	// SELECT delta FROM metrics WHERE id=$1 AND mType=$2
+	selectDeltaSQL = "SELECT delta FROM metrics WHERE id=$1 AND mType=$2"
+
+	// SELECT value, version FROM metrics WHERE id=$1 AND mType=$2
+	selectValueSQL = "SELECT value, version FROM metrics WHERE id=$1 AND mType=$2"
+
+	// SELECT data FROM metrics WHERE id=$1 AND mType=$2
+	selectDataSQL = "SELECT data FROM metrics WHERE id=$1 AND mType=$2"
+
+	// SELECT data FROM metrics WHERE id=$1 AND mType=$2 FOR UPDATE
+	selectDataForUpdateSQL = "SELECT data FROM metrics WHERE id=$1 AND mType=$2 FOR UPDATE"
+
+	// INSERT INTO metrics (id, mType, data, updated_at)
+	// VALUES ($1, $2, $3, now())
+	// ON CONFLICT (id, mType) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at;
+	insertDataSQL = "INSERT INTO metrics (id, mType, data, updated_at) " +
+		"VALUES ($1, $2, $3, now()) " +
+		"ON CONFLICT (id, mType) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at;"
+
+	// SELECT id, mType, delta, value, data FROM metrics
+	selectDeltaValueSQL = "SELECT id, mType, delta, value, data FROM metrics"
+
+	// SELECT id, mType, updated_at FROM metrics WHERE updated_at < $1
+	selectUpdatedBeforeSQL = "SELECT id, mType, updated_at FROM metrics WHERE updated_at < $1"
+
+	// DELETE FROM metrics WHERE id=$1 AND mType=$2 AND updated_at < $3
+	deleteUpdatedBeforeSQL = "DELETE FROM metrics WHERE id=$1 AND mType=$2 AND updated_at < $3"
+
+	// DELETE FROM metrics WHERE id=$1 AND mType=$2
+	deleteMetricSQL = "DELETE FROM metrics WHERE id=$1 AND mType=$2"
 )
 
 var ErrUnknowMetricType = errors.New("unknown metric type")
@@ -73,23 +183,39 @@ func InitV2(dbContext context.Context, connString string) (*StorageV2, error) {
 
 	log.Printf("Connected to DB %s successfully\n", connString)
 
-	sql := "CREATE TABLE IF NOT EXISTS metrics (" +
-		"id text UNIQUE, " +
-		"mType text, " +
-		"delta bigint DEFAULT 0, " +
-		"value double precision DEFAULT 0" +
-		");"
-
-	_, err = conn.Exec(context.TODO(), sql)
+	migrator, err := NewMigrator(conn)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	log.Println("Created metrics Table successfully")
+	if err = migrator.Up(dbContext); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	log.Println("Database schema is up to date")
 
 	return &StorageV2{dbPool: conn}, nil
 }
 
+// InitMigrator connects to database without applying migrations.
+// Returned function closes the connection.
+func InitMigrator(dbContext context.Context, connString string) (*Migrator, func(), error) {
+	conn, err := pgxpool.Connect(dbContext, connString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return migrator, conn.Close, nil
+}
+
 // Ping checks if the connection to database established
 func (stor *StorageV2) Ping(ctx context.Context) error {
 	return stor.dbPool.Ping(ctx)
@@ -101,15 +227,14 @@ func (stor *StorageV2) Close() {
 
 // ForEachMetrics passes through all metrics in database and call handler
 func (stor *StorageV2) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
-	rows, err := stor.dbPool.Query(context.Background(), selectDeltaValueSQL)
+	rows, err := stor.dbPool.Query(ctx, selectDeltaValueSQL)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		storageMetric := &StorageMetric{}
-
-		err := rows.Scan(&storageMetric.ID, &storageMetric.MType, &storageMetric.Delta, &storageMetric.Value)
+		storageMetric, err := scanMetric(rows)
 		if err != nil {
 			return err
 		}
@@ -117,24 +242,57 @@ func (stor *StorageV2) ForEachMetrics(ctx context.Context, handler func(*Storage
 		handler(storageMetric)
 	}
 
-	return nil
+	return rows.Err()
 }
 
-func (stor *StorageV2) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
-	storageMetric := &StorageMetric{
-		MType: mType,
-		ID:    id,
+// scanMetric scans row of id, mType, delta, value and data columns.
+func scanMetric(rows pgx.Rows) (*StorageMetric, error) {
+	storageMetric := &StorageMetric{}
+
+	// only the column of metric type is not NULL
+	var delta *int64
+	var value *float64
+	var data []byte
+
+	err := rows.Scan(&storageMetric.ID, &storageMetric.MType, &delta, &value, &data)
+	if err != nil {
+		return nil, err
 	}
 
+	storageMetric.setKey(storageMetric.ID)
+
+	if delta != nil {
+		storageMetric.Delta = *delta
+	}
+	if value != nil {
+		storageMetric.Value = *value
+	}
+	if err := decodeData(storageMetric.MType, data, storageMetric); err != nil {
+		return nil, err
+	}
+
+	return storageMetric, nil
+}
+
+func (stor *StorageV2) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	storageMetric := newStorageMetric(mType, id)
+
 	var err error
 
 	switch mType {
 	case common.GaugeMetricName:
-		err = stor.dbPool.QueryRow(ctx, selectValueSQL, id).
-			Scan(&storageMetric.Value)
+		err = stor.dbPool.QueryRow(ctx, selectValueSQL, id, mType).
+			Scan(&storageMetric.Value, &storageMetric.Version)
 	case common.CounterMetricName:
-		err = stor.dbPool.QueryRow(ctx, selectDeltaSQL, id).
+		err = stor.dbPool.QueryRow(ctx, selectDeltaSQL, id, mType).
 			Scan(&storageMetric.Delta)
+	case common.HistogramMetricName, common.SummaryMetricName:
+		var data []byte
+		err = stor.dbPool.QueryRow(ctx, selectDataSQL, id, mType).
+			Scan(&data)
+		if err == nil {
+			err = decodeData(mType, data, storageMetric)
+		}
 	default:
 		err = newUnknownMetricTypeError(mType)
 	}
@@ -150,14 +308,64 @@ func (stor *StorageV2) GetMetric(ctx context.Context, mType string, id string) (
 	return storageMetric, nil
 }
 
-func (stor *StorageV2) UpdateMetric(ctx context.Context, metric common.Metric) error {
+// decodeData sets histogram or summary kept as JSON in data column to storageMetric.
+func decodeData(mType string, data []byte, storageMetric *StorageMetric) error {
+	if data == nil {
+		return nil
+	}
+
+	switch mType {
+	case common.HistogramMetricName:
+		storageMetric.Histogram = &common.Histogram{}
+		return json.Unmarshal(data, storageMetric.Histogram)
+	case common.SummaryMetricName:
+		storageMetric.Summary = &common.Summary{}
+		return json.Unmarshal(data, storageMetric.Summary)
+	}
+
+	return nil
+}
+
+// upsertMetric saves valid metric in transaction, histogram is locked
+// to be merged with the saved one. Conditional gauge is saved by upsertGaugeIf.
+func upsertMetric(ctx context.Context, tx pgx.Tx, metric common.Metric) error {
 	var err error
 
 	switch metric.MType {
 	case common.GaugeMetricName:
-		_, err = stor.dbPool.Exec(ctx, insertValueSQL, metric.ID, metric.MType, *metric.Value)
+		if metric.Precondition != nil {
+			return upsertGaugeIf(ctx, tx, metric)
+		}
+
+		_, err = tx.Exec(ctx, insertValueSQL, metric.Key(), metric.MType, *metric.Value)
 	case common.CounterMetricName:
-		_, err = stor.dbPool.Exec(ctx, insertDeltaSQL, metric.ID, metric.MType, *metric.Delta)
+		_, err = tx.Exec(ctx, insertDeltaSQL, metric.Key(), metric.MType, *metric.Delta)
+	case common.HistogramMetricName:
+		var data []byte
+		err = tx.QueryRow(ctx, selectDataForUpdateSQL, metric.Key(), metric.MType).Scan(&data)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		prev := &StorageMetric{}
+		if err = decodeData(metric.MType, data, prev); err != nil {
+			return err
+		}
+
+		data, err = json.Marshal(prev.Histogram.Merge(metric.Histogram))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, insertDataSQL, metric.Key(), metric.MType, data)
+	case common.SummaryMetricName:
+		var data []byte
+		data, err = json.Marshal(metric.Summary)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, insertDataSQL, metric.Key(), metric.MType, data)
 	default:
 		err = newUnknownMetricTypeError(metric.MType)
 	}
@@ -165,82 +373,375 @@ func (stor *StorageV2) UpdateMetric(ctx context.Context, metric common.Metric) e
 	return err
 }
 
-func (stor *StorageV2) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+func (stor *StorageV2) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+// UpdateMetricsSequential saves metrics in a single transaction with a round trip per metric.
+// It is the former UpdateMetrics kept to compare with batch ingestion.
+func (stor *StorageV2) UpdateMetricsSequential(ctx context.Context, metricsList []common.Metric) error {
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
+
 	tx, err := stor.dbPool.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
 
 	for _, metric := range metricsList {
-		switch metric.MType {
-		case common.GaugeMetricName:
-			_, err = tx.Exec(ctx, insertValueSQL, metric.ID, metric.MType, *metric.Value)
-		case common.CounterMetricName:
-			_, err = tx.Exec(ctx, insertDeltaSQL, metric.ID, metric.MType, *metric.Delta)
-		default:
-			return tx.Rollback(ctx)
+		if err := upsertMetric(ctx, tx, metric); err != nil {
+			return err
 		}
 
-		if err != nil {
-			return tx.Rollback(ctx)
+		if err := stor.pushHistory(ctx, tx, metric.Key(), metric.MType); err != nil {
+			return err
 		}
 	}
 
 	return tx.Commit(ctx)
 }
 
+// EvictExpired deletes metrics not updated for their TTL. Metric updated
+// after it was selected for eviction is kept.
+func (stor *StorageV2) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	minTTL := policy.MinTTL()
+	if minTTL <= 0 {
+		return []*StorageMetric{}, nil
+	}
+
+	rows, err := stor.dbPool.Query(ctx, selectUpdatedBeforeSQL, now.Add(-minTTL))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := make([]*StorageMetric, 0)
+	for rows.Next() {
+		storageMetric := &StorageMetric{}
+
+		var updatedAt time.Time
+		if err := rows.Scan(&storageMetric.ID, &storageMetric.MType, &updatedAt); err != nil {
+			return nil, err
+		}
+		storageMetric.setKey(storageMetric.ID)
+
+		if policy.IsExpired(storageMetric.ID, updatedAt, now) {
+			expired = append(expired, storageMetric)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	tx, err := stor.dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	evicted := make([]*StorageMetric, 0, len(expired))
+	for _, storageMetric := range expired {
+		deadline := now.Add(-policy.TTL(storageMetric.ID))
+
+		tag, err := tx.Exec(ctx, deleteUpdatedBeforeSQL, storageMetric.Key(), storageMetric.MType, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag.RowsAffected() != 0 {
+			if err := deleteHistory(ctx, tx, storageMetric.Key(), storageMetric.MType); err != nil {
+				return nil, err
+			}
+
+			evicted = append(evicted, storageMetric)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return evicted, nil
+}
+
+// deleteMetric deletes the metric with its history in tx.
+func deleteMetric(ctx context.Context, tx pgx.Tx, mType, key string) (bool, error) {
+	tag, err := tx.Exec(ctx, deleteMetricSQL, key, mType)
+	if err != nil || tag.RowsAffected() == 0 {
+		return false, err
+	}
+
+	return true, deleteHistory(ctx, tx, key, mType)
+}
+
+func (stor *StorageV2) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		return false, newUnknownMetricTypeError(mType)
+	}
+
+	tx, err := stor.dbPool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	deleted, err := deleteMetric(ctx, tx, mType, id)
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, tx.Commit(ctx)
+}
+
+// DeleteMetrics lists metrics of filter and deletes them in a single transaction,
+// metric created after listing is kept.
+func (stor *StorageV2) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	page, err := stor.ListMetrics(ctx, ListQuery{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := stor.dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	deleted := make([]*StorageMetric, 0, len(page.Metrics))
+	for _, storageMetric := range page.Metrics {
+		ok, err := deleteMetric(ctx, tx, storageMetric.MType, storageMetric.Key())
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			deleted = append(deleted, storageMetric)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
 type GaugeMetricsStorage map[string]float64
 type CounterMetricsStorage map[string]int64
 
+// HistogramMetricsStorage and SummaryMetricsStorage values are never changed in place,
+// update replaces the value, so they may be shared with readers.
+type HistogramMetricsStorage map[string]*common.Histogram
+type SummaryMetricsStorage map[string]*common.Summary
+
+// DefaultShardsCount is the number of Storage shards used by Init.
+const DefaultShardsCount = 16
+
+// storageShard keeps metrics which ID hash falls into the shard.
+type storageShard struct {
+	gaugeMap     GaugeMetricsStorage
+	counterMap   CounterMetricsStorage
+	histogramMap HistogramMetricsStorage
+	summaryMap   SummaryMetricsStorage
+	historyMap   map[string]*history
+	// updatedMap keeps the last update time of every metric by historyKey
+	updatedMap map[string]time.Time
+	// versionMap and valueTimestampMap keep version and value timestamp of gauges by historyKey
+	versionMap        map[string]uint64
+	valueTimestampMap map[string]time.Time
+	shardRWM          sync.RWMutex
+}
+
+func newStorageShard() *storageShard {
+	return &storageShard{
+		gaugeMap:     make(GaugeMetricsStorage),
+		counterMap:   make(CounterMetricsStorage),
+		histogramMap: make(HistogramMetricsStorage),
+		summaryMap:   make(SummaryMetricsStorage),
+		historyMap:   make(map[string]*history),
+		updatedMap:   make(map[string]time.Time),
+
+		versionMap:        make(map[string]uint64),
+		valueTimestampMap: make(map[string]time.Time),
+	}
+}
+
+// Storage keeps metrics in memory split into shards by metric ID hash,
+// every shard is guarded by its own lock.
 type Storage struct {
-	gaugeMap   GaugeMetricsStorage
-	counterMap CounterMetricsStorage
-	storageRWM sync.RWMutex
+	// walSequence is the sequence number of the last write-ahead log record applied to the storage,
+	// accessed atomically
+	walSequence uint64
+
+	shards []*storageShard
+
+	// restored is set by InitShardedWithCodec if metrics were restored from backup or write-ahead log
+	restored bool
+
+	// historySize is changed under locks of all shards
+	historySize int
 }
 
-// ForEachMetrics passes through all metrics in database and call handler
-func (stor *Storage) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
-	stor.storageRWM.RLock()
-	defer stor.storageRWM.RUnlock()
+func historyKey(mType, id string) string {
+	return mType + ":" + id
+}
+
+func (stor *Storage) shardIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+
+	return int(h.Sum32() % uint32(len(stor.shards)))
+}
+
+func (stor *Storage) shard(id string) *storageShard {
+	return stor.shards[stor.shardIndex(id)]
+}
+
+func (stor *Storage) lockAll() {
+	for _, shard := range stor.shards {
+		shard.shardRWM.Lock()
+	}
+}
+
+func (stor *Storage) unlockAll() {
+	for _, shard := range stor.shards {
+		shard.shardRWM.Unlock()
+	}
+}
+
+func (stor *Storage) rLockAll() {
+	for _, shard := range stor.shards {
+		shard.shardRWM.RLock()
+	}
+}
+
+func (stor *Storage) rUnlockAll() {
+	for _, shard := range stor.shards {
+		shard.shardRWM.RUnlock()
+	}
+}
+
+// SetHistorySize changes the number of points kept for every metric.
+// Already collected history is dropped, non positive size turns history off.
+func (stor *Storage) SetHistorySize(size int) {
+	stor.lockAll()
+	defer stor.unlockAll()
+
+	stor.historySize = size
+	for _, shard := range stor.shards {
+		shard.historyMap = make(map[string]*history)
+	}
+}
+
+// pushHistory saves the current state of the metric, should be called under shard lock.
+func (stor *Storage) pushHistory(shard *storageShard, mType, id string, timestamp time.Time) {
+	if stor.historySize <= 0 {
+		return
+	}
+
+	key := historyKey(mType, id)
 
-	for id, value := range stor.gaugeMap {
-		handler(&StorageMetric{
-			ID:    id,
-			MType: common.GaugeMetricName,
-			Value: value,
-		})
+	h, ok := shard.historyMap[key]
+	if !ok {
+		h = newHistory(stor.historySize)
+		shard.historyMap[key] = h
 	}
-	for id, delta := range stor.counterMap {
-		handler(&StorageMetric{
-			ID:    id,
-			MType: common.CounterMetricName,
-			Delta: delta,
-		})
+
+	point := HistoryPoint{Timestamp: timestamp}
+
+	switch mType {
+	case common.GaugeMetricName:
+		point.Value = shard.gaugeMap[id]
+	case common.CounterMetricName:
+		point.Delta = shard.counterMap[id]
+	case common.HistogramMetricName:
+		point.Histogram = shard.histogramMap[id]
+	case common.SummaryMetricName:
+		point.Summary = shard.summaryMap[id]
+	}
+
+	h.push(point)
+}
+
+func (stor *Storage) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	return listByForEach(ctx, stor.ForEachMetrics, query)
+}
+
+// ForEachMetrics passes through all metrics in database and call handler.
+// Shards are passed one by one, so metrics of different shards may be seen in different moments.
+func (stor *Storage) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
+	for _, shard := range stor.shards {
+		shard.shardRWM.RLock()
+
+		for key, value := range shard.gaugeMap {
+			storageMetric := newStorageMetric(common.GaugeMetricName, key)
+			storageMetric.Value = value
+
+			handler(storageMetric)
+		}
+		for key, delta := range shard.counterMap {
+			storageMetric := newStorageMetric(common.CounterMetricName, key)
+			storageMetric.Delta = delta
+
+			handler(storageMetric)
+		}
+		for key, histogram := range shard.histogramMap {
+			storageMetric := newStorageMetric(common.HistogramMetricName, key)
+			storageMetric.Histogram = histogram
+
+			handler(storageMetric)
+		}
+		for key, summary := range shard.summaryMap {
+			storageMetric := newStorageMetric(common.SummaryMetricName, key)
+			storageMetric.Summary = summary
+
+			handler(storageMetric)
+		}
+
+		shard.shardRWM.RUnlock()
 	}
 
 	return nil
 }
 
 func (stor *Storage) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
-	stor.storageRWM.RLock()
-	defer stor.storageRWM.RUnlock()
+	shard := stor.shard(id)
 
-	storageMetric := &StorageMetric{
-		MType: mType,
-		ID:    id,
-	}
+	shard.shardRWM.RLock()
+	defer shard.shardRWM.RUnlock()
+
+	storageMetric := newStorageMetric(mType, id)
 
 	switch mType {
 	case common.GaugeMetricName:
-		if value, ok := stor.gaugeMap[id]; ok {
+		if value, ok := shard.gaugeMap[id]; ok {
 			storageMetric.Value = value
+			storageMetric.Version = shard.versionMap[historyKey(mType, id)]
 			return storageMetric, nil
 		}
 	case common.CounterMetricName:
-		if delta, ok := stor.counterMap[id]; ok {
+		if delta, ok := shard.counterMap[id]; ok {
 			storageMetric.Delta = delta
 			return storageMetric, nil
 		}
+	case common.HistogramMetricName:
+		if histogram, ok := shard.histogramMap[id]; ok {
+			storageMetric.Histogram = histogram
+			return storageMetric, nil
+		}
+	case common.SummaryMetricName:
+		if summary, ok := shard.summaryMap[id]; ok {
+			storageMetric.Summary = summary
+			return storageMetric, nil
+		}
 	default:
 		return nil, newUnknownMetricTypeError(mType)
 	}
@@ -249,47 +750,261 @@ func (stor *Storage) GetMetric(ctx context.Context, mType string, id string) (*S
 }
 
 func (stor *Storage) UpdateMetric(ctx context.Context, metric common.Metric) error {
-	stor.storageRWM.Lock()
-	defer stor.storageRWM.Unlock()
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+func validateMetrics(metricsList []common.Metric) error {
+	for _, metric := range metricsList {
+		switch metric.MType {
+		case common.GaugeMetricName:
+		case common.CounterMetricName:
+		case common.HistogramMetricName:
+		case common.SummaryMetricName:
+		default:
+			return newUnknownMetricTypeError(metric.MType)
+		}
+
+		if err := metric.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMetrics saves valid metrics shard by shard: gauges and summaries of the same series
+// are overwritten in order of metricsList, counters and histograms are summed.
+// Metrics of one shard are saved atomically.
+func (stor *Storage) applyMetrics(metricsList []common.Metric, timestamp time.Time) {
+	shardsMetrics := make(map[int][]common.Metric)
+	for _, metric := range metricsList {
+		idx := stor.shardIndex(metric.Key())
+		shardsMetrics[idx] = append(shardsMetrics[idx], metric)
+	}
+
+	for idx, shardMetrics := range shardsMetrics {
+		shard := stor.shards[idx]
+
+		shard.shardRWM.Lock()
+
+		for _, metric := range shardMetrics {
+			stor.applyShardMetric(shard, metric, timestamp)
+		}
+
+		shard.shardRWM.Unlock()
+	}
+}
+
+// applyShardMetric saves valid metric ignoring its precondition, should be called under shard lock.
+func (stor *Storage) applyShardMetric(shard *storageShard, metric common.Metric, timestamp time.Time) {
+	key := metric.Key()
 
 	switch metric.MType {
 	case common.GaugeMetricName:
-		stor.gaugeMap[metric.ID] = *metric.Value
+		shard.gaugeMap[key] = *metric.Value
+		shard.versionMap[historyKey(metric.MType, key)]++
+
+		if metric.Precondition != nil && metric.Precondition.Timestamp != nil {
+			shard.valueTimestampMap[historyKey(metric.MType, key)] = *metric.Precondition.Timestamp
+		}
 	case common.CounterMetricName:
-		stor.counterMap[metric.ID] += *metric.Delta
-	default:
-		return newUnknownMetricTypeError(metric.MType)
+		shard.counterMap[key] += *metric.Delta
+	case common.HistogramMetricName:
+		shard.histogramMap[key] = shard.histogramMap[key].Merge(metric.Histogram)
+	case common.SummaryMetricName:
+		shard.summaryMap[key] = metric.Summary.Copy()
+	}
+
+	shard.updatedMap[historyKey(metric.MType, key)] = timestamp
+	stor.pushHistory(shard, metric.MType, key, timestamp)
+}
+
+// gaugeStateLocked returns state of gauge with series key, should be called under shard lock.
+func (stor *Storage) gaugeStateLocked(key string) gaugeState {
+	shard := stor.shard(key)
+	value, ok := shard.gaugeMap[key]
+
+	return gaugeState{
+		exists:    ok,
+		value:     value,
+		version:   shard.versionMap[historyKey(common.GaugeMetricName, key)],
+		timestamp: shard.valueTimestampMap[historyKey(common.GaugeMetricName, key)],
+	}
+}
+
+// gaugeState returns state of gauge with series key.
+func (stor *Storage) gaugeState(key string) gaugeState {
+	shard := stor.shard(key)
+
+	shard.shardRWM.RLock()
+	defer shard.shardRWM.RUnlock()
+
+	return stor.gaugeStateLocked(key)
+}
+
+// applyMetricsIf saves valid metrics under locks of all the shards if all their preconditions are satisfied.
+func (stor *Storage) applyMetricsIf(metricsList []common.Metric, timestamp time.Time) error {
+	stor.lockAll()
+	defer stor.unlockAll()
+
+	if err := checkPreconditions(metricsList, stor.gaugeStateLocked); err != nil {
+		return err
+	}
+
+	for _, metric := range metricsList {
+		stor.applyShardMetric(stor.shard(metric.Key()), metric, timestamp)
 	}
 
 	return nil
 }
 
+// UpdateMetrics validates the whole batch before saving, so either all metrics are saved
+// or none of them. The batch is saved shard by shard: concurrent readers may see
+// a part of the batch already saved, but never a part of one shard's metrics.
+// Batch with preconditions is checked and saved under locks of all the shards.
 func (stor *Storage) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
-	stor.storageRWM.Lock()
-	defer stor.storageRWM.Unlock()
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
 
-	gaugeMap := make(GaugeMetricsStorage)
-	counterMap := make(CounterMetricsStorage)
+	if hasPreconditions(metricsList) {
+		return stor.applyMetricsIf(metricsList, time.Now())
+	}
 
-	for _, metric := range metricsList {
-		switch metric.MType {
-		case common.GaugeMetricName:
-			gaugeMap[metric.ID] = *metric.Value
-		case common.CounterMetricName:
-			counterMap[metric.ID] = *metric.Delta
-		default:
-			return newUnknownMetricTypeError(metric.MType)
+	stor.applyMetrics(metricsList, time.Now())
+
+	return nil
+}
+
+func (stor *Storage) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	switch mType {
+	case common.GaugeMetricName:
+	case common.CounterMetricName:
+	case common.HistogramMetricName:
+	case common.SummaryMetricName:
+	default:
+		return nil, newUnknownMetricTypeError(mType)
+	}
+
+	shard := stor.shard(id)
+
+	shard.shardRWM.RLock()
+	defer shard.shardRWM.RUnlock()
+
+	h, ok := shard.historyMap[historyKey(mType, id)]
+	if !ok {
+		return nil, nil
+	}
+
+	return h.query(from, to, step), nil
+}
+
+// removeShardMap deletes metrics of mType selected by remove from m with their history,
+// should be called under shard lock.
+func removeShardMap[V any](
+	shard *storageShard,
+	m map[string]V,
+	mType string,
+	remove func(*StorageMetric) bool,
+) []*StorageMetric {
+	removed := make([]*StorageMetric, 0)
+
+	for seriesKey := range m {
+		storageMetric := newStorageMetric(mType, seriesKey)
+
+		if remove(storageMetric) {
+			key := historyKey(mType, seriesKey)
+
+			delete(m, seriesKey)
+			delete(shard.historyMap, key)
+			delete(shard.updatedMap, key)
+			delete(shard.versionMap, key)
+			delete(shard.valueTimestampMap, key)
+
+			removed = append(removed, storageMetric)
 		}
 	}
 
-	for key, value := range gaugeMap {
-		stor.gaugeMap[key] = value
+	return removed
+}
+
+// removeShardMetrics deletes metrics selected by remove from the shard, should be called under shard lock.
+func removeShardMetrics(shard *storageShard, remove func(*StorageMetric) bool) []*StorageMetric {
+	removed := removeShardMap(shard, shard.gaugeMap, common.GaugeMetricName, remove)
+	removed = append(removed, removeShardMap(shard, shard.counterMap, common.CounterMetricName, remove)...)
+	removed = append(removed, removeShardMap(shard, shard.histogramMap, common.HistogramMetricName, remove)...)
+	removed = append(removed, removeShardMap(shard, shard.summaryMap, common.SummaryMetricName, remove)...)
+
+	return removed
+}
+
+func (stor *Storage) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted := make([]*StorageMetric, 0)
+
+	for _, shard := range stor.shards {
+		shard.shardRWM.Lock()
+
+		evicted = append(evicted, removeShardMetrics(shard, func(sm *StorageMetric) bool {
+			return policy.IsExpired(sm.ID, shard.updatedMap[historyKey(sm.MType, sm.Key())], now)
+		})...)
+
+		shard.shardRWM.Unlock()
+	}
+
+	return evicted, nil
+}
+
+func (stor *Storage) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	shard := stor.shard(id)
+
+	shard.shardRWM.Lock()
+	defer shard.shardRWM.Unlock()
+
+	var ok bool
+
+	switch mType {
+	case common.GaugeMetricName:
+		_, ok = shard.gaugeMap[id]
+		delete(shard.gaugeMap, id)
+	case common.CounterMetricName:
+		_, ok = shard.counterMap[id]
+		delete(shard.counterMap, id)
+	case common.HistogramMetricName:
+		_, ok = shard.histogramMap[id]
+		delete(shard.histogramMap, id)
+	case common.SummaryMetricName:
+		_, ok = shard.summaryMap[id]
+		delete(shard.summaryMap, id)
+	default:
+		return false, newUnknownMetricTypeError(mType)
 	}
-	for key, delta := range counterMap {
-		stor.counterMap[key] += delta
+
+	key := historyKey(mType, id)
+	delete(shard.historyMap, key)
+	delete(shard.updatedMap, key)
+	delete(shard.versionMap, key)
+	delete(shard.valueTimestampMap, key)
+
+	return ok, nil
+}
+
+// DeleteMetrics deletes metrics shard by shard.
+func (stor *Storage) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted := make([]*StorageMetric, 0)
+
+	for _, shard := range stor.shards {
+		shard.shardRWM.Lock()
+		deleted = append(deleted, removeShardMetrics(shard, filter.Match)...)
+		shard.shardRWM.Unlock()
 	}
 
-	return nil
+	return deleted, nil
 }
 
 func (stor *Storage) Ping(ctx context.Context) error {
@@ -298,32 +1013,86 @@ func (stor *Storage) Ping(ctx context.Context) error {
 
 type BackupStorageWrapper struct {
 	*Storage
-	backupFilePath string
-	fileRWM        sync.Mutex
+	backupConfig BackupConfig
+	fileRWM      sync.Mutex
 }
 
 type BackupObject struct {
-	GaugeMetrics   GaugeMetricsStorage
-	CounterMetrics CounterMetricsStorage
+	GaugeMetrics     GaugeMetricsStorage
+	CounterMetrics   CounterMetricsStorage
+	HistogramMetrics HistogramMetricsStorage `json:",omitempty"`
+	SummaryMetrics   SummaryMetricsStorage   `json:",omitempty"`
+	// WALSequence is the sequence number of the last write-ahead log record included in backup
+	WALSequence uint64 `json:",omitempty"`
+	// UpdatedAt keeps the last update time of metrics by `${mType}:${id}` key
+	UpdatedAt map[string]time.Time `json:",omitempty"`
+	// Versions and ValueTimestamps keep version and value timestamp of gauges by `${mType}:${id}` key
+	Versions        map[string]uint64    `json:",omitempty"`
+	ValueTimestamps map[string]time.Time `json:",omitempty"`
 }
 
-func writeStoreBackup(stor *Storage, backupFilePath string) error {
-	stor.storageRWM.RLock()
+// writeStoreBackup locks all the shards to copy consistent state of Storage.
+// Snapshot (waiting for the lock and copying) and write are timed by backupConfig.Metrics.
+func writeStoreBackup(stor *Storage, backupConfig BackupConfig) error {
+	snapshotDone := backupConfig.Metrics.startBackup(backupSnapshotPhase)
 
 	backup := BackupObject{
-		GaugeMetrics:   stor.gaugeMap,
-		CounterMetrics: stor.counterMap,
+		GaugeMetrics:     make(GaugeMetricsStorage),
+		CounterMetrics:   make(CounterMetricsStorage),
+		HistogramMetrics: make(HistogramMetricsStorage),
+		SummaryMetrics:   make(SummaryMetricsStorage),
+		UpdatedAt:        make(map[string]time.Time),
+		Versions:         make(map[string]uint64),
+		ValueTimestamps:  make(map[string]time.Time),
 	}
 
+	stor.rLockAll()
+
+	for _, shard := range stor.shards {
+		for id, value := range shard.gaugeMap {
+			backup.GaugeMetrics[id] = value
+		}
+		for id, delta := range shard.counterMap {
+			backup.CounterMetrics[id] = delta
+		}
+		for id, histogram := range shard.histogramMap {
+			backup.HistogramMetrics[id] = histogram
+		}
+		for id, summary := range shard.summaryMap {
+			backup.SummaryMetrics[id] = summary
+		}
+		for key, updatedAt := range shard.updatedMap {
+			backup.UpdatedAt[key] = updatedAt
+		}
+		for key, version := range shard.versionMap {
+			backup.Versions[key] = version
+		}
+		for key, timestamp := range shard.valueTimestampMap {
+			backup.ValueTimestamps[key] = timestamp
+		}
+	}
+	backup.WALSequence = atomic.LoadUint64(&stor.walSequence)
+
+	stor.rUnlockAll()
+
+	snapshotDone(nil)
+	writeDone := backupConfig.Metrics.startBackup(backupWritePhase)
+
 	backupBytes, _ := json.Marshal(&backup)
 
-	stor.storageRWM.RUnlock()
+	err := writeBackupFiles(backupConfig, backupBytes)
+	writeDone(err)
 
-	return os.WriteFile(backupFilePath, backupBytes, 0644)
+	return err
 }
 
 func (stor *BackupStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
-	err := stor.Storage.UpdateMetric(ctx, metric)
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+// UpdateMetrics saves metrics and writes backup, metrics are saved even if backup is not written.
+func (stor *BackupStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	err := stor.Storage.UpdateMetrics(ctx, metricsList)
 	if err != nil {
 		return err
 	}
@@ -331,48 +1100,203 @@ func (stor *BackupStorageWrapper) UpdateMetric(ctx context.Context, metric commo
 	stor.fileRWM.Lock()
 	defer stor.fileRWM.Unlock()
 
-	writeStoreBackup(stor.Storage, stor.backupFilePath)
+	if err := writeStoreBackup(stor.Storage, stor.backupConfig); err != nil {
+		log.Println("Could not create backup", err)
+	}
 
 	return nil
 }
 
-func createStorageFromBackup(stor *Storage, initialFilePath string) error {
-	file, err := os.OpenFile(initialFilePath, os.O_RDONLY, 0777)
+func (stor *BackupStorageWrapper) EvictExpired(
+	ctx context.Context,
+	policy *TTLPolicy,
+	now time.Time,
+) ([]*StorageMetric, error) {
+	evicted, err := stor.Storage.EvictExpired(ctx, policy, now)
+	if err != nil || len(evicted) == 0 {
+		return evicted, err
+	}
+
+	stor.fileRWM.Lock()
+	defer stor.fileRWM.Unlock()
+
+	return evicted, writeStoreBackup(stor.Storage, stor.backupConfig)
+}
+
+func (stor *BackupStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	deleted, err := stor.Storage.DeleteMetric(ctx, mType, id)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+
+	stor.fileRWM.Lock()
+	defer stor.fileRWM.Unlock()
+
+	return deleted, writeStoreBackup(stor.Storage, stor.backupConfig)
+}
+
+func (stor *BackupStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted, err := stor.Storage.DeleteMetrics(ctx, filter)
+	if err != nil || len(deleted) == 0 {
+		return deleted, err
+	}
+
+	stor.fileRWM.Lock()
+	defer stor.fileRWM.Unlock()
+
+	return deleted, writeStoreBackup(stor.Storage, stor.backupConfig)
+}
+
+// readBackupFile reads backup file of any format decoded by codec.
+func readBackupFile(filePath string, codec *BackupCodec) (*BackupObject, error) {
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	data, err := codec.Decode(content)
+	if err != nil {
+		return nil, err
 	}
-	defer file.Close()
 
 	backupObject := &BackupObject{
 		GaugeMetrics:   make(GaugeMetricsStorage),
 		CounterMetrics: make(CounterMetricsStorage),
 	}
-	err = json.NewDecoder(file).Decode(backupObject)
+	err = json.Unmarshal(data, backupObject)
+
+	return backupObject, err
+}
+
+// createStorageFromBackup restores stor from backup file
+// falling back to the newest valid timestamped snapshot.
+func createStorageFromBackup(stor *Storage, initialFilePath string, codec *BackupCodec) error {
+	backupObject, err := readBackupFile(initialFilePath, codec)
+
+	if err != nil {
+		snapshots, _ := listSnapshots(initialFilePath)
+
+		for _, snapshot := range snapshots {
+			snapshotObject, snapshotErr := readBackupFile(snapshot, codec)
+			if snapshotErr != nil {
+				log.Println("Snapshot", snapshot, "is not valid,", snapshotErr)
+				continue
+			}
+
+			log.Println("Backup file is not valid,", err, "falling back to snapshot", snapshot)
+			backupObject, err = snapshotObject, nil
+			break
+		}
+	}
 
 	if err == nil {
-		stor.counterMap = backupObject.CounterMetrics
-		stor.gaugeMap = backupObject.GaugeMetrics
+		stor.lockAll()
+		defer stor.unlockAll()
+
+		// metrics from backups without update time live for TTL since restore
+		restoredAt := time.Now()
+		updatedAt := func(mType, id string) time.Time {
+			if t, ok := backupObject.UpdatedAt[historyKey(mType, id)]; ok {
+				return t
+			}
+			return restoredAt
+		}
+
+		for id, value := range backupObject.GaugeMetrics {
+			shard := stor.shard(id)
+			key := historyKey(common.GaugeMetricName, id)
+
+			shard.gaugeMap[id] = value
+			shard.updatedMap[key] = updatedAt(common.GaugeMetricName, id)
+
+			// gauges from backups without versions are saved once
+			shard.versionMap[key] = 1
+			if version, ok := backupObject.Versions[key]; ok {
+				shard.versionMap[key] = version
+			}
+			if timestamp, ok := backupObject.ValueTimestamps[key]; ok {
+				shard.valueTimestampMap[key] = timestamp
+			}
+		}
+		for id, delta := range backupObject.CounterMetrics {
+			shard := stor.shard(id)
+			shard.counterMap[id] = delta
+			shard.updatedMap[historyKey(common.CounterMetricName, id)] = updatedAt(common.CounterMetricName, id)
+		}
+		for id, histogram := range backupObject.HistogramMetrics {
+			shard := stor.shard(id)
+			shard.histogramMap[id] = histogram
+			shard.updatedMap[historyKey(common.HistogramMetricName, id)] = updatedAt(common.HistogramMetricName, id)
+		}
+		for id, summary := range backupObject.SummaryMetrics {
+			shard := stor.shard(id)
+			shard.summaryMap[id] = summary
+			shard.updatedMap[historyKey(common.SummaryMetricName, id)] = updatedAt(common.SummaryMetricName, id)
+		}
+
+		atomic.StoreUint64(&stor.walSequence, backupObject.WALSequence)
 	}
 
 	return err
 }
 
 func Init(initialFilePath *string) (*Storage, error) {
+	return InitSharded(initialFilePath, DefaultShardsCount)
+}
+
+// InitSharded creates Storage with shardsCount shards (at least one).
+func InitSharded(initialFilePath *string, shardsCount int) (*Storage, error) {
+	return InitShardedWithCodec(initialFilePath, shardsCount, nil)
+}
+
+// InitShardedWithCodec creates Storage like InitSharded, compressed and encrypted backup
+// and write-ahead log are decoded by codec.
+func InitShardedWithCodec(initialFilePath *string, shardsCount int, codec *BackupCodec) (*Storage, error) {
+	if shardsCount <= 0 {
+		shardsCount = 1
+	}
+
 	stor := &Storage{
-		gaugeMap:   make(GaugeMetricsStorage),
-		counterMap: make(CounterMetricsStorage),
+		shards:      make([]*storageShard, shardsCount),
+		historySize: DefaultHistorySize,
+	}
+
+	for i := range stor.shards {
+		stor.shards[i] = newStorageShard()
 	}
 
 	var err error
 
 	if initialFilePath != nil {
-		err = createStorageFromBackup(stor, *initialFilePath)
+		err = createStorageFromBackup(stor, *initialFilePath, codec)
 
 		if err == nil {
 			log.Println("Storage is successfully restored from backup")
 		} else {
 			log.Println("Storage is not restored from backup,", err)
 		}
+
+		walFilePath := WALFilePath(*initialFilePath)
+
+		records, walErr := replayWAL(stor, walFilePath, codec)
+		stor.restored = err == nil || records != 0
+		switch {
+		case walErr == nil:
+			log.Printf("%d write-ahead log records are replayed\n", records)
+		case errors.Is(walErr, os.ErrNotExist):
+		case errors.Is(walErr, ErrBackupNotEncrypted) || errors.Is(walErr, ErrBackupKeyRequired) ||
+			errors.Is(walErr, ErrBackupDecrypt):
+			// the log is kept until Server is started with the right key settings
+			return stor, walErr
+		default:
+			// the log is not compacted over, records after the damaged one stay in the moved file
+			path, moveErr := moveWALAside(walFilePath)
+			if moveErr != nil {
+				return stor, fmt.Errorf("%w: %s, could not move it aside: %s", ErrWALCorrupted, walErr, moveErr)
+			}
+
+			log.Printf("%d write-ahead log records are replayed before damaged one, %s, the log is moved to %s\n", records, walErr, path)
+		}
 	}
 
 	return stor, err
@@ -380,15 +1304,16 @@ func Init(initialFilePath *string) (*Storage, error) {
 
 func WithBackup(stor *Storage, backupFilePath string) StorageInterface {
 	return &BackupStorageWrapper{
-		backupFilePath: backupFilePath,
-		Storage:        stor,
+		backupConfig: BackupConfig{FilePath: backupFilePath},
+		Storage:      stor,
 	}
 }
 
 type Empty struct{}
 
-func InitBackupTicker(stor *Storage, backupFilePath string, backupInterval time.Duration) func() {
-	ticker := time.NewTicker(backupInterval)
+// startTicker calls handler every interval until returned function is called.
+func startTicker(interval time.Duration, handler func()) func() {
+	ticker := time.NewTicker(interval)
 	doneFlag := make(chan Empty)
 
 	stopTicker := func() {
@@ -396,16 +1321,14 @@ func InitBackupTicker(stor *Storage, backupFilePath string, backupInterval time.
 	}
 
 	go func() {
+		defer ticker.Stop()
+
 		for {
 			select {
 			case <-doneFlag:
 				return
 			case <-ticker.C:
-				err := writeStoreBackup(stor, backupFilePath)
-
-				if err != nil {
-					log.Println("Could not create backup", err)
-				}
+				handler()
 			}
 		}
 	}()
@@ -413,6 +1336,18 @@ func InitBackupTicker(stor *Storage, backupFilePath string, backupInterval time.
 	return stopTicker
 }
 
+// InitBackupTicker writes backup of stor every backupInterval, backups are timed by backupConfig.Metrics.
+// Returned func stops the ticker.
+func InitBackupTicker(stor *Storage, backupConfig BackupConfig, backupInterval time.Duration) func() {
+	return startTicker(backupInterval, func() {
+		err := writeStoreBackup(stor, backupConfig)
+
+		if err != nil {
+			log.Println("Could not create backup", err)
+		}
+	})
+}
+
 type MockStorage struct {
 	StorageInterface
 
@@ -425,6 +1360,18 @@ type MockStorage struct {
 	UpdateMetricResponse error
 
 	UpdateMetricsResponse error
+
+	GetMetricHistoryResponse      []*HistoryPoint
+	GetMetricHistoryErrorResponse error
+
+	EvictExpiredResponse      []*StorageMetric
+	EvictExpiredErrorResponse error
+
+	DeleteMetricResponse      bool
+	DeleteMetricErrorResponse error
+
+	DeleteMetricsResponse      []*StorageMetric
+	DeleteMetricsErrorResponse error
 }
 
 func (s *MockStorage) ForEachMetrics(ctx context.Context, h func(*StorageMetric)) error {
@@ -435,6 +1382,14 @@ func (s *MockStorage) ForEachMetrics(ctx context.Context, h func(*StorageMetric)
 	return s.ForEachMetricsResponse
 }
 
+func (s *MockStorage) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	if s.ForEachMetricsResponse != nil {
+		return nil, s.ForEachMetricsResponse
+	}
+
+	return listByForEach(ctx, s.ForEachMetrics, query)
+}
+
 func (s *MockStorage) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
 	return s.GetMetricResponse, s.GetMetricErrorResponse
 }
@@ -447,6 +1402,28 @@ func (s *MockStorage) UpdateMetrics(ctx context.Context, metricsList []common.Me
 	return s.UpdateMetricsResponse
 }
 
+func (s *MockStorage) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	return s.GetMetricHistoryResponse, s.GetMetricHistoryErrorResponse
+}
+
+func (s *MockStorage) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	return s.EvictExpiredResponse, s.EvictExpiredErrorResponse
+}
+
+func (s *MockStorage) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	return s.DeleteMetricResponse, s.DeleteMetricErrorResponse
+}
+
+func (s *MockStorage) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	return s.DeleteMetricsResponse, s.DeleteMetricsErrorResponse
+}
+
 func (s *MockStorage) Ping(ctx context.Context) error {
 	return nil
 }
@@ -1,14 +1,28 @@
 package storage_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/storage"
+	"github.com/GermanVor/devops-pet-project/internal/storage/storagetest"
 	"github.com/bmizerany/assert"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v4"
 	"github.com/stretchr/testify/require"
 )
 
@@ -124,3 +138,2756 @@ func TestMain(t *testing.T) {
 		compareMaps(t, counterMetrics, backupObject.CounterMetrics)
 	})
 }
+
+func TestHistory(t *testing.T) {
+	stor, _ := storage.Init(nil)
+	stor.SetHistorySize(3)
+
+	from := time.Now()
+
+	for i := 1; i <= 4; i++ {
+		value := float64(i)
+		delta := int64(i)
+
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.GaugeMetricName,
+			ID:    "gauge",
+			Value: &value,
+		}))
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{{
+			MType: common.CounterMetricName,
+			ID:    "counter",
+			Delta: &delta,
+		}}))
+	}
+
+	t.Run("Gauge keeps last points", func(t *testing.T) {
+		points, err := stor.GetMetricHistory(context.TODO(), common.GaugeMetricName, "gauge", from, time.Time{}, 0)
+		require.NoError(t, err)
+		require.Len(t, points, 3)
+
+		for i, p := range points {
+			assert.Equal(t, float64(i+2), p.Value)
+		}
+	})
+
+	t.Run("Counter keeps accumulated values", func(t *testing.T) {
+		points, err := stor.GetMetricHistory(context.TODO(), common.CounterMetricName, "counter", time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+		require.Len(t, points, 3)
+
+		assert.Equal(t, int64(3), points[0].Delta)
+		assert.Equal(t, int64(6), points[1].Delta)
+		assert.Equal(t, int64(10), points[2].Delta)
+	})
+
+	t.Run("Step keeps last point of window", func(t *testing.T) {
+		points, err := stor.GetMetricHistory(context.TODO(), common.GaugeMetricName, "gauge", from, time.Time{}, time.Hour)
+		require.NoError(t, err)
+		require.Len(t, points, 1)
+
+		assert.Equal(t, float64(4), points[0].Value)
+		assert.Equal(t, true, points[0].Timestamp.Equal(from))
+	})
+
+	t.Run("Range out of history", func(t *testing.T) {
+		points, err := stor.GetMetricHistory(context.TODO(), common.GaugeMetricName, "gauge", time.Time{}, from.Add(-time.Second), 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(points))
+	})
+
+	t.Run("Missing metric", func(t *testing.T) {
+		points, err := stor.GetMetricHistory(context.TODO(), common.GaugeMetricName, "qwerty", time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, ([]*storage.HistoryPoint)(nil), points)
+	})
+}
+
+func TestDownsample(t *testing.T) {
+	origin := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	points := []*storage.HistoryPoint{
+		{Timestamp: origin.Add(10 * time.Second), Value: 1},
+		{Timestamp: origin.Add(50 * time.Second), Value: 2},
+		{Timestamp: origin.Add(70 * time.Second), Value: 3},
+		{Timestamp: origin.Add(190 * time.Second), Value: 4},
+	}
+
+	res := storage.Downsample(points, time.Time{}, time.Minute)
+	require.Len(t, res, 3)
+
+	assert.Equal(t, float64(2), res[0].Value)
+	assert.Equal(t, origin, res[0].Timestamp)
+	assert.Equal(t, float64(3), res[1].Value)
+	assert.Equal(t, origin.Add(time.Minute), res[1].Timestamp)
+	assert.Equal(t, float64(4), res[2].Value)
+	assert.Equal(t, origin.Add(3*time.Minute), res[2].Timestamp)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := storage.LoadMigrations()
+	require.NoError(t, err)
+	require.NotEqual(t, 0, len(migrations))
+
+	for i, migration := range migrations {
+		assert.NotEqual(t, "", migration.Up)
+		assert.NotEqual(t, "", migration.Down)
+
+		if i > 0 {
+			assert.Equal(t, true, migrations[i-1].Version < migration.Version)
+		}
+	}
+}
+
+func TestSameIDDifferentTypes(t *testing.T) {
+	stor, _ := storage.Init(nil)
+
+	value := float64(24)
+	delta := int64(5)
+
+	require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+		{MType: common.GaugeMetricName, ID: "qwerty", Value: &value},
+		{MType: common.CounterMetricName, ID: "qwerty", Delta: &delta},
+	}))
+
+	gauge, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "qwerty")
+	require.NoError(t, err)
+	assert.Equal(t, value, gauge.Value)
+
+	counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "qwerty")
+	require.NoError(t, err)
+	assert.Equal(t, delta, counter.Delta)
+}
+
+func TestWAL(t *testing.T) {
+	backupFileName := t.TempDir() + "/backup.json"
+
+	baseStor, _ := storage.Init(nil)
+	stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+	require.NoError(t, err)
+
+	// empty storage is not compacted at start
+	_, err = os.Stat(backupFileName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	value := float64(24)
+	delta := int64(5)
+
+	require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+		MType: common.GaugeMetricName,
+		ID:    "qwerty1",
+		Value: &value,
+	}))
+	require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+		{MType: common.CounterMetricName, ID: "qwerty2", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "qwerty3", Delta: &delta},
+	}))
+	require.Error(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+		{MType: "qwerty", ID: "qwerty4"},
+	}))
+
+	checkStorage := func(t *testing.T, stor *storage.Storage) {
+		gauge, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "qwerty1")
+		require.NoError(t, err)
+		require.NotNil(t, gauge)
+		assert.Equal(t, value, gauge.Value)
+
+		for _, id := range []string{"qwerty2", "qwerty3"} {
+			counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, id)
+			require.NoError(t, err)
+			require.NotNil(t, counter)
+			assert.Equal(t, delta, counter.Delta)
+		}
+	}
+
+	t.Run("Replay log with unfinished record", func(t *testing.T) {
+		walFile, err := os.OpenFile(storage.WALFilePath(backupFileName), os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = walFile.WriteString(`{"seq":3,"metrics":[{"id":"qwe`)
+		require.NoError(t, err)
+		require.NoError(t, walFile.Close())
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		checkStorage(t, restoredStor)
+	})
+
+	t.Run("Compact log", func(t *testing.T) {
+		require.NoError(t, stor.Compact())
+
+		walInfo, err := os.Stat(storage.WALFilePath(backupFileName))
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), walInfo.Size())
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		checkStorage(t, restoredStor)
+	})
+
+	stor.Close()
+
+	t.Run("Backup which is not restored is replaced at start", func(t *testing.T) {
+		emptyStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(emptyStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+		defer stor.Close()
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		gauge, err := restoredStor.GetMetric(context.TODO(), common.GaugeMetricName, "qwerty1")
+		require.NoError(t, err)
+		require.Nil(t, gauge)
+	})
+}
+
+func TestWALCorruptedRecord(t *testing.T) {
+	backupFileName := t.TempDir() + "/backup.json"
+	walFileName := storage.WALFilePath(backupFileName)
+
+	// damaged record is followed by a valid one
+	walContent := `{"seq":1,"metrics":[{"id":"PollCount","type":"counter","delta":1}]}
+{"seq":2,"metrics":[{"id":"PollCount","ty
+{"seq":3,"metrics":[{"id":"PollCount","type":"counter","delta":2}]}
+`
+	require.NoError(t, os.WriteFile(walFileName, []byte(walContent), 0644))
+
+	// there is no backup, records before the damaged one are replayed
+	restoredStor, err := storage.Init(&backupFileName)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	counter, err := restoredStor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(1), counter.Delta)
+
+	// compaction of the new log does not truncate the damaged one
+	stor, err := storage.WithWAL(restoredStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+	require.NoError(t, err)
+	stor.Close()
+
+	moved, err := filepath.Glob(walFileName + ".corrupt-*")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(moved))
+
+	movedContent, err := os.ReadFile(moved[0])
+	require.NoError(t, err)
+	assert.Equal(t, walContent, string(movedContent))
+}
+
+func TestBackupSnapshots(t *testing.T) {
+	backupFileName := t.TempDir() + "/backup.json"
+	backupConfig := storage.BackupConfig{FilePath: backupFileName, Retention: 2}
+
+	baseStor, _ := storage.Init(nil)
+	stor, err := storage.WithWAL(baseStor, backupConfig, 0)
+	require.NoError(t, err)
+	defer stor.Close()
+
+	for i := 1; i <= 3; i++ {
+		value := float64(i)
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.GaugeMetricName,
+			ID:    "qwerty",
+			Value: &value,
+		}))
+		require.NoError(t, stor.Compact())
+	}
+
+	snapshots, err := filepath.Glob(backupFileName + ".snapshot-*")
+	require.NoError(t, err)
+	assert.Equal(t, backupConfig.Retention, len(snapshots))
+
+	tmpFiles, err := filepath.Glob(backupFileName + ".tmp-*")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(tmpFiles))
+
+	t.Run("Restore from snapshot if backup is damaged", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(backupFileName, []byte(`{"GaugeMetrics":{"qwe`), 0644))
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		gauge, err := restoredStor.GetMetric(context.TODO(), common.GaugeMetricName, "qwerty")
+		require.NoError(t, err)
+		require.NotNil(t, gauge)
+		assert.Equal(t, float64(3), gauge.Value)
+	})
+}
+
+func TestBackupCodec(t *testing.T) {
+	key := strings.Repeat("0f", 32)
+
+	_, err := storage.NewBackupCodec("lz4", "")
+	require.Error(t, err)
+	_, err = storage.NewBackupCodec("gzip", "0f0f")
+	require.Error(t, err)
+
+	value := float64(24)
+	delta := int64(5)
+
+	writeBackup := func(t *testing.T, codec *storage.BackupCodec) string {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName, Codec: codec}, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{MType: common.GaugeMetricName, ID: "Secret", Value: &value}))
+		require.NoError(t, stor.Compact())
+
+		// the log is not compacted after the counter, so it is restored from the log record
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{MType: common.CounterMetricName, ID: "Logged", Delta: &delta}))
+
+		return backupFileName
+	}
+
+	checkRestored := func(t *testing.T, backupFileName string, codec *storage.BackupCodec) {
+		stor, err := storage.InitShardedWithCodec(&backupFileName, 4, codec)
+		require.NoError(t, err)
+
+		gauge, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Secret")
+		require.NoError(t, err)
+		require.NotNil(t, gauge)
+		assert.Equal(t, value, gauge.Value)
+
+		counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "Logged")
+		require.NoError(t, err)
+		require.NotNil(t, counter)
+		assert.Equal(t, delta, counter.Delta)
+	}
+
+	for _, compression := range []string{"", "none", "gzip", "zstd"} {
+		for _, encryptionKey := range []string{"", key} {
+			name := fmt.Sprintf("Compression %q, encrypted %v", compression, encryptionKey != "")
+
+			t.Run(name, func(t *testing.T) {
+				codec, err := storage.NewBackupCodec(compression, encryptionKey)
+				require.NoError(t, err)
+				assert.Equal(t, encryptionKey != "", codec.Encrypted())
+
+				backupFileName := writeBackup(t, codec)
+				checkRestored(t, backupFileName, codec)
+
+				if encryptionKey == "" {
+					// format is detected, so compressed backup is restored by any codec
+					checkRestored(t, backupFileName, nil)
+					return
+				}
+
+				for _, fileName := range []string{backupFileName, storage.WALFilePath(backupFileName)} {
+					content, err := os.ReadFile(fileName)
+					require.NoError(t, err)
+					assert.Equal(t, false, bytes.Contains(content, []byte("Secret")) || bytes.Contains(content, []byte("Logged")))
+				}
+
+				_, err = storage.InitShardedWithCodec(&backupFileName, 4, nil)
+				require.ErrorIs(t, err, storage.ErrBackupKeyRequired)
+
+				otherCodec, err := storage.NewBackupCodec(compression, strings.Repeat("f0", 32))
+				require.NoError(t, err)
+				_, err = storage.InitShardedWithCodec(&backupFileName, 4, otherCodec)
+				require.ErrorIs(t, err, storage.ErrBackupDecrypt)
+
+				// the log read with the wrong key is kept, so its records are replayed with the right one
+				require.NoError(t, os.Remove(backupFileName))
+				_, err = storage.InitShardedWithCodec(&backupFileName, 4, otherCodec)
+				require.ErrorIs(t, err, storage.ErrBackupDecrypt)
+
+				moved, err := filepath.Glob(storage.WALFilePath(backupFileName) + ".corrupt-*")
+				require.NoError(t, err)
+				assert.Equal(t, 0, len(moved))
+
+				stor, err := storage.InitShardedWithCodec(&backupFileName, 4, codec)
+				require.ErrorIs(t, err, os.ErrNotExist)
+
+				counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "Logged")
+				require.NoError(t, err)
+				require.NotNil(t, counter)
+				assert.Equal(t, delta, counter.Delta)
+			})
+		}
+	}
+
+	t.Run("Unencrypted backup is restored by codec with key only for migration", func(t *testing.T) {
+		codec, err := storage.NewBackupCodec("zstd", key)
+		require.NoError(t, err)
+
+		plainCodec, err := storage.NewBackupCodec("gzip", "")
+		require.NoError(t, err)
+
+		for _, writeCodec := range []*storage.BackupCodec{nil, plainCodec} {
+			backupFileName := writeBackup(t, writeCodec)
+
+			_, err = storage.InitShardedWithCodec(&backupFileName, 4, codec)
+			require.ErrorIs(t, err, storage.ErrBackupNotEncrypted)
+
+			// the log is checked too
+			require.NoError(t, os.Remove(backupFileName))
+			_, err = storage.InitShardedWithCodec(&backupFileName, 4, codec)
+			require.ErrorIs(t, err, storage.ErrBackupNotEncrypted)
+		}
+
+		migrationCodec, err := storage.NewBackupCodec("zstd", key)
+		require.NoError(t, err)
+		migrationCodec.AllowUnencrypted()
+
+		checkRestored(t, writeBackup(t, nil), migrationCodec)
+		checkRestored(t, writeBackup(t, plainCodec), migrationCodec)
+	})
+
+	t.Run("Moved log records are not replayed", func(t *testing.T) {
+		codec, err := storage.NewBackupCodec("", key)
+		require.NoError(t, err)
+
+		backupFileName := t.TempDir() + "/backup.json"
+		walFileName := storage.WALFilePath(backupFileName)
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName, Codec: codec}, 0)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{MType: common.CounterMetricName, ID: "Logged", Delta: &delta}))
+		}
+
+		content, err := os.ReadFile(walFileName)
+		require.NoError(t, err)
+		lines := strings.SplitAfter(string(content), "\n")
+		require.Equal(t, 4, len(lines))
+
+		// backup written by compaction is removed, so only the log is replayed
+		stor.Close()
+		require.NoError(t, os.Remove(backupFileName))
+
+		// the number of records replayed before the moved one
+		for changed, replayed := range map[string]int64{
+			// repeated record
+			lines[0] + lines[1] + lines[1] + lines[2]: 2,
+			// reordered records
+			lines[0] + lines[2] + lines[1]: 1,
+			// record with changed sequence number
+			lines[0] + strings.Replace(lines[2], "!3:", "!2:", 1): 1,
+		} {
+			require.NoError(t, os.WriteFile(walFileName, []byte(changed), 0644))
+
+			restoredStor, err := storage.InitShardedWithCodec(&backupFileName, 4, codec)
+			require.ErrorIs(t, err, os.ErrNotExist)
+
+			counter, err := restoredStor.GetMetric(context.TODO(), common.CounterMetricName, "Logged")
+			require.NoError(t, err)
+			require.NotNil(t, counter)
+			assert.Equal(t, delta*replayed, counter.Delta)
+		}
+	})
+
+	t.Run("Changed backup is not restored", func(t *testing.T) {
+		codec, err := storage.NewBackupCodec("gzip", key)
+		require.NoError(t, err)
+
+		backupFileName := writeBackup(t, codec)
+
+		content, err := os.ReadFile(backupFileName)
+		require.NoError(t, err)
+
+		// header is authenticated too
+		for _, i := range []int{5, len(content) - 1} {
+			changed := append([]byte{}, content...)
+			changed[i] ^= 1
+			require.NoError(t, os.WriteFile(backupFileName, changed, 0644))
+
+			_, err = storage.InitShardedWithCodec(&backupFileName, 4, codec)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestBoltStorage(t *testing.T) {
+	dbFileName := t.TempDir() + "/metrics.db"
+
+	stor, err := storage.InitBolt(dbFileName)
+	require.NoError(t, err)
+	stor.SetHistorySize(2)
+
+	value := float64(24)
+	delta := int64(5)
+
+	require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+		MType: common.GaugeMetricName,
+		ID:    "qwerty",
+		Value: &value,
+	}))
+	require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+		{MType: common.CounterMetricName, ID: "qwerty", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "qwerty", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "qwerty", Delta: &delta},
+	}))
+
+	t.Run("Batch with unknown type is not saved", func(t *testing.T) {
+		err := stor.UpdateMetrics(context.TODO(), []common.Metric{
+			{MType: common.CounterMetricName, ID: "qwerty", Delta: &delta},
+			{MType: "qwerty", ID: "qwerty"},
+		})
+		require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+	})
+
+	t.Run("Reopen database", func(t *testing.T) {
+		stor.Close()
+
+		stor, err = storage.InitBolt(dbFileName)
+		require.NoError(t, err)
+		stor.SetHistorySize(2)
+
+		gauge, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "qwerty")
+		require.NoError(t, err)
+		require.NotNil(t, gauge)
+		assert.Equal(t, value, gauge.Value)
+
+		counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "qwerty")
+		require.NoError(t, err)
+		require.NotNil(t, counter)
+		assert.Equal(t, 3*delta, counter.Delta)
+
+		missing, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "qwe")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), missing)
+
+		count := 0
+		require.NoError(t, stor.ForEachMetrics(context.TODO(), func(sm *storage.StorageMetric) {
+			count++
+		}))
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+				MType: common.CounterMetricName,
+				ID:    "qwerty",
+				Delta: &delta,
+			}))
+		}
+
+		points, err := stor.GetMetricHistory(context.TODO(), common.CounterMetricName, "qwerty", time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+		require.Len(t, points, 2)
+
+		assert.Equal(t, 5*delta, points[0].Delta)
+		assert.Equal(t, 6*delta, points[1].Delta)
+	})
+
+	stor.Close()
+}
+
+func TestShardedStorage(t *testing.T) {
+	stor, _ := storage.InitSharded(nil, 4)
+
+	delta := int64(1)
+	metrics := make([]common.Metric, 0)
+	for i := 0; i < 32; i++ {
+		metrics = append(metrics, common.Metric{
+			ID:    fmt.Sprint("qwerty", i),
+			MType: common.CounterMetricName,
+			Delta: &delta,
+		})
+	}
+
+	// counters with the same ID are summed within a batch
+	batch := append(metrics, metrics[0])
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, stor.UpdateMetrics(context.TODO(), batch))
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	require.NoError(t, stor.ForEachMetrics(context.TODO(), func(sm *storage.StorageMetric) {
+		count++
+
+		if sm.ID == metrics[0].ID {
+			assert.Equal(t, int64(16), sm.Delta)
+		} else {
+			assert.Equal(t, int64(8), sm.Delta)
+		}
+	}))
+	assert.Equal(t, len(metrics), count)
+}
+
+func TestTTLPolicy(t *testing.T) {
+	policy, err := storage.NewTTLPolicy(time.Hour, "Heap*=10m, Poll*=0s")
+	require.NoError(t, err)
+
+	assert.Equal(t, 10*time.Minute, policy.TTL("HeapAlloc"))
+	assert.Equal(t, time.Duration(0), policy.TTL("PollCount"))
+	assert.Equal(t, time.Hour, policy.TTL("Alloc"))
+	assert.Equal(t, 10*time.Minute, policy.MinTTL())
+
+	now := time.Now()
+	assert.Equal(t, true, policy.IsExpired("HeapAlloc", now.Add(-11*time.Minute), now))
+	assert.Equal(t, false, policy.IsExpired("Alloc", now.Add(-11*time.Minute), now))
+	assert.Equal(t, false, policy.IsExpired("PollCount", now.Add(-24*time.Hour), now))
+
+	for _, rules := range []string{"Heap*", "Heap*=qwerty", "[=1h"} {
+		_, err := storage.NewTTLPolicy(0, rules)
+		require.Error(t, err)
+	}
+}
+
+func TestEvictExpired(t *testing.T) {
+	policy, err := storage.NewTTLPolicy(time.Hour, "Poll*=0s")
+	require.NoError(t, err)
+
+	value := float64(24)
+	delta := int64(5)
+	metrics := []common.Metric{
+		{MType: common.GaugeMetricName, ID: "Alloc", Value: &value},
+		{MType: common.CounterMetricName, ID: "Alloc", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta},
+	}
+
+	checkEviction := func(t *testing.T, stor storage.StorageInterface) {
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		evicted, err := stor.EvictExpired(context.TODO(), policy, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(evicted))
+
+		evicted, err = stor.EvictExpired(context.TODO(), policy, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(evicted))
+
+		for _, mType := range []string{common.GaugeMetricName, common.CounterMetricName} {
+			metric, err := stor.GetMetric(context.TODO(), mType, "Alloc")
+			require.NoError(t, err)
+			assert.Equal(t, (*storage.StorageMetric)(nil), metric)
+
+			points, err := stor.GetMetricHistory(context.TODO(), mType, "Alloc", time.Time{}, time.Time{}, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, len(points))
+		}
+
+		counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		require.NotNil(t, counter)
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkEviction(t, stor)
+	})
+
+	t.Run("Write-ahead log", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkEviction(t, stor)
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		gauge, err := restoredStor.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), gauge)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkEviction(t, stor)
+	})
+
+	t.Run("Update time is restored from backup", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+		stor.Close()
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		evicted, err := restoredStor.EvictExpired(context.TODO(), policy, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(evicted))
+	})
+}
+
+func TestJanitor(t *testing.T) {
+	policy, err := storage.NewTTLPolicy(time.Hour, "")
+	require.NoError(t, err)
+
+	stor := &storage.MockStorage{
+		EvictExpiredResponse: []*storage.StorageMetric{
+			{MType: common.GaugeMetricName, ID: "Alloc"},
+		},
+	}
+	janitor := storage.NewJanitor(stor, policy)
+
+	require.NoError(t, janitor.Run(context.TODO(), time.Now()))
+	require.NoError(t, janitor.Run(context.TODO(), time.Now()))
+	assert.Equal(t, uint64(2), janitor.Evicted())
+}
+
+func TestSeriesKey(t *testing.T) {
+	labels := map[string]string{"region": "eu", "host": `web "1"`}
+
+	seriesKey := common.SeriesKey("HeapAlloc", labels)
+	assert.Equal(t, `HeapAlloc{host="web \"1\"",region="eu"}`, seriesKey)
+
+	id, parsedLabels, err := common.ParseSeriesKey(seriesKey)
+	require.NoError(t, err)
+	assert.Equal(t, "HeapAlloc", id)
+	assert.Equal(t, labels, parsedLabels)
+
+	assert.Equal(t, "HeapAlloc", common.SeriesKey("HeapAlloc", nil))
+
+	for _, key := range []string{`HeapAlloc{host="a"`, `HeapAlloc{host=a}`, `HeapAlloc{host-name="a"}`} {
+		_, _, err := common.ParseSeriesKey(key)
+		require.Error(t, err)
+	}
+
+	t.Run("Label matchers", func(t *testing.T) {
+		matchers, err := common.ParseLabelMatchers(`{host=~"web.*", region!="us", env!~"test|dev", dc=""}`)
+		require.NoError(t, err)
+		require.Len(t, matchers, 4)
+
+		assert.Equal(t, true, common.MatchLabels(matchers, labels))
+		assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "web", "region": "us"}))
+		assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "web", "env": "dev"}))
+		assert.Equal(t, false, common.MatchLabels(matchers, map[string]string{"host": "db"}))
+
+		for _, selector := range []string{`{host="a"`, `{host~"a"}`, `{host="a" region="b"}`, `{host=~"("}`} {
+			_, err := common.ParseLabelMatchers(selector)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestMetricLabels(t *testing.T) {
+	value1 := float64(1)
+	value2 := float64(2)
+	delta := int64(5)
+
+	metrics := []common.Metric{
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value1, Labels: map[string]string{"host": "a"}},
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value2, Labels: map[string]string{"host": "b"}},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta, Labels: map[string]string{"host": "a"}},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta},
+	}
+
+	checkLabels := func(t *testing.T, stor storage.StorageInterface) {
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		for _, metric := range metrics {
+			storageMetric, err := stor.GetMetric(context.TODO(), metric.MType, metric.Key())
+			require.NoError(t, err)
+			require.NotNil(t, storageMetric)
+
+			assert.Equal(t, metric.ID, storageMetric.ID)
+			assert.Equal(t, metric.Labels, storageMetric.Labels)
+		}
+
+		gauge, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, `HeapAlloc{host="b"}`)
+		require.NoError(t, err)
+		assert.Equal(t, value2, gauge.Value)
+
+		seen := make(map[string]bool)
+		require.NoError(t, stor.ForEachMetrics(context.TODO(), func(sm *storage.StorageMetric) {
+			seen[sm.MType+":"+sm.Key()] = true
+		}))
+		assert.Equal(t, len(metrics), len(seen))
+
+		require.Error(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType:  common.GaugeMetricName,
+			ID:     "HeapAlloc",
+			Value:  &value1,
+			Labels: map[string]string{"host name": "a"},
+		}))
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkLabels(t, stor)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkLabels(t, stor)
+	})
+
+	t.Run("Write-ahead log and backup", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		// there is no backup until compaction, metrics are replayed from the log
+		restoredStor, err := storage.Init(&backupFileName)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		gauge, err := restoredStor.GetMetric(context.TODO(), common.GaugeMetricName, `HeapAlloc{host="a"}`)
+		require.NoError(t, err)
+		require.NotNil(t, gauge)
+		assert.Equal(t, value1, gauge.Value)
+
+		stor.Close()
+	})
+}
+
+func TestHistogramAndSummary(t *testing.T) {
+	newHistogram := func(first, second, count uint64, sum float64) *common.Histogram {
+		return &common.Histogram{
+			Buckets: []common.HistogramBucket{{UpperBound: 0.1, Count: first}, {UpperBound: 1, Count: second}},
+			Sum:     sum,
+			Count:   count,
+		}
+	}
+
+	summary := &common.Summary{
+		Quantiles: []common.SummaryQuantile{{Quantile: 0.5, Value: 0.2}, {Quantile: 0.99, Value: 1.5}},
+		Sum:       4,
+		Count:     10,
+	}
+
+	checkDistributions := func(t *testing.T, stor storage.StorageInterface) {
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+			{MType: common.HistogramMetricName, ID: "Latency", Histogram: newHistogram(1, 2, 3, 1.5)},
+			{MType: common.SummaryMetricName, ID: "Latency", Summary: &common.Summary{Sum: 1, Count: 1}},
+		}))
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+			{MType: common.HistogramMetricName, ID: "Latency", Histogram: newHistogram(2, 3, 4, 2)},
+			{MType: common.SummaryMetricName, ID: "Latency", Summary: summary},
+		}))
+
+		histogramMetric, err := stor.GetMetric(context.TODO(), common.HistogramMetricName, "Latency")
+		require.NoError(t, err)
+		require.NotNil(t, histogramMetric)
+		assert.Equal(t, newHistogram(3, 5, 7, 3.5), histogramMetric.Histogram)
+
+		summaryMetric, err := stor.GetMetric(context.TODO(), common.SummaryMetricName, "Latency")
+		require.NoError(t, err)
+		require.NotNil(t, summaryMetric)
+		assert.Equal(t, summary, summaryMetric.Summary)
+
+		// changed buckets reset the histogram
+		resetHistogram := &common.Histogram{Buckets: []common.HistogramBucket{{UpperBound: 5, Count: 1}}, Sum: 3, Count: 1}
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType:     common.HistogramMetricName,
+			ID:        "Latency",
+			Histogram: resetHistogram,
+		}))
+
+		histogramMetric, err = stor.GetMetric(context.TODO(), common.HistogramMetricName, "Latency")
+		require.NoError(t, err)
+		assert.Equal(t, resetHistogram, histogramMetric.Histogram)
+
+		err = stor.UpdateMetric(context.TODO(), common.Metric{
+			MType:     common.HistogramMetricName,
+			ID:        "Latency",
+			Histogram: newHistogram(3, 1, 3, 1),
+		})
+		require.ErrorIs(t, err, common.ErrInvalidHistogram)
+
+		err = stor.UpdateMetric(context.TODO(), common.Metric{MType: common.SummaryMetricName, ID: "Latency"})
+		require.ErrorIs(t, err, common.ErrInvalidSummary)
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkDistributions(t, stor)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkDistributions(t, stor)
+	})
+
+	t.Run("Write-ahead log and backup", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+
+		checkDistributions(t, stor)
+		stor.Close()
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+
+		summaryMetric, err := restoredStor.GetMetric(context.TODO(), common.SummaryMetricName, "Latency")
+		require.NoError(t, err)
+		require.NotNil(t, summaryMetric)
+		assert.Equal(t, summary, summaryMetric.Summary)
+	})
+}
+
+func TestRateTracker(t *testing.T) {
+	start := time.Now()
+
+	tracker, err := storage.NewRateTracker(time.Minute, "Poll*, Requests")
+	require.NoError(t, err)
+
+	assert.Equal(t, true, tracker.IsCumulative("PollCount"))
+	assert.Equal(t, false, tracker.IsCumulative("RandomValue"))
+
+	_, ok := tracker.Rate("RandomValue", start)
+	assert.Equal(t, false, ok)
+
+	t.Run("Rate", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			tracker.Observe("RandomValue", 10, start.Add(time.Duration(i)*10*time.Second))
+		}
+
+		rate, ok := tracker.Rate("RandomValue", start.Add(40*time.Second))
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 1.0, rate)
+
+		// samples out of window are dropped
+		rate, _ = tracker.Rate("RandomValue", start.Add(2*time.Minute))
+		assert.Equal(t, 0.0, rate)
+	})
+
+	t.Run("Cumulative counter reset", func(t *testing.T) {
+		assert.Equal(t, int64(5), tracker.ToDelta("PollCount", 5, nil))
+		assert.Equal(t, int64(3), tracker.ToDelta("PollCount", 8, nil))
+		assert.Equal(t, uint64(0), tracker.Resets())
+
+		assert.Equal(t, int64(2), tracker.ToDelta("PollCount", 2, nil))
+		assert.Equal(t, uint64(1), tracker.Resets())
+	})
+
+	t.Run("Baseline of stored counter", func(t *testing.T) {
+		stored := &storage.StorageMetric{MType: common.CounterMetricName, ID: "Requests", Delta: 100}
+
+		assert.Equal(t, int64(0), tracker.ToDelta("Requests", 40, stored))
+		assert.Equal(t, int64(2), tracker.ToDelta("Requests", 42, stored))
+	})
+
+	_, err = storage.NewRateTracker(time.Minute, "Poll[")
+	require.Error(t, err)
+}
+
+func TestRateStorage(t *testing.T) {
+	tracker, err := storage.NewRateTracker(time.Minute, "PollCount")
+	require.NoError(t, err)
+
+	baseStor, _ := storage.Init(nil)
+	stor := storage.WithRates(baseStor, tracker)
+
+	for _, value := range []int64{3, 5, 2} {
+		delta := value
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    "PollCount",
+			Delta: &delta,
+		}))
+	}
+
+	counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(7), counter.Delta)
+	assert.Equal(t, uint64(1), tracker.Resets())
+
+	rate, err := stor.GetMetric(context.TODO(), common.RateMetricName, "PollCount")
+	require.NoError(t, err)
+	require.NotNil(t, rate)
+	assert.Equal(t, "PollCount", rate.ID)
+
+	rate, err = stor.GetMetric(context.TODO(), common.RateMetricName, "Unknown")
+	require.NoError(t, err)
+	assert.Equal(t, (*storage.StorageMetric)(nil), rate)
+
+	types := make(map[string]int)
+	require.NoError(t, stor.ForEachMetrics(context.TODO(), func(sm *storage.StorageMetric) {
+		types[sm.MType]++
+	}))
+	assert.Equal(t, 1, types[common.CounterMetricName])
+	assert.Equal(t, 1, types[common.RateMetricName])
+
+	value := float64(1)
+	err = stor.UpdateMetric(context.TODO(), common.Metric{MType: common.RateMetricName, ID: "PollCount", Value: &value})
+	require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+
+	_, err = stor.GetMetricHistory(context.TODO(), common.RateMetricName, "PollCount", time.Time{}, time.Time{}, 0)
+	require.ErrorIs(t, err, storage.ErrHistoryNotSupported)
+}
+
+func TestRateStorageFailedUpdate(t *testing.T) {
+	tracker, err := storage.NewRateTracker(time.Minute, "PollCount")
+	require.NoError(t, err)
+
+	baseStor, _ := storage.Init(nil)
+	faults := storage.WithFaults(baseStor)
+	stor := storage.WithRates(faults, tracker)
+
+	update := func(value int64) error {
+		return stor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    "PollCount",
+			Delta: &value,
+		})
+	}
+
+	require.NoError(t, update(3))
+
+	require.NoError(t, faults.SetConfig(storage.FaultConfig{Methods: []string{"UpdateMetrics"}, ErrorRate: 1}))
+	require.ErrorIs(t, update(5), storage.ErrInjectedFault)
+
+	// retried value is converted from the last saved value, not from the failed one
+	require.NoError(t, faults.SetConfig(storage.FaultConfig{}))
+	require.NoError(t, update(5))
+
+	counter, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+	require.NotNil(t, counter)
+	assert.Equal(t, int64(5), counter.Delta)
+	assert.Equal(t, uint64(0), tracker.Resets())
+
+	// values of the same counter in one batch are converted one after another
+	first, second := int64(7), int64(10)
+	require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &first},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &second},
+	}))
+
+	counter, err = stor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), counter.Delta)
+}
+
+func TestRateStorageReplica(t *testing.T) {
+	primaryTracker, err := storage.NewRateTracker(time.Minute, "PollCount")
+	require.NoError(t, err)
+	// the window of replica is one second, so the rate at the last batch is counted from the batches only
+	replicaTracker, err := storage.NewRateTracker(time.Second, "PollCount")
+	require.NoError(t, err)
+
+	primaryStor, _ := storage.Init(nil)
+	primaryLog := storage.WithReplicationLog(primaryStor, 10, false)
+	primary := storage.WithRates(primaryLog, primaryTracker)
+
+	replicaStor, _ := storage.Init(nil)
+	replicaLog := storage.WithReplicationLog(replicaStor, 10, true)
+	replica := storage.WithRates(replicaLog, replicaTracker)
+	replicaLog.OnApply(replica.ObserveReplicated)
+
+	getRate := func(stor storage.StorageInterface) *storage.StorageMetric {
+		rate, err := stor.GetMetric(context.TODO(), common.RateMetricName, "PollCount")
+		require.NoError(t, err)
+
+		return rate
+	}
+
+	update := func(value int64) {
+		require.NoError(t, primary.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    "PollCount",
+			Delta: &value,
+		}))
+	}
+
+	update(3)
+
+	// replica starts from a snapshot of primary
+	snapshot := make([]*storage.StorageMetric, 0)
+	epoch, sequence, err := primaryLog.Snapshot(context.TODO(), func(sm *storage.StorageMetric) {
+		snapshot = append(snapshot, sm)
+	})
+	require.NoError(t, err)
+	require.NoError(t, replicaLog.ApplySnapshot(context.TODO(), snapshot, epoch, sequence, true))
+	require.NotNil(t, getRate(replica))
+
+	// cumulative values are converted by primary, replica observes the logged deltas
+	update(5)
+	update(9)
+
+	batches, ok, _ := primaryLog.Since(epoch, sequence)
+	require.Equal(t, true, ok)
+	require.Equal(t, 2, len(batches))
+	assert.Equal(t, int64(2), *batches[0].Metrics[0].Delta)
+
+	// batches of primary are a second apart, so the rate is the second increment per second
+	batches[1].Timestamp = batches[0].Timestamp.Add(time.Second)
+	for _, batch := range batches {
+		require.NoError(t, replicaLog.Apply(context.TODO(), epoch, batch))
+	}
+
+	require.NotNil(t, getRate(replica))
+
+	rate, ok := replicaTracker.Rate("PollCount", batches[1].Timestamp)
+	require.Equal(t, true, ok)
+	assert.Equal(t, float64(4), rate)
+
+	counter, err := replica.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), counter.Delta)
+
+	// counter deleted by primary is forgotten by replica
+	_, err = primary.DeleteMetric(context.TODO(), common.CounterMetricName, "PollCount")
+	require.NoError(t, err)
+
+	batches, ok, _ = primaryLog.Since(replicaLog.Position())
+	require.Equal(t, true, ok)
+	for _, batch := range batches {
+		require.NoError(t, replicaLog.Apply(context.TODO(), epoch, batch))
+	}
+
+	assert.Equal(t, (*storage.StorageMetric)(nil), getRate(replica))
+}
+
+func TestRollupPolicy(t *testing.T) {
+	policy, err := storage.ParseRollupPolicy(storage.DefaultHistoryRollups)
+	require.NoError(t, err)
+
+	assert.Equal(t, 24*time.Hour, policy.Raw)
+	assert.Equal(t, []storage.RollupTier{
+		{Resolution: time.Minute, Retention: 720 * time.Hour},
+		{Resolution: time.Hour, Retention: 8760 * time.Hour},
+	}, policy.Tiers)
+
+	now := time.Now()
+
+	tiers := []struct {
+		step       time.Duration
+		from       time.Time
+		resolution time.Duration
+	}{
+		{0, time.Time{}, 0},
+		{30 * time.Second, time.Time{}, 0},
+		{time.Minute, time.Time{}, time.Minute},
+		{15 * time.Minute, time.Time{}, time.Minute},
+		{time.Hour, time.Time{}, time.Hour},
+		{24 * time.Hour, time.Time{}, time.Hour},
+		// the finest tier which keeps points since from
+		{time.Hour, now.Add(-time.Hour), 0},
+		{time.Hour, now.Add(-48 * time.Hour), time.Minute},
+		{time.Hour, now.Add(-1000 * time.Hour), time.Hour},
+		{30 * time.Second, now.Add(-48 * time.Hour), 0},
+		// no tier keeps points since from
+		{15 * time.Minute, now.Add(-1000 * time.Hour), time.Minute},
+		{24 * time.Hour, now.Add(-10000 * time.Hour), time.Hour},
+	}
+	for _, test := range tiers {
+		tier := policy.TierFor(test.step, test.from, now)
+		if test.resolution == 0 {
+			assert.Equal(t, (*storage.RollupTier)(nil), tier)
+		} else {
+			require.NotNil(t, tier)
+			assert.Equal(t, test.resolution, tier.Resolution)
+		}
+	}
+
+	for _, policyStr := range []string{
+		"",
+		"1m=720h",
+		"raw=30s,1m=720h",
+		"raw=24h,1m=720h,90s=800h",
+		"raw=24h,1m=30s",
+		"raw=24h,1m=30m,1h=720h",
+		"raw=24h,500ms=1h",
+		"raw=24h,1m",
+	} {
+		_, err := storage.ParseRollupPolicy(policyStr)
+		require.Error(t, err, policyStr)
+	}
+}
+
+func TestCollapseMetrics(t *testing.T) {
+	value1, value2 := float64(1), float64(2)
+	delta1, delta2 := int64(3), int64(4)
+	histogram := &common.Histogram{Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 1}}, Sum: 1, Count: 1}
+
+	metricsList := []common.Metric{
+		{MType: common.GaugeMetricName, ID: "Alloc", Value: &value1},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta1},
+		{MType: common.GaugeMetricName, ID: "Alloc", Value: &value2},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta2},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta2, Labels: map[string]string{"host": "a"}},
+		{MType: common.HistogramMetricName, ID: "Latency", Histogram: histogram},
+		{MType: common.HistogramMetricName, ID: "Latency", Histogram: histogram},
+	}
+
+	metrics := storage.CollapseMetrics(metricsList)
+	require.Len(t, metrics, 4)
+
+	assert.Equal(t, common.CounterMetricName, metrics[0].MType)
+	assert.Equal(t, "PollCount", metrics[0].Key())
+	assert.Equal(t, int64(7), *metrics[0].Delta)
+
+	assert.Equal(t, `PollCount{host="a"}`, metrics[1].Key())
+	assert.Equal(t, int64(4), *metrics[1].Delta)
+
+	assert.Equal(t, common.GaugeMetricName, metrics[2].MType)
+	assert.Equal(t, value2, *metrics[2].Value)
+
+	assert.Equal(t, common.HistogramMetricName, metrics[3].MType)
+	assert.Equal(t, uint64(2), metrics[3].Histogram.Count)
+
+	// source metrics are not changed
+	assert.Equal(t, int64(3), delta1)
+	assert.Equal(t, uint64(1), histogram.Count)
+}
+
+// testDatabaseDSN returns Postgres database of TEST_DATABASE_DSN or starts embedded Postgres for the test,
+// false means there is no database (-short or embedded Postgres binaries could not be downloaded).
+func testDatabaseDSN(tb testing.TB) (string, bool) {
+	tb.Helper()
+
+	if dsn, ok := os.LookupEnv("TEST_DATABASE_DSN"); ok {
+		return dsn, true
+	}
+
+	if testing.Short() {
+		return "", false
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	require.NoError(tb, listener.Close())
+
+	config := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(tb.TempDir()).
+		Logger(io.Discard)
+
+	database := embeddedpostgres.NewDatabase(config)
+	if err := database.Start(); err != nil {
+		tb.Logf("Embedded Postgres is not started: %s", err)
+		return "", false
+	}
+	tb.Cleanup(func() {
+		if err := database.Stop(); err != nil {
+			tb.Errorf("Embedded Postgres is not stopped: %s", err)
+		}
+	})
+
+	return config.GetConnectionURL() + "?sslmode=disable", true
+}
+
+// testSchemaDSN creates throwaway schema dropped at the test cleanup and returns dsn which uses it
+// (by search_path), so tests never touch metrics of other schemas of the database.
+func testSchemaDSN(tb testing.TB, dsn string) string {
+	tb.Helper()
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("storage_test_%d_%d", time.Now().UnixNano(), rand.Int63())
+
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(tb, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(tb, err)
+
+	tb.Cleanup(func() {
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			tb.Errorf("Schema %s is not dropped: %s", schema, err)
+			return
+		}
+		defer conn.Close(ctx)
+
+		if _, err := conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			tb.Errorf("Schema %s is not dropped: %s", schema, err)
+		}
+	})
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		require.NoError(tb, err)
+
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+
+		return u.String()
+	}
+
+	return dsn + " search_path=" + schema
+}
+
+// BenchmarkStorageV2UpdateMetrics compares batch ingestion with a round trip per metric,
+// it needs Postgres database (see testDatabaseDSN).
+func BenchmarkStorageV2UpdateMetrics(b *testing.B) {
+	dsn, ok := testDatabaseDSN(b)
+	if !ok {
+		b.Skip("there is no Postgres database")
+	}
+
+	stor, err := storage.InitV2(context.Background(), testSchemaDSN(b, dsn))
+	require.NoError(b, err)
+	defer stor.Close()
+
+	metrics := make([]common.Metric, 0, 32)
+	for i := 0; i < 31; i++ {
+		value := float64(i)
+		metrics = append(metrics, common.Metric{
+			MType: common.GaugeMetricName,
+			ID:    fmt.Sprintf("bench-gauge%d", i),
+			Value: &value,
+		})
+	}
+	delta := int64(1)
+	metrics = append(metrics, common.Metric{MType: common.CounterMetricName, ID: "bench-PollCount", Delta: &delta})
+
+	updates := map[string]func(context.Context, []common.Metric) error{
+		"sequential": stor.UpdateMetricsSequential,
+		"batch":      stor.UpdateMetrics,
+	}
+
+	for name, update := range updates {
+		update := update
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := update(context.Background(), metrics); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(name+"/parallel", func(b *testing.B) {
+			b.SetParallelism(16)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := update(context.Background(), metrics); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestCacheStorage(t *testing.T) {
+	baseStor, _ := storage.Init(nil)
+	stor := storage.WithCache(baseStor, 2)
+
+	updateGauge := func(id string, value float64) {
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.GaugeMetricName,
+			ID:    id,
+			Value: &value,
+		}))
+	}
+	getGauge := func(id string) *storage.StorageMetric {
+		metric, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, id)
+		require.NoError(t, err)
+
+		return metric
+	}
+
+	updateGauge("Alloc", 1)
+
+	assert.Equal(t, float64(1), getGauge("Alloc").Value)
+	assert.Equal(t, float64(1), getGauge("Alloc").Value)
+	assert.Equal(t, storage.CacheStats{Hits: 1, Misses: 1, Size: 1, Capacity: 2}, stor.Stats())
+
+	t.Run("Update invalidates cache", func(t *testing.T) {
+		updateGauge("Alloc", 2)
+
+		assert.Equal(t, float64(2), getGauge("Alloc").Value)
+		assert.Equal(t, uint64(2), stor.Stats().Misses)
+	})
+
+	t.Run("Returned metric is a copy", func(t *testing.T) {
+		getGauge("Alloc").Value = 100
+
+		assert.Equal(t, float64(2), getGauge("Alloc").Value)
+	})
+
+	t.Run("Missing metric is not cached", func(t *testing.T) {
+		assert.Equal(t, (*storage.StorageMetric)(nil), getGauge("Missing"))
+		assert.Equal(t, 1, stor.Stats().Size)
+	})
+
+	t.Run("Least recently used metric is evicted", func(t *testing.T) {
+		updateGauge("Sys", 3)
+		updateGauge("Frees", 4)
+
+		getGauge("Sys")
+		getGauge("Alloc")
+		getGauge("Frees")
+
+		stats := stor.Stats()
+		assert.Equal(t, 2, stats.Size)
+		assert.Equal(t, uint64(1), stats.Evictions)
+
+		misses := stats.Misses
+		getGauge("Alloc")
+		assert.Equal(t, misses, stor.Stats().Misses)
+		getGauge("Sys")
+		assert.Equal(t, misses+1, stor.Stats().Misses)
+	})
+
+	t.Run("Returned labels and histogram are copies", func(t *testing.T) {
+		require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{
+			MType:     common.HistogramMetricName,
+			ID:        "Latency",
+			Labels:    map[string]string{"host": "a"},
+			Histogram: &common.Histogram{Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 1}}, Sum: 1, Count: 1},
+		}))
+
+		key := common.SeriesKey("Latency", map[string]string{"host": "a"})
+		getHistogram := func() *storage.StorageMetric {
+			metric, err := stor.GetMetric(context.TODO(), common.HistogramMetricName, key)
+			require.NoError(t, err)
+			require.NotNil(t, metric)
+
+			return metric
+		}
+
+		metric := getHistogram()
+		metric.Labels["host"] = "b"
+		metric.Histogram.Buckets[0].Count = 100
+
+		// the second read is served by the cache
+		hits := stor.Stats().Hits
+		metric = getHistogram()
+		assert.Equal(t, hits+1, stor.Stats().Hits)
+		assert.Equal(t, "a", metric.Labels["host"])
+		assert.Equal(t, uint64(1), metric.Histogram.Buckets[0].Count)
+	})
+}
+
+func TestReplicationLog(t *testing.T) {
+	baseStor, _ := storage.Init(nil)
+	primary := storage.WithReplicationLog(baseStor, 2, false)
+
+	updateCounter := func(id string, delta int64) {
+		require.NoError(t, primary.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    id,
+			Delta: &delta,
+		}))
+	}
+
+	epoch, sequence := primary.Position()
+	assert.Equal(t, uint64(0), sequence)
+
+	updateCounter("PollCount", 1)
+	updateCounter("PollCount", 2)
+
+	t.Run("Since returns batches after sequence", func(t *testing.T) {
+		batches, ok, _ := primary.Since(epoch, 1)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 1, len(batches))
+		assert.Equal(t, uint64(2), batches[0].Sequence)
+		assert.Equal(t, int64(2), *batches[0].Metrics[0].Delta)
+
+		batches, ok, _ = primary.Since(epoch, 2)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 0, len(batches))
+	})
+
+	t.Run("Notify is closed by the next batch", func(t *testing.T) {
+		_, _, notify := primary.Since(epoch, 2)
+		updateCounter("PollCount", 3)
+
+		select {
+		case <-notify:
+		default:
+			t.Fatal("notify is not closed")
+		}
+	})
+
+	t.Run("Snapshot is needed out of the log", func(t *testing.T) {
+		_, ok, _ := primary.Since(epoch, 0)
+		assert.Equal(t, false, ok)
+
+		_, ok, _ = primary.Since(epoch+1, 3)
+		assert.Equal(t, false, ok)
+	})
+
+	replicaStor, _ := storage.Init(nil)
+	replica := storage.WithReplicationLog(replicaStor, 2, true)
+
+	t.Run("Replica rejects writes", func(t *testing.T) {
+		value := float64(1)
+		err := replica.UpdateMetric(context.TODO(), common.Metric{MType: common.GaugeMetricName, ID: "Alloc", Value: &value})
+		require.ErrorIs(t, err, storage.ErrReadOnlyReplica)
+	})
+
+	t.Run("Replica applies snapshot and batches", func(t *testing.T) {
+		delta := int64(10)
+		require.NoError(t, replicaStor.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    "PollCount",
+			Delta: &delta,
+		}))
+
+		snapshot := make([]*storage.StorageMetric, 0)
+		epoch, sequence, err := primary.Snapshot(context.TODO(), func(sm *storage.StorageMetric) {
+			snapshot = append(snapshot, sm)
+		})
+		require.NoError(t, err)
+		require.NoError(t, replica.ApplySnapshot(context.TODO(), snapshot, epoch, sequence, true))
+
+		metric, _ := replica.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		assert.Equal(t, int64(6), metric.Delta)
+
+		replicaEpoch, replicaSequence := replica.Position()
+		assert.Equal(t, epoch, replicaEpoch)
+		assert.Equal(t, uint64(3), replicaSequence)
+
+		updateCounter("PollCount", 4)
+		batches, ok, _ := primary.Since(replica.Position())
+		assert.Equal(t, true, ok)
+
+		for _, batch := range batches {
+			require.NoError(t, replica.Apply(context.TODO(), epoch, batch))
+		}
+
+		metric, _ = replica.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		assert.Equal(t, int64(10), metric.Delta)
+
+		_, replicaSequence = replica.Position()
+		assert.Equal(t, uint64(4), replicaSequence)
+	})
+
+	t.Run("Snapshot deletes missing metrics", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		replica := storage.WithReplicationLog(stor, 10, true)
+
+		value := float64(1)
+		for _, id := range []string{"Alloc", "Sys"} {
+			require.NoError(t, stor.UpdateMetric(context.TODO(), common.Metric{MType: common.GaugeMetricName, ID: id, Value: &value}))
+		}
+
+		// parts of the snapshot are sent in separate messages
+		parts := [][]*storage.StorageMetric{
+			{{ID: "PollCount", MType: common.CounterMetricName, Delta: 3}},
+			{{ID: "Alloc", MType: common.GaugeMetricName, Value: 2}},
+		}
+		require.NoError(t, replica.ApplySnapshot(context.TODO(), parts[0], epoch, 3, false))
+		require.NoError(t, replica.ApplySnapshot(context.TODO(), parts[1], epoch, 3, true))
+
+		metric, err := replica.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), metric.Delta)
+
+		metric, err = replica.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, float64(2), metric.Value)
+
+		metric, err = replica.GetMetric(context.TODO(), common.GaugeMetricName, "Sys")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), metric)
+	})
+
+	t.Run("Replica drops metrics evicted by primary", func(t *testing.T) {
+		primaryStor, _ := storage.Init(nil)
+		primary := storage.WithReplicationLog(primaryStor, 10, false)
+		stor, _ := storage.Init(nil)
+		replica := storage.WithReplicationLog(stor, 10, true)
+
+		delta := int64(1)
+		require.NoError(t, primary.UpdateMetric(context.TODO(), common.Metric{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta}))
+
+		policy := &storage.TTLPolicy{Default: time.Hour}
+
+		_, err := replica.EvictExpired(context.TODO(), policy, time.Now().Add(2*time.Hour))
+		require.ErrorIs(t, err, storage.ErrReadOnlyReplica)
+
+		evicted, err := primary.EvictExpired(context.TODO(), policy, time.Now().Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(evicted))
+
+		epoch, _ := primary.Position()
+		batches, ok, _ := primary.Since(epoch, 0)
+		require.Equal(t, true, ok)
+
+		for _, batch := range batches {
+			require.NoError(t, replica.Apply(context.TODO(), epoch, batch))
+		}
+
+		metric, err := replica.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), metric)
+
+		_, replicaSequence := replica.Position()
+		assert.Equal(t, uint64(2), replicaSequence)
+	})
+
+	t.Run("Promoted replica starts a new epoch", func(t *testing.T) {
+		replica.Promote()
+		assert.Equal(t, false, replica.IsReadOnly())
+
+		promotedEpoch, promotedSequence := replica.Position()
+		assert.NotEqual(t, epoch, promotedEpoch)
+		assert.Equal(t, uint64(4), promotedSequence)
+
+		delta := int64(1)
+		require.NoError(t, replica.UpdateMetric(context.TODO(), common.Metric{
+			MType: common.CounterMetricName,
+			ID:    "PollCount",
+			Delta: &delta,
+		}))
+
+		// another replica of the old primary may be at the same sequence with other batches
+		_, ok, _ := replica.Since(epoch, 4)
+		assert.Equal(t, false, ok)
+
+		batches, ok, _ := replica.Since(promotedEpoch, 4)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 1, len(batches))
+		assert.Equal(t, uint64(5), batches[0].Sequence)
+	})
+
+	t.Run("Snapshot does not block writes", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		primary := storage.WithReplicationLog(stor, 10, false)
+
+		delta := int64(1)
+		metric := common.Metric{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta}
+		require.NoError(t, primary.UpdateMetric(context.TODO(), metric))
+
+		snapshot := make([]*storage.StorageMetric, 0)
+		epoch, sequence, err := primary.Snapshot(context.TODO(), func(sm *storage.StorageMetric) {
+			// handler sending snapshot to replica may write and read the log
+			require.NoError(t, primary.UpdateMetric(context.TODO(), metric))
+			_, ok, _ := primary.Since(primary.Position())
+			assert.Equal(t, true, ok)
+
+			snapshot = append(snapshot, sm)
+		})
+		require.NoError(t, err)
+
+		// writes during snapshot are after its position
+		assert.Equal(t, uint64(1), sequence)
+		assert.Equal(t, int64(1), snapshot[0].Delta)
+
+		batches, ok, _ := primary.Since(epoch, sequence)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, 1, len(batches))
+	})
+}
+
+func TestTenantStorage(t *testing.T) {
+	_, err := storage.ParseTenants([]byte(`[{"name": "team-a", "key": "a"}, {"name": "team-a", "key": "b"}]`))
+	require.Error(t, err)
+
+	_, err = storage.ParseTenants([]byte(`[{"name": "team a", "public": true}]`))
+	require.Error(t, err)
+	// tenant header is not authenticated, so tenant without key should be marked public
+	// tenant header is not authenticated, so tenant without key is public
+	_, err = storage.ParseTenants([]byte(`[{"name": "team-a"}]`))
+	require.Error(t, err)
+
+	tenants, err := storage.ParseTenants([]byte(`[
+		{"name": "team-a", "key": "a", "max_series": 2, "max_batch_size": 3},
+		{"name": "team-b", "key": "b"}
+	]`))
+	require.NoError(t, err)
+
+	baseStor, _ := storage.Init(nil)
+	stor := storage.WithTenants(baseStor, tenants)
+
+	ctxA := storage.ContextWithTenant(context.TODO(), "team-a")
+	ctxB := storage.ContextWithTenant(context.TODO(), "team-b")
+
+	gauge := func(id string, value float64) common.Metric {
+		return common.Metric{MType: common.GaugeMetricName, ID: id, Value: &value}
+	}
+
+	require.NoError(t, stor.UpdateMetric(ctxA, gauge("Alloc", 1)))
+	require.NoError(t, stor.UpdateMetric(ctxB, gauge("Alloc", 2)))
+	require.NoError(t, stor.UpdateMetric(context.TODO(), gauge("Alloc", 3)))
+
+	t.Run("Tenants have own metrics", func(t *testing.T) {
+		for ctx, value := range map[context.Context]float64{ctxA: 1, ctxB: 2, context.TODO(): 3} {
+			metric, err := stor.GetMetric(ctx, common.GaugeMetricName, "Alloc")
+			require.NoError(t, err)
+			assert.Equal(t, value, metric.Value)
+			assert.Equal(t, 0, len(metric.Labels))
+
+			count := 0
+			require.NoError(t, stor.ForEachMetrics(ctx, func(sm *storage.StorageMetric) {
+				assert.Equal(t, value, sm.Value)
+				count++
+			}))
+			assert.Equal(t, 1, count)
+		}
+	})
+
+	t.Run("Tenant label is reserved", func(t *testing.T) {
+		metric := gauge("Alloc", 4)
+		metric.Labels = map[string]string{storage.TenantLabel: "team-a"}
+
+		require.ErrorIs(t, stor.UpdateMetric(context.TODO(), metric), common.ErrInvalidLabel)
+
+		got, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, `Alloc{__tenant__="team-a"}`)
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), got)
+	})
+
+	t.Run("Tenant limits", func(t *testing.T) {
+		err := stor.UpdateMetrics(ctxA, []common.Metric{gauge("Sys", 1), gauge("Sys", 2), gauge("Sys", 3), gauge("Sys", 4)})
+		require.ErrorIs(t, err, storage.ErrTenantLimit)
+
+		require.NoError(t, stor.UpdateMetric(ctxA, gauge("Sys", 1)))
+		require.NoError(t, stor.UpdateMetric(ctxA, gauge("Sys", 2)))
+		require.ErrorIs(t, stor.UpdateMetric(ctxA, gauge("Frees", 1)), storage.ErrTenantLimit)
+
+		_, err = stor.EvictExpired(context.TODO(), &storage.TTLPolicy{Default: time.Nanosecond}, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.NoError(t, stor.UpdateMetric(ctxA, gauge("Frees", 1)))
+	})
+
+	t.Run("Unknown tenant", func(t *testing.T) {
+		err := stor.UpdateMetric(storage.ContextWithTenant(context.TODO(), "team-c"), gauge("Alloc", 1))
+		require.ErrorIs(t, err, storage.ErrUnknownTenant)
+	})
+
+	t.Run("Tenant by hash", func(t *testing.T) {
+		metric := gauge("Alloc", 1)
+		require.NoError(t, metric.SetHash("b"))
+		assert.Equal(t, "team-b", tenants.ByHash(&metric).Name)
+
+		require.NoError(t, metric.SetHash("c"))
+		assert.Equal(t, (*storage.Tenant)(nil), tenants.ByHash(&metric))
+	})
+}
+
+func TestDumpRestore(t *testing.T) {
+	source, _ := storage.Init(nil)
+
+	delta := int64(5)
+	value := float64(1.5)
+	require.NoError(t, source.UpdateMetrics(context.TODO(), []common.Metric{
+		{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta},
+		{ID: "Alloc", MType: common.GaugeMetricName, Value: &value, Labels: map[string]string{"host": "a"}},
+		{ID: "Latency", MType: common.HistogramMetricName, Histogram: &common.Histogram{
+			Buckets: []common.HistogramBucket{{UpperBound: 1, Count: 2}},
+			Sum:     1,
+			Count:   2,
+		}},
+	}))
+
+	var dump bytes.Buffer
+	count, err := storage.Dump(context.TODO(), source, &dump)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	header := storage.DumpHeader{}
+	require.NoError(t, json.NewDecoder(bytes.NewReader(dump.Bytes())).Decode(&header))
+	assert.Equal(t, storage.DumpVersion, header.Version)
+
+	restore := func(t *testing.T, stor storage.StorageInterface, mode storage.RestoreMode) {
+		count, err := storage.Restore(context.TODO(), stor, bytes.NewReader(dump.Bytes()), mode)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	}
+
+	t.Run("Overwrite", func(t *testing.T) {
+		target, _ := storage.Init(nil)
+		require.NoError(t, target.UpdateMetric(context.TODO(), common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}))
+
+		restore(t, target, storage.RestoreOverwrite)
+		restore(t, target, storage.RestoreOverwrite)
+
+		counter, _ := target.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		assert.Equal(t, int64(5), counter.Delta)
+
+		gauge, _ := target.GetMetric(context.TODO(), common.GaugeMetricName, `Alloc{host="a"}`)
+		assert.Equal(t, 1.5, gauge.Value)
+
+		histogram, _ := target.GetMetric(context.TODO(), common.HistogramMetricName, "Latency")
+		assert.Equal(t, uint64(2), histogram.Histogram.Count)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		target, _ := storage.Init(nil)
+		require.NoError(t, target.UpdateMetric(context.TODO(), common.Metric{ID: "PollCount", MType: common.CounterMetricName, Delta: &delta}))
+
+		restore(t, target, storage.RestoreMerge)
+
+		counter, _ := target.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		assert.Equal(t, int64(10), counter.Delta)
+	})
+
+	t.Run("Not a dump", func(t *testing.T) {
+		target, _ := storage.Init(nil)
+
+		_, err := storage.Restore(context.TODO(), target, strings.NewReader(`{"id": "Alloc"}`), storage.RestoreMerge)
+		require.ErrorIs(t, err, storage.ErrDumpFormat)
+
+		_, err = storage.Restore(context.TODO(), target, strings.NewReader(`{"format": "devops-metrics-dump", "version": 2}`), storage.RestoreMerge)
+		require.ErrorIs(t, err, storage.ErrDumpFormat)
+	})
+}
+
+func TestListMetrics(t *testing.T) {
+	value := float64(1)
+	delta := int64(1)
+
+	metrics := []common.Metric{
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value, Labels: map[string]string{"host": "a"}},
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value, Labels: map[string]string{"host": "b"}},
+		{MType: common.GaugeMetricName, ID: "HeapSys", Value: &value},
+		{MType: common.GaugeMetricName, ID: "Heap_Idle", Value: &value},
+		{MType: common.GaugeMetricName, ID: "Alloc", Value: &value},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "HeapCount", Delta: &delta},
+	}
+
+	keys := func(page *storage.MetricsPage) []string {
+		list := make([]string, 0, len(page.Metrics))
+		for _, sm := range page.Metrics {
+			list = append(list, sm.MType+":"+sm.Key())
+		}
+
+		return list
+	}
+
+	listAll := func(t *testing.T, stor storage.StorageInterface, filter *storage.MetricsFilter, limit int) []string {
+		list := make([]string, 0)
+		query := storage.ListQuery{Filter: *filter, Limit: limit}
+
+		for {
+			page, err := stor.ListMetrics(context.TODO(), query)
+			require.NoError(t, err)
+			require.Equal(t, true, limit == 0 || len(page.Metrics) <= limit)
+
+			list = append(list, keys(page)...)
+			if page.NextCursor == "" {
+				return list
+			}
+
+			query.Cursor = page.NextCursor
+		}
+	}
+
+	checkList := func(t *testing.T, stor storage.StorageInterface) {
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		all, err := storage.NewMetricsFilter("", "", "", "")
+		require.NoError(t, err)
+
+		expected := []string{
+			"counter:HeapCount",
+			"counter:PollCount",
+			"gauge:Alloc",
+			`gauge:HeapAlloc{host="a"}`,
+			`gauge:HeapAlloc{host="b"}`,
+			"gauge:HeapSys",
+			"gauge:Heap_Idle",
+		}
+
+		for _, limit := range []int{0, 1, 2, 3, 7, 100} {
+			assert.Equal(t, expected, listAll(t, stor, all, limit))
+		}
+
+		filter, err := storage.NewMetricsFilter(common.GaugeMetricName, "Heap", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, expected[3:], listAll(t, stor, filter, 2))
+
+		filter, err = storage.NewMetricsFilter("", "Heap_", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gauge:Heap_Idle"}, listAll(t, stor, filter, 1))
+
+		filter, err = storage.NewMetricsFilter("", "", "Heap.*c", `{host!="b"}`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`gauge:HeapAlloc{host="a"}`}, listAll(t, stor, filter, 1))
+
+		_, err = stor.ListMetrics(context.TODO(), storage.ListQuery{Filter: *all, Cursor: "not a cursor"})
+		require.ErrorIs(t, err, storage.ErrInvalidListQuery)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = stor.ListMetrics(ctx, storage.ListQuery{Filter: *all, Limit: 1})
+		require.ErrorIs(t, err, context.Canceled)
+	}
+
+	t.Run("Filter", func(t *testing.T) {
+		_, err := storage.NewMetricsFilter("unknown", "", "", "")
+		require.ErrorIs(t, err, storage.ErrInvalidListQuery)
+
+		_, err = storage.NewMetricsFilter("", "", "(", "")
+		require.ErrorIs(t, err, storage.ErrInvalidListQuery)
+
+		_, err = storage.NewMetricsFilter("", "", "", "{host")
+		require.ErrorIs(t, err, storage.ErrInvalidListQuery)
+	})
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkList(t, stor)
+	})
+
+	t.Run("Sharded", func(t *testing.T) {
+		stor, _ := storage.InitSharded(nil, 4)
+		checkList(t, stor)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkList(t, stor)
+	})
+
+	t.Run("Rates", func(t *testing.T) {
+		tracker, err := storage.NewRateTracker(time.Minute, "")
+		require.NoError(t, err)
+
+		baseStor, _ := storage.Init(nil)
+		stor := storage.WithRates(baseStor, tracker)
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		all, err := storage.NewMetricsFilter("", "", "", "")
+		require.NoError(t, err)
+
+		expected := []string{
+			"counter:HeapCount",
+			"counter:PollCount",
+			"gauge:Alloc",
+			`gauge:HeapAlloc{host="a"}`,
+			`gauge:HeapAlloc{host="b"}`,
+			"gauge:HeapSys",
+			"gauge:Heap_Idle",
+			"rate:HeapCount",
+			"rate:PollCount",
+		}
+
+		for _, limit := range []int{0, 1, 2, 8, 9} {
+			assert.Equal(t, expected, listAll(t, stor, all, limit))
+		}
+
+		rates, err := storage.NewMetricsFilter(common.RateMetricName, "Poll", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"rate:PollCount"}, listAll(t, stor, rates, 1))
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		tenants, err := storage.ParseTenants([]byte(`[{"name": "team-a", "public": true}]`))
+		require.NoError(t, err)
+
+		baseStor, _ := storage.Init(nil)
+		stor := storage.WithTenants(baseStor, tenants)
+
+		ctxA := storage.ContextWithTenant(context.TODO(), "team-a")
+		require.NoError(t, stor.UpdateMetrics(ctxA, metrics[:3]))
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics[3:]))
+
+		all, err := storage.NewMetricsFilter("", "", "", "")
+		require.NoError(t, err)
+
+		page, err := stor.ListMetrics(ctxA, storage.ListQuery{Filter: *all, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{`gauge:HeapAlloc{host="a"}`, `gauge:HeapAlloc{host="b"}`}, keys(page))
+
+		page, err = stor.ListMetrics(ctxA, storage.ListQuery{Filter: *all, Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gauge:HeapSys"}, keys(page))
+		assert.Equal(t, "", page.NextCursor)
+
+		page, err = stor.ListMetrics(context.TODO(), storage.ListQuery{Filter: *all})
+		require.NoError(t, err)
+		assert.Equal(t, 4, len(page.Metrics))
+	})
+}
+
+func TestDeleteMetrics(t *testing.T) {
+	value := float64(1)
+	delta := int64(1)
+
+	metrics := []common.Metric{
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value, Labels: map[string]string{"host": "a"}},
+		{MType: common.GaugeMetricName, ID: "HeapAlloc", Value: &value, Labels: map[string]string{"host": "b"}},
+		{MType: common.GaugeMetricName, ID: "HeapSys", Value: &value},
+		{MType: common.CounterMetricName, ID: "PollCount", Delta: &delta},
+	}
+
+	listKeys := func(t *testing.T, ctx context.Context, stor storage.StorageInterface) []string {
+		all, err := storage.NewMetricsFilter("", "", "", "")
+		require.NoError(t, err)
+
+		page, err := stor.ListMetrics(ctx, storage.ListQuery{Filter: *all})
+		require.NoError(t, err)
+
+		keys := make([]string, 0, len(page.Metrics))
+		for _, sm := range page.Metrics {
+			keys = append(keys, sm.MType+":"+sm.Key())
+		}
+
+		return keys
+	}
+
+	checkDelete := func(t *testing.T, stor storage.StorageInterface) {
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+
+		deleted, err := stor.DeleteMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, true, deleted)
+
+		deleted, err = stor.DeleteMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, false, deleted)
+
+		_, err = stor.DeleteMetric(context.TODO(), "unknown", "PollCount")
+		require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+
+		filter, err := storage.NewMetricsFilter(common.GaugeMetricName, "Heap", "", `{host="a"}`)
+		require.NoError(t, err)
+
+		deletedMetrics, err := stor.DeleteMetrics(context.TODO(), *filter)
+		require.NoError(t, err)
+		require.Len(t, deletedMetrics, 1)
+		assert.Equal(t, `HeapAlloc{host="a"}`, deletedMetrics[0].Key())
+
+		assert.Equal(t, []string{`gauge:HeapAlloc{host="b"}`, "gauge:HeapSys"}, listKeys(t, context.TODO(), stor))
+
+		metric, err := stor.GetMetric(context.TODO(), common.CounterMetricName, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), metric)
+
+		points, err := stor.GetMetricHistory(context.TODO(), common.CounterMetricName, "PollCount", time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0, len(points))
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkDelete(t, stor)
+	})
+
+	t.Run("Sharded", func(t *testing.T) {
+		stor, _ := storage.InitSharded(nil, 4)
+		checkDelete(t, stor)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkDelete(t, stor)
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		baseStor, _ := storage.Init(nil)
+		checkDelete(t, storage.WithCache(baseStor, 10))
+	})
+
+	t.Run("Write-ahead log", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+
+		checkDelete(t, stor)
+		stor.Close()
+
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+		assert.Equal(t, []string{`gauge:HeapAlloc{host="b"}`, "gauge:HeapSys"}, listKeys(t, context.TODO(), restoredStor))
+	})
+
+	t.Run("Rates", func(t *testing.T) {
+		tracker, err := storage.NewRateTracker(time.Minute, "")
+		require.NoError(t, err)
+
+		baseStor, _ := storage.Init(nil)
+		checkDelete(t, storage.WithRates(baseStor, tracker))
+
+		_, ok := tracker.Rate("PollCount", time.Now())
+		assert.Equal(t, false, ok)
+	})
+
+	t.Run("Replication", func(t *testing.T) {
+		baseStor, _ := storage.Init(nil)
+		primary := storage.WithReplicationLog(baseStor, 10, false)
+		checkDelete(t, primary)
+
+		replicaStor, _ := storage.Init(nil)
+		replica := storage.WithReplicationLog(replicaStor, 10, true)
+
+		_, err := replica.DeleteMetric(context.TODO(), common.GaugeMetricName, "HeapSys")
+		require.ErrorIs(t, err, storage.ErrReadOnlyReplica)
+
+		epoch, _ := primary.Position()
+		batches, ok, _ := primary.Since(epoch, 0)
+		require.Equal(t, true, ok)
+		require.Len(t, batches, 3)
+
+		for _, batch := range batches {
+			require.NoError(t, replica.Apply(context.TODO(), epoch, batch))
+		}
+		assert.Equal(t, listKeys(t, context.TODO(), primary), listKeys(t, context.TODO(), replica))
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		tenants, err := storage.ParseTenants([]byte(`[{"name": "team-a", "public": true, "max_series": 4}]`))
+		require.NoError(t, err)
+
+		baseStor, _ := storage.Init(nil)
+		stor := storage.WithTenants(baseStor, tenants)
+
+		ctxA := storage.ContextWithTenant(context.TODO(), "team-a")
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), metrics))
+		checkDelete(t, storage.StorageInterface(&tenantScope{StorageInterface: stor, ctx: ctxA}))
+
+		// metrics of the default tenant are kept
+		assert.Equal(t, 4, len(listKeys(t, context.TODO(), stor)))
+
+		// deleted series are not counted by the limit
+		gauge := func(id string) common.Metric {
+			return common.Metric{MType: common.GaugeMetricName, ID: id, Value: &value}
+		}
+		require.NoError(t, stor.UpdateMetrics(ctxA, []common.Metric{gauge("Alloc"), gauge("Frees")}))
+		require.ErrorIs(t, stor.UpdateMetric(ctxA, gauge("Mallocs")), storage.ErrTenantLimit)
+	})
+}
+
+// tenantScope makes requests of the wrapped storage with the tenant of ctx.
+type tenantScope struct {
+	storage.StorageInterface
+	ctx context.Context
+}
+
+func (s *tenantScope) UpdateMetrics(_ context.Context, metricsList []common.Metric) error {
+	return s.StorageInterface.UpdateMetrics(s.ctx, metricsList)
+}
+
+func (s *tenantScope) GetMetric(_ context.Context, mType string, id string) (*storage.StorageMetric, error) {
+	return s.StorageInterface.GetMetric(s.ctx, mType, id)
+}
+
+func (s *tenantScope) GetMetricHistory(
+	_ context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*storage.HistoryPoint, error) {
+	return s.StorageInterface.GetMetricHistory(s.ctx, mType, id, from, to, step)
+}
+
+func (s *tenantScope) ListMetrics(_ context.Context, query storage.ListQuery) (*storage.MetricsPage, error) {
+	return s.StorageInterface.ListMetrics(s.ctx, query)
+}
+
+func (s *tenantScope) DeleteMetric(_ context.Context, mType string, id string) (bool, error) {
+	return s.StorageInterface.DeleteMetric(s.ctx, mType, id)
+}
+
+func (s *tenantScope) DeleteMetrics(_ context.Context, filter storage.MetricsFilter) ([]*storage.StorageMetric, error) {
+	return s.StorageInterface.DeleteMetrics(s.ctx, filter)
+}
+
+func TestConditionalUpdates(t *testing.T) {
+	gauge := func(value float64, precondition *common.Precondition) common.Metric {
+		return common.Metric{MType: common.GaugeMetricName, ID: "Jobs", Value: &value, Precondition: precondition}
+	}
+	ifVersion := func(version uint64) *common.Precondition {
+		return &common.Precondition{Version: &version}
+	}
+	ifValue := func(value float64) *common.Precondition {
+		return &common.Precondition{Value: &value}
+	}
+	ifNewer := func(timestamp time.Time) *common.Precondition {
+		return &common.Precondition{Timestamp: &timestamp}
+	}
+
+	checkGauge := func(t *testing.T, stor storage.StorageInterface, value float64, version uint64) {
+		metric, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Jobs")
+		require.NoError(t, err)
+		require.NotNil(t, metric)
+		assert.Equal(t, value, metric.Value)
+		assert.Equal(t, version, metric.Version)
+	}
+
+	checkConditional := func(t *testing.T, stor storage.StorageInterface) {
+		ctx := context.TODO()
+		now := time.Now()
+
+		// version 0 creates missing gauge only
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(1, ifValue(0))), storage.ErrPreconditionFailed)
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(1, ifVersion(0))))
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(2, ifVersion(0))), storage.ErrPreconditionFailed)
+		checkGauge(t, stor, 1, 1)
+
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(2, ifVersion(1))))
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(3, ifVersion(1))), storage.ErrPreconditionFailed)
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(3, ifValue(2))))
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(4, ifValue(2))), storage.ErrPreconditionFailed)
+		checkGauge(t, stor, 3, 3)
+
+		// unconditional update changes the version
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(4, nil)))
+		checkGauge(t, stor, 4, 4)
+
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(5, ifNewer(now))))
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(6, ifNewer(now))), storage.ErrPreconditionFailed)
+		require.ErrorIs(t, stor.UpdateMetric(ctx, gauge(6, ifNewer(now.Add(-time.Second)))), storage.ErrPreconditionFailed)
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(6, ifNewer(now.Add(time.Second)))))
+		checkGauge(t, stor, 6, 6)
+
+		// batch is saved only if all preconditions are satisfied, in order of the batch
+		delta := int64(1)
+		counter := common.Metric{MType: common.CounterMetricName, ID: "Runs", Delta: &delta}
+
+		err := stor.UpdateMetrics(ctx, []common.Metric{counter, gauge(7, ifVersion(6)), gauge(8, ifVersion(6))})
+		require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+		checkGauge(t, stor, 6, 6)
+
+		runs, err := stor.GetMetric(ctx, common.CounterMetricName, "Runs")
+		require.NoError(t, err)
+		assert.Equal(t, (*storage.StorageMetric)(nil), runs)
+
+		require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{counter, gauge(7, ifVersion(6)), gauge(8, ifVersion(7))}))
+		checkGauge(t, stor, 8, 8)
+
+		// precondition is allowed for gauges only
+		counter.Precondition = ifVersion(0)
+		require.Error(t, stor.UpdateMetric(ctx, counter))
+
+		// deleted gauge starts from version 0
+		_, err = stor.DeleteMetric(ctx, common.GaugeMetricName, "Jobs")
+		require.NoError(t, err)
+		require.NoError(t, stor.UpdateMetric(ctx, gauge(1, ifVersion(0))))
+		checkGauge(t, stor, 1, 1)
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		stor, _ := storage.Init(nil)
+		checkConditional(t, stor)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		stor, _ := storage.InitSharded(nil, 4)
+
+		const writers = 8
+
+		// every writer increments the gauge with compare-and-set until it succeeds
+		wg := sync.WaitGroup{}
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for {
+					metric, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Jobs")
+					require.NoError(t, err)
+
+					var value float64
+					var version uint64
+					if metric != nil {
+						value, version = metric.Value, metric.Version
+					}
+
+					err = stor.UpdateMetric(context.TODO(), gauge(value+1, ifVersion(version)))
+					if err == nil {
+						return
+					}
+					require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+				}
+			}()
+		}
+		wg.Wait()
+
+		checkGauge(t, stor, writers, writers)
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+		require.NoError(t, err)
+		defer stor.Close()
+
+		checkConditional(t, stor)
+	})
+
+	t.Run("Write-ahead log", func(t *testing.T) {
+		backupFileName := t.TempDir() + "/backup.json"
+
+		baseStor, _ := storage.Init(nil)
+		stor, err := storage.WithWAL(baseStor, storage.BackupConfig{FilePath: backupFileName}, 0)
+		require.NoError(t, err)
+
+		checkConditional(t, stor)
+		require.NoError(t, stor.UpdateMetric(context.TODO(), gauge(2, ifNewer(time.Now()))))
+		stor.Close()
+
+		// versions and value timestamps are restored
+		restoredStor, err := storage.Init(&backupFileName)
+		require.NoError(t, err)
+		checkGauge(t, restoredStor, 2, 2)
+		require.ErrorIs(t, restoredStor.UpdateMetric(context.TODO(), gauge(3, ifNewer(time.Now().Add(-time.Minute)))),
+			storage.ErrPreconditionFailed)
+	})
+
+	t.Run("Replication", func(t *testing.T) {
+		baseStor, _ := storage.Init(nil)
+		primary := storage.WithReplicationLog(baseStor, 100, false)
+		checkConditional(t, primary)
+
+		replicaStor, _ := storage.Init(nil)
+		replica := storage.WithReplicationLog(replicaStor, 100, true)
+
+		epoch, _ := primary.Position()
+		batches, ok, _ := primary.Since(epoch, 0)
+		require.Equal(t, true, ok)
+
+		for _, batch := range batches {
+			require.NoError(t, replica.Apply(context.TODO(), epoch, batch))
+		}
+		checkGauge(t, replica, 1, 1)
+	})
+}
+
+func TestWatch(t *testing.T) {
+	gauge := func(id string, value float64) common.Metric {
+		return common.Metric{MType: common.GaugeMetricName, ID: id, Value: &value}
+	}
+
+	nextEvent := func(t *testing.T, sub *storage.Subscription) storage.WatchEvent {
+		select {
+		case event, ok := <-sub.Events():
+			require.Equal(t, true, ok)
+			return event
+		case <-time.After(time.Second):
+			require.FailNow(t, "no watch event")
+		}
+
+		return storage.WatchEvent{}
+	}
+
+	noEvent := func(t *testing.T, sub *storage.Subscription) {
+		select {
+		case event := <-sub.Events():
+			require.FailNow(t, "unexpected watch event", event.Metric.Key())
+		default:
+		}
+	}
+
+	t.Run("Filtered events", func(t *testing.T) {
+		baseStor, _ := storage.Init(nil)
+		hub := storage.NewWatchHub(16)
+		stor := storage.WithWatch(baseStor, hub)
+
+		filter, err := storage.NewMetricsFilter(common.GaugeMetricName, "Heap", "", `{host="a"}`)
+		require.NoError(t, err)
+
+		sub, err := hub.Subscribe(context.TODO(), *filter)
+		require.NoError(t, err)
+		defer sub.Close()
+
+		heap := gauge("HeapAlloc", 1)
+		heap.Labels = map[string]string{"host": "a"}
+		other := gauge("HeapAlloc", 2)
+		other.Labels = map[string]string{"host": "b"}
+
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{gauge("Alloc", 1), other, heap}))
+
+		event := nextEvent(t, sub)
+		assert.Equal(t, storage.WatchUpdated, event.Type)
+		assert.Equal(t, `HeapAlloc{host="a"}`, event.Metric.Key())
+		assert.Equal(t, 1.0, event.Metric.Value)
+		assert.Equal(t, uint64(1), event.Metric.Version)
+		noEvent(t, sub)
+
+		// event has the stored value
+		require.NoError(t, stor.UpdateMetric(context.TODO(), heap))
+		assert.Equal(t, uint64(2), nextEvent(t, sub).Metric.Version)
+
+		deleted, err := stor.DeleteMetric(context.TODO(), common.GaugeMetricName, heap.Key())
+		require.NoError(t, err)
+		require.Equal(t, true, deleted)
+
+		event = nextEvent(t, sub)
+		assert.Equal(t, storage.WatchDeleted, event.Type)
+		assert.Equal(t, heap.Key(), event.Metric.Key())
+		noEvent(t, sub)
+
+		// rejected update is not published
+		heap.Precondition = &common.Precondition{Version: new(uint64)}
+		require.NoError(t, stor.UpdateMetric(context.TODO(), heap))
+		require.ErrorIs(t, stor.UpdateMetric(context.TODO(), heap), storage.ErrPreconditionFailed)
+		assert.Equal(t, storage.WatchUpdated, nextEvent(t, sub).Type)
+		noEvent(t, sub)
+
+		sub.Close()
+		_, ok := <-sub.Events()
+		assert.Equal(t, false, ok)
+		assert.Equal(t, nil, sub.Err())
+		assert.Equal(t, 0, hub.Stats().Subscriptions)
+	})
+
+	t.Run("Slow subscriber is dropped", func(t *testing.T) {
+		baseStor, _ := storage.Init(nil)
+		hub := storage.NewWatchHub(2)
+		stor := storage.WithWatch(baseStor, hub)
+
+		slow, err := hub.Subscribe(context.TODO(), storage.MetricsFilter{})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, stor.UpdateMetric(context.TODO(), gauge("Alloc", float64(i))))
+		}
+
+		assert.Equal(t, 0.0, nextEvent(t, slow).Metric.Value)
+		assert.Equal(t, 1.0, nextEvent(t, slow).Metric.Value)
+
+		_, ok := <-slow.Events()
+		assert.Equal(t, false, ok)
+		require.ErrorIs(t, slow.Err(), storage.ErrWatchLagged)
+
+		stats := hub.Stats()
+		assert.Equal(t, uint64(1), stats.Lagged)
+		assert.Equal(t, uint64(2), stats.Published)
+
+		// updates are saved without subscribers
+		require.NoError(t, stor.UpdateMetric(context.TODO(), gauge("Alloc", 3)))
+
+		sub, err := hub.Subscribe(context.TODO(), storage.MetricsFilter{})
+		require.NoError(t, err)
+
+		hub.Close()
+		_, ok = <-sub.Events()
+		assert.Equal(t, false, ok)
+		require.ErrorIs(t, sub.Err(), storage.ErrWatchClosed)
+
+		_, err = hub.Subscribe(context.TODO(), storage.MetricsFilter{})
+		require.ErrorIs(t, err, storage.ErrWatchClosed)
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		tenants, err := storage.ParseTenants([]byte(`[{"name": "team-a", "public": true}]`))
+		require.NoError(t, err)
+
+		baseStor, _ := storage.Init(nil)
+		hub := storage.NewWatchHub(16)
+		stor := storage.WithTenants(storage.WithWatch(baseStor, hub), tenants)
+
+		ctxA := storage.ContextWithTenant(context.TODO(), "team-a")
+
+		subA, err := hub.Subscribe(ctxA, storage.MetricsFilter{})
+		require.NoError(t, err)
+		defer subA.Close()
+
+		sub, err := hub.Subscribe(context.TODO(), storage.MetricsFilter{})
+		require.NoError(t, err)
+		defer sub.Close()
+
+		require.NoError(t, stor.UpdateMetric(ctxA, gauge("Alloc", 1)))
+		require.NoError(t, stor.UpdateMetric(context.TODO(), gauge("Alloc", 2)))
+
+		event := nextEvent(t, subA)
+		assert.Equal(t, "Alloc", event.Metric.Key())
+		assert.Equal(t, 1.0, event.Metric.Value)
+		noEvent(t, subA)
+
+		event = nextEvent(t, sub)
+		assert.Equal(t, "Alloc", event.Metric.Key())
+		assert.Equal(t, 2.0, event.Metric.Value)
+		noEvent(t, sub)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	memory := func(t *testing.T) *storage.Storage {
+		stor, _ := storage.Init(nil)
+		return stor
+	}
+
+	backends := map[string]storagetest.Factory{
+		"Memory": func(t *testing.T) storage.StorageInterface {
+			return memory(t)
+		},
+		"Memory with one shard": func(t *testing.T) storage.StorageInterface {
+			stor, _ := storage.InitSharded(nil, 1)
+			return stor
+		},
+		"Backup": func(t *testing.T) storage.StorageInterface {
+			return storage.WithBackup(memory(t), t.TempDir()+"/backup.json")
+		},
+		"WAL": func(t *testing.T) storage.StorageInterface {
+			codec, err := storage.NewBackupCodec("zstd", strings.Repeat("0f", 32))
+			require.NoError(t, err)
+
+			stor, err := storage.WithWAL(memory(t), storage.BackupConfig{FilePath: t.TempDir() + "/backup.json", Codec: codec}, 0)
+			require.NoError(t, err)
+			t.Cleanup(stor.Close)
+
+			return stor
+		},
+		"Bolt": func(t *testing.T) storage.StorageInterface {
+			stor, err := storage.InitBolt(t.TempDir() + "/metrics.db")
+			require.NoError(t, err)
+			t.Cleanup(stor.Close)
+
+			return stor
+		},
+		"Cache": func(t *testing.T) storage.StorageInterface {
+			return storage.WithCache(memory(t), 4)
+		},
+		"Replication log": func(t *testing.T) storage.StorageInterface {
+			return storage.WithReplicationLog(memory(t), 16, false)
+		},
+		"Default tenant": func(t *testing.T) storage.StorageInterface {
+			tenants, err := storage.ParseTenants([]byte(`[{"name": "team-a", "public": true}]`))
+			require.NoError(t, err)
+
+			return storage.WithTenants(memory(t), tenants)
+		},
+		"Instrumented": func(t *testing.T) storage.StorageInterface {
+			return storage.WithInstrumentation(memory(t), storage.NewStorageMetrics(nil))
+		},
+		"Without faults": func(t *testing.T) storage.StorageInterface {
+			return storage.WithFaults(memory(t))
+		},
+		"Watch": func(t *testing.T) storage.StorageInterface {
+			hub := storage.NewWatchHub(1)
+			_, err := hub.Subscribe(context.TODO(), storage.MetricsFilter{})
+			require.NoError(t, err)
+
+			return storage.WithWatch(memory(t), hub)
+		},
+	}
+
+	// Postgres backend runs against embedded Postgres (or database of TEST_DATABASE_DSN),
+	// every test gets its own schema
+	if dsn, ok := testDatabaseDSN(t); ok {
+		backends["Postgres"] = func(t *testing.T) storage.StorageInterface {
+			stor, err := storage.InitV2(context.Background(), testSchemaDSN(t, dsn))
+			require.NoError(t, err)
+			t.Cleanup(stor.Close)
+
+			return stor
+		}
+	} else {
+		t.Log("Postgres backend is skipped, there is no database")
+	}
+
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			storagetest.Run(t, newStorage)
+		})
+	}
+}
+
+func TestPersistentConformance(t *testing.T) {
+	backends := map[string]storagetest.PersistentFactory{
+		"Backup": func(t *testing.T, path string) (storage.StorageInterface, func()) {
+			stor, _ := storage.Init(&path)
+			return storage.WithBackup(stor, path), func() {}
+		},
+		"WAL": func(t *testing.T, path string) (storage.StorageInterface, func()) {
+			codec, err := storage.NewBackupCodec("gzip", strings.Repeat("0f", 32))
+			require.NoError(t, err)
+
+			stor, _ := storage.InitShardedWithCodec(&path, 4, codec)
+			walStor, err := storage.WithWAL(stor, storage.BackupConfig{FilePath: path, Codec: codec}, 0)
+			require.NoError(t, err)
+
+			return walStor, walStor.Close
+		},
+		"Bolt": func(t *testing.T, path string) (storage.StorageInterface, func()) {
+			stor, err := storage.InitBolt(path)
+			require.NoError(t, err)
+
+			return stor, stor.Close
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storagetest.RunPersistent(t, open)
+		})
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	baseStor, _ := storage.Init(nil)
+	stor := storage.WithFaults(baseStor)
+
+	gauge := func(id string, value float64) common.Metric {
+		return common.Metric{ID: id, MType: common.GaugeMetricName, Value: &value}
+	}
+
+	t.Run("Invalid config", func(t *testing.T) {
+		assert.NotEqual(t, nil, stor.SetConfig(storage.FaultConfig{ErrorRate: 1.5}))
+		assert.NotEqual(t, nil, stor.SetConfig(storage.FaultConfig{Methods: []string{"Update"}}))
+		assert.NotEqual(t, nil, stor.SetConfig(storage.FaultConfig{Latency: common.Duration{Duration: -time.Second}}))
+	})
+
+	t.Run("Errors of selected methods", func(t *testing.T) {
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{Methods: []string{"UpdateMetric"}, ErrorRate: 1}))
+
+		err := stor.UpdateMetric(context.TODO(), gauge("Alloc", 1))
+		assert.Equal(t, true, errors.Is(err, storage.ErrInjectedFault))
+
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{gauge("Alloc", 2)}))
+
+		metric, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+		require.NoError(t, err)
+		assert.Equal(t, float64(2), metric.Value)
+
+		assert.Equal(t, storage.FaultStats{Calls: 1, Errors: 1}, stor.State().Stats)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{Latency: common.Duration{Duration: 20 * time.Millisecond}}))
+
+		start := time.Now()
+		require.NoError(t, stor.Ping(context.TODO()))
+		assert.Equal(t, true, time.Since(start) >= 20*time.Millisecond)
+		assert.Equal(t, uint64(1), stor.State().Stats.Delayed)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{TimeoutRate: 1, Timeout: common.Duration{Duration: 10 * time.Millisecond}}))
+
+		_, err := stor.GetMetric(context.TODO(), common.GaugeMetricName, "Alloc")
+		assert.Equal(t, true, errors.Is(err, storage.ErrInjectedTimeout))
+
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{TimeoutRate: 1, Timeout: common.Duration{Duration: time.Hour}}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err = stor.GetMetric(ctx, common.GaugeMetricName, "Alloc")
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+
+	t.Run("Partial batch", func(t *testing.T) {
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{PartialBatchRate: 1, Seed: 1}))
+
+		batch := []common.Metric{gauge("B0", 0), gauge("B1", 1), gauge("B2", 2), gauge("B3", 3)}
+		err := stor.UpdateMetrics(context.TODO(), batch)
+		assert.Equal(t, true, errors.Is(err, storage.ErrInjectedFault))
+
+		saved := 0
+		for i, metric := range batch {
+			sm, err := baseStor.GetMetric(context.TODO(), common.GaugeMetricName, metric.ID)
+			require.NoError(t, err)
+
+			if sm == nil {
+				continue
+			}
+
+			// saved metrics are the first ones of the batch
+			assert.Equal(t, saved, i)
+			saved++
+		}
+
+		assert.Equal(t, true, saved > 0 && saved < len(batch))
+		assert.Equal(t, uint64(1), stor.State().Stats.PartialBatches)
+
+		// single metric can not fail partially
+		require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{gauge("B3", 3)}))
+	})
+
+	t.Run("Turned off", func(t *testing.T) {
+		require.NoError(t, stor.SetConfig(storage.FaultConfig{}))
+
+		require.NoError(t, stor.UpdateMetric(context.TODO(), gauge("Alloc", 3)))
+		assert.Equal(t, storage.FaultStats{Calls: 1}, stor.State().Stats)
+	})
+}
+
+func TestStorageMetrics(t *testing.T) {
+	baseStor, _ := storage.Init(nil)
+	faults := storage.WithFaults(baseStor)
+
+	metrics := storage.NewStorageMetrics([]float64{0.01, 1})
+	stor := storage.WithInstrumentation(faults, metrics)
+
+	selfMetric := func(id string, labels map[string]string) common.Metric {
+		for _, metric := range metrics.Metrics() {
+			if metric.ID == id && metric.Key() == common.SeriesKey(id, labels) {
+				return metric
+			}
+		}
+
+		t.Fatalf("no %s %v self-metric", id, labels)
+		return common.Metric{}
+	}
+
+	value := 1.0
+	alloc := common.Metric{ID: "Alloc", MType: common.GaugeMetricName, Value: &value}
+
+	require.NoError(t, faults.SetConfig(storage.FaultConfig{Methods: []string{"UpdateMetrics"}, Latency: common.Duration{Duration: 20 * time.Millisecond}}))
+	require.NoError(t, stor.UpdateMetrics(context.TODO(), []common.Metric{alloc}))
+
+	require.NoError(t, faults.SetConfig(storage.FaultConfig{Methods: []string{"UpdateMetrics"}, ErrorRate: 1}))
+	assert.NotEqual(t, nil, stor.UpdateMetrics(context.TODO(), []common.Metric{alloc}))
+
+	method := map[string]string{"method": "UpdateMetrics"}
+
+	latency := selfMetric(storage.StorageLatencyMetric, method).Histogram
+	assert.Equal(t, uint64(2), latency.Count)
+	// the delayed call is slower than the first bucket
+	assert.Equal(t, []common.HistogramBucket{{UpperBound: 0.01, Count: 1}, {UpperBound: 1, Count: 2}}, latency.Buckets)
+	assert.Equal(t, true, latency.Sum >= 0.02)
+
+	assert.Equal(t, int64(1), *selfMetric(storage.StorageErrorsMetric, method).Delta)
+	assert.Equal(t, float64(0), *selfMetric(storage.StorageInFlightMetric, method).Value)
+
+	t.Run("In flight calls", func(t *testing.T) {
+		require.NoError(t, faults.SetConfig(storage.FaultConfig{Methods: []string{"Ping"}, TimeoutRate: 1, Timeout: common.Duration{Duration: time.Hour}}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			stor.Ping(ctx)
+			close(done)
+		}()
+
+		ping := map[string]string{"method": "Ping"}
+		require.Eventually(t, func() bool {
+			return *selfMetric(storage.StorageInFlightMetric, ping).Value == 1
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+
+		assert.Equal(t, float64(0), *selfMetric(storage.StorageInFlightMetric, ping).Value)
+		assert.Equal(t, int64(1), *selfMetric(storage.StorageErrorsMetric, ping).Delta)
+	})
+
+	t.Run("Backup ticker", func(t *testing.T) {
+		backupConfig := storage.BackupConfig{FilePath: t.TempDir() + "/backup.json", Metrics: metrics}
+
+		stop := storage.InitBackupTicker(baseStor, backupConfig, 5*time.Millisecond)
+		defer stop()
+
+		require.Eventually(t, func() bool {
+			return selfMetric(storage.BackupLatencyMetric, map[string]string{"phase": "write"}).Histogram.Count > 0
+		}, time.Second, time.Millisecond)
+
+		assert.Equal(t, true, selfMetric(storage.BackupLatencyMetric, map[string]string{"phase": "snapshot"}).Histogram.Count > 0)
+		assert.Equal(t, int64(0), *selfMetric(storage.BackupErrorsMetric, map[string]string{"phase": "write"}).Delta)
+	})
+}
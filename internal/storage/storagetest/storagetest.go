@@ -0,0 +1,602 @@
+// Package storagetest is the conformance suite of storage.StorageInterface implementations.
+//
+// Every backend and wrapper is expected to behave the same way, so handlers and replication
+// do not depend on the storage the Server is started with:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.StorageInterface {
+//			stor, _ := storage.Init(nil)
+//			return stor
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+	"github.com/GermanVor/devops-pet-project/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns empty storage for the test, it should be closed by t.Cleanup.
+type Factory func(t *testing.T) storage.StorageInterface
+
+// PersistentFactory opens storage kept in path (missing path is empty storage),
+// close is called before path is opened again.
+type PersistentFactory func(t *testing.T, path string) (stor storage.StorageInterface, close func())
+
+// Run runs the conformance suite, every subtest gets new storage of newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, stor storage.StorageInterface)
+	}{
+		{"Update and get", testUpdateAndGet},
+		{"Missing metric", testMissingMetric},
+		{"History", testHistory},
+		{"Same ID of different types", testSameIDDifferentTypes},
+		{"Unknown type", testUnknownType},
+		{"Invalid metric", testInvalidMetric},
+		{"Batch", testBatch},
+		{"Failed batch", testFailedBatch},
+		{"Labels", testLabels},
+		{"Iteration", testIteration},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"Evict expired", testEvictExpired},
+		{"Concurrent updates", testConcurrentUpdates},
+		{"Ping", testPing},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+// RunPersistent checks that every successful update and deletion is kept after storage is reopened.
+func RunPersistent(t *testing.T, open PersistentFactory) {
+	ctx := context.Background()
+	path := t.TempDir() + "/metrics"
+
+	stor, closeStorage := open(t, path)
+
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Alloc", 1)))
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Sys", 4)))
+
+	deleted, err := stor.DeleteMetric(ctx, common.GaugeMetricName, "Sys")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	// batch is the last update, so it is not saved along with the others
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{
+		gauge("Alloc", 2),
+		counter("PollCount", 3),
+		histogram("Latency", 1, 1),
+		summary("Duration", 2, 3),
+	}))
+	require.Error(t, stor.UpdateMetrics(ctx, []common.Metric{counter("PollCount", 1), {MType: common.GaugeMetricName, ID: "Frees"}}))
+
+	expected := keys(t, stor)
+	closeStorage()
+
+	stor, closeStorage = open(t, path)
+	defer closeStorage()
+
+	require.Equal(t, expected, keys(t, stor))
+
+	sm := get(t, stor, common.GaugeMetricName, "Alloc")
+	require.Equal(t, 2.0, sm.Value)
+	require.Equal(t, uint64(2), sm.Version)
+	require.Equal(t, int64(3), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+	require.Equal(t, uint64(1), get(t, stor, common.HistogramMetricName, "Latency").Histogram.Count)
+	require.Equal(t, 3.0, get(t, stor, common.SummaryMetricName, "Duration").Summary.Sum)
+	requireMissing(t, stor, common.GaugeMetricName, "Sys")
+}
+
+func gauge(id string, value float64) common.Metric {
+	return common.Metric{MType: common.GaugeMetricName, ID: id, Value: &value}
+}
+
+func counter(id string, delta int64) common.Metric {
+	return common.Metric{MType: common.CounterMetricName, ID: id, Delta: &delta}
+}
+
+func histogram(id string, count uint64, sum float64) common.Metric {
+	return common.Metric{
+		MType: common.HistogramMetricName,
+		ID:    id,
+		Histogram: &common.Histogram{
+			Buckets: []common.HistogramBucket{{UpperBound: 1, Count: count}},
+			Sum:     sum,
+			Count:   count,
+		},
+	}
+}
+
+func summary(id string, count uint64, sum float64) common.Metric {
+	return common.Metric{
+		MType: common.SummaryMetricName,
+		ID:    id,
+		Summary: &common.Summary{
+			Quantiles: []common.SummaryQuantile{{Quantile: 0.5, Value: sum / float64(count)}},
+			Sum:       sum,
+			Count:     count,
+		},
+	}
+}
+
+// get returns stored metric which must exist.
+func get(t *testing.T, stor storage.StorageInterface, mType, id string) *storage.StorageMetric {
+	t.Helper()
+
+	sm, err := stor.GetMetric(context.Background(), mType, id)
+	require.NoError(t, err)
+	require.NotNil(t, sm, "%s %s is not stored", mType, id)
+	require.Equal(t, mType, sm.MType)
+
+	return sm
+}
+
+// requireMissing checks that metric is not stored.
+func requireMissing(t *testing.T, stor storage.StorageInterface, mType, id string) {
+	t.Helper()
+
+	sm, err := stor.GetMetric(context.Background(), mType, id)
+	require.NoError(t, err)
+	require.Nil(t, sm, "%s %s is stored", mType, id)
+}
+
+// keys returns `${mType}:${seriesKey}` of all the stored metrics, sorted.
+func keys(t *testing.T, stor storage.StorageInterface) []string {
+	t.Helper()
+
+	keys := make([]string, 0)
+	require.NoError(t, stor.ForEachMetrics(context.Background(), func(sm *storage.StorageMetric) {
+		keys = append(keys, sm.MType+":"+sm.Key())
+	}))
+	sort.Strings(keys)
+
+	return keys
+}
+
+// testUpdateAndGet: gauge and summary are overwritten, counter and histogram are summed,
+// gauge version is incremented by every update.
+func testUpdateAndGet(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Alloc", 1.5)))
+	require.NoError(t, stor.UpdateMetric(ctx, counter("PollCount", 2)))
+	require.NoError(t, stor.UpdateMetric(ctx, histogram("Latency", 1, 0.5)))
+	require.NoError(t, stor.UpdateMetric(ctx, summary("Duration", 2, 3)))
+
+	sm := get(t, stor, common.GaugeMetricName, "Alloc")
+	require.Equal(t, 1.5, sm.Value)
+	require.Equal(t, uint64(1), sm.Version)
+	require.Equal(t, int64(2), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Alloc", -3)))
+	require.NoError(t, stor.UpdateMetric(ctx, counter("PollCount", 3)))
+	require.NoError(t, stor.UpdateMetric(ctx, histogram("Latency", 2, 1.5)))
+	require.NoError(t, stor.UpdateMetric(ctx, summary("Duration", 4, 8)))
+
+	sm = get(t, stor, common.GaugeMetricName, "Alloc")
+	require.Equal(t, -3.0, sm.Value)
+	require.Equal(t, uint64(2), sm.Version)
+	require.Equal(t, int64(5), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+
+	h := get(t, stor, common.HistogramMetricName, "Latency").Histogram
+	require.NotNil(t, h)
+	require.Equal(t, uint64(3), h.Count)
+	require.Equal(t, 2.0, h.Sum)
+	require.Equal(t, []common.HistogramBucket{{UpperBound: 1, Count: 3}}, h.Buckets)
+
+	s := get(t, stor, common.SummaryMetricName, "Duration").Summary
+	require.NotNil(t, s)
+	require.Equal(t, uint64(4), s.Count)
+	require.Equal(t, 8.0, s.Sum)
+
+	// metrics of different types with the same ID are different metrics
+	require.NoError(t, stor.UpdateMetric(ctx, counter("Alloc", 7)))
+	require.Equal(t, -3.0, get(t, stor, common.GaugeMetricName, "Alloc").Value)
+	require.Equal(t, int64(7), get(t, stor, common.CounterMetricName, "Alloc").Delta)
+}
+
+// testMissingMetric: missing metric is nil without error for every read.
+func testMissingMetric(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	for _, mType := range []string{
+		common.GaugeMetricName,
+		common.CounterMetricName,
+		common.HistogramMetricName,
+		common.SummaryMetricName,
+	} {
+		requireMissing(t, stor, mType, "Missing")
+
+		deleted, err := stor.DeleteMetric(ctx, mType, "Missing")
+		require.NoError(t, err)
+		require.False(t, deleted)
+	}
+
+	points, err := stor.GetMetricHistory(ctx, common.GaugeMetricName, "Missing", time.Time{}, time.Now(), 0)
+	if !errors.Is(err, storage.ErrHistoryNotSupported) {
+		require.NoError(t, err)
+		require.Nil(t, points)
+	}
+
+	require.Empty(t, keys(t, stor))
+}
+
+// testHistory: history of existing series is not nil (even without points within the range),
+// history of missing series is nil.
+func testHistory(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Alloc", 1)))
+
+	points, err := stor.GetMetricHistory(ctx, common.GaugeMetricName, "Alloc", time.Time{}, time.Time{}, 0)
+	if errors.Is(err, storage.ErrHistoryNotSupported) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	require.NotEmpty(t, points)
+	require.Equal(t, 1.0, points[len(points)-1].Value)
+
+	for _, step := range []time.Duration{0, time.Minute} {
+		points, err = stor.GetMetricHistory(ctx, common.GaugeMetricName, "Alloc", time.Now().Add(time.Hour), time.Time{}, step)
+		require.NoError(t, err)
+		require.NotNil(t, points, "step %s", step)
+		require.Empty(t, points, "step %s", step)
+	}
+
+	points, err = stor.GetMetricHistory(ctx, common.CounterMetricName, "Alloc", time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	require.Nil(t, points)
+}
+
+// testSameIDDifferentTypes: series is identified by type and key (`(id, mType)` key of Postgres),
+// metrics of every type with the same ID are saved, read and deleted separately.
+func testSameIDDifferentTypes(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{
+		gauge("Metric", 1.5),
+		counter("Metric", 2),
+		histogram("Metric", 1, 0.5),
+		summary("Metric", 2, 3),
+	}))
+	require.NoError(t, stor.UpdateMetric(ctx, counter("Metric", 3)))
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("Metric", 2.5)))
+
+	require.Equal(t, 2.5, get(t, stor, common.GaugeMetricName, "Metric").Value)
+	require.Equal(t, int64(5), get(t, stor, common.CounterMetricName, "Metric").Delta)
+	require.Equal(t, uint64(1), get(t, stor, common.HistogramMetricName, "Metric").Histogram.Count)
+	require.Equal(t, 3.0, get(t, stor, common.SummaryMetricName, "Metric").Summary.Sum)
+
+	require.Equal(t, []string{"counter:Metric", "gauge:Metric", "histogram:Metric", "summary:Metric"}, keys(t, stor))
+
+	deleted, err := stor.DeleteMetric(ctx, common.CounterMetricName, "Metric")
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	requireMissing(t, stor, common.CounterMetricName, "Metric")
+	require.Equal(t, []string{"gauge:Metric", "histogram:Metric", "summary:Metric"}, keys(t, stor))
+}
+
+// testUnknownType: unknown type is rejected with storage.ErrUnknowMetricType and nothing is saved.
+func testUnknownType(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+	value := 1.0
+
+	_, err := stor.GetMetric(ctx, "qwerty", "Alloc")
+	require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+
+	err = stor.UpdateMetric(ctx, common.Metric{MType: "qwerty", ID: "Alloc", Value: &value})
+	require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+
+	err = stor.UpdateMetrics(ctx, []common.Metric{gauge("Alloc", 1), {MType: "qwerty", ID: "Alloc", Value: &value}})
+	require.ErrorIs(t, err, storage.ErrUnknowMetricType)
+
+	require.Empty(t, keys(t, stor))
+}
+
+// testInvalidMetric: metric without value of its type is rejected with validation error.
+func testInvalidMetric(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+	delta := int64(1)
+
+	for _, metric := range []common.Metric{
+		{MType: common.GaugeMetricName, ID: "Alloc"},
+		{MType: common.GaugeMetricName, ID: "Alloc", Delta: &delta},
+		{MType: common.CounterMetricName, ID: "PollCount"},
+		{MType: common.HistogramMetricName, ID: "Latency"},
+		{MType: common.SummaryMetricName, ID: "Duration"},
+		{MType: common.CounterMetricName, ID: "", Delta: &delta},
+	} {
+		err := stor.UpdateMetric(ctx, metric)
+		require.Error(t, err, "%s %q is saved", metric.MType, metric.ID)
+		require.True(t, common.IsValidationError(err), "%s %q: %v", metric.MType, metric.ID, err)
+	}
+
+	require.Empty(t, keys(t, stor))
+}
+
+// testBatch: batch is applied in order, gauges of the same series are overwritten, counters are summed.
+func testBatch(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{}))
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{
+		gauge("Alloc", 1),
+		counter("PollCount", 1),
+		gauge("Alloc", 2),
+		counter("PollCount", 2),
+		histogram("Latency", 1, 1),
+		histogram("Latency", 1, 2),
+		gauge("Sys", 3),
+	}))
+
+	require.Equal(t, 2.0, get(t, stor, common.GaugeMetricName, "Alloc").Value)
+	require.Equal(t, 3.0, get(t, stor, common.GaugeMetricName, "Sys").Value)
+	require.Equal(t, int64(3), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+	require.Equal(t, uint64(2), get(t, stor, common.HistogramMetricName, "Latency").Histogram.Count)
+
+	require.Equal(t, []string{"counter:PollCount", "gauge:Alloc", "gauge:Sys", "histogram:Latency"}, keys(t, stor))
+}
+
+// testFailedBatch: batch with invalid metric is rejected as a whole.
+func testFailedBatch(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetric(ctx, counter("PollCount", 1)))
+
+	err := stor.UpdateMetrics(ctx, []common.Metric{
+		gauge("Alloc", 1),
+		counter("PollCount", 1),
+		{MType: common.GaugeMetricName, ID: "Sys"},
+	})
+	require.Error(t, err)
+	require.True(t, common.IsValidationError(err), "%v", err)
+
+	requireMissing(t, stor, common.GaugeMetricName, "Alloc")
+	require.Equal(t, int64(1), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+
+	// failed precondition rejects the batch too
+	version := uint64(5)
+	conditional := gauge("Jobs", 1)
+	conditional.Precondition = &common.Precondition{Version: &version}
+
+	err = stor.UpdateMetrics(ctx, []common.Metric{counter("PollCount", 1), conditional})
+	require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+
+	requireMissing(t, stor, common.GaugeMetricName, "Jobs")
+	require.Equal(t, int64(1), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+}
+
+// testLabels: series with different labels are different metrics, labels are returned by reads.
+func testLabels(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	a := gauge("Alloc", 1)
+	a.Labels = map[string]string{"host": "a"}
+	b := gauge("Alloc", 2)
+	b.Labels = map[string]string{"host": "b", "region": "eu"}
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{a, b, gauge("Alloc", 3)}))
+
+	sm := get(t, stor, common.GaugeMetricName, `Alloc{host="b",region="eu"}`)
+	require.Equal(t, 2.0, sm.Value)
+	require.Equal(t, "Alloc", sm.ID)
+	require.Equal(t, b.Labels, sm.Labels)
+
+	require.Equal(t, 1.0, get(t, stor, common.GaugeMetricName, `Alloc{host="a"}`).Value)
+	require.Equal(t, 3.0, get(t, stor, common.GaugeMetricName, "Alloc").Value)
+
+	require.Equal(t, []string{"gauge:Alloc", `gauge:Alloc{host="a"}`, `gauge:Alloc{host="b",region="eu"}`}, keys(t, stor))
+}
+
+// testIteration: ForEachMetrics passes every metric once with its value.
+func testIteration(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	metrics := make([]common.Metric, 0)
+	for i := 0; i < 50; i++ {
+		metrics = append(metrics, gauge(fmt.Sprintf("Gauge%02d", i), float64(i)), counter(fmt.Sprintf("Counter%02d", i), int64(i)))
+	}
+	require.NoError(t, stor.UpdateMetrics(ctx, metrics))
+
+	seen := make(map[string]int)
+	require.NoError(t, stor.ForEachMetrics(ctx, func(sm *storage.StorageMetric) {
+		seen[sm.MType+":"+sm.Key()]++
+
+		var i int
+		switch sm.MType {
+		case common.GaugeMetricName:
+			fmt.Sscanf(sm.ID, "Gauge%02d", &i)
+			require.Equal(t, float64(i), sm.Value)
+		case common.CounterMetricName:
+			fmt.Sscanf(sm.ID, "Counter%02d", &i)
+			require.Equal(t, int64(i), sm.Delta)
+		}
+	}))
+
+	require.Equal(t, len(metrics), len(seen))
+	for key, count := range seen {
+		require.Equal(t, 1, count, key)
+	}
+}
+
+// testList: pages are ordered by type and series key, filtered and do not overlap.
+func testList(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	metrics := make([]common.Metric, 0)
+	for i := 0; i < 7; i++ {
+		metrics = append(metrics, gauge(fmt.Sprintf("Heap%d", i), float64(i)), counter(fmt.Sprintf("Poll%d", i), 1))
+	}
+	require.NoError(t, stor.UpdateMetrics(ctx, metrics))
+
+	all := make([]string, 0)
+	query := storage.ListQuery{Limit: 3}
+	for {
+		page, err := stor.ListMetrics(ctx, query)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Metrics), 3)
+
+		for _, sm := range page.Metrics {
+			all = append(all, sm.MType+":"+sm.Key())
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	require.Equal(t, keys(t, stor), all)
+
+	filter, err := storage.NewMetricsFilter(common.GaugeMetricName, "Heap", "Heap[0-2]", "")
+	require.NoError(t, err)
+
+	page, err := stor.ListMetrics(ctx, storage.ListQuery{Filter: *filter})
+	require.NoError(t, err)
+	require.Empty(t, page.NextCursor)
+	require.Len(t, page.Metrics, 3)
+	require.Equal(t, "Heap0", page.Metrics[0].ID)
+	require.Equal(t, 2.0, page.Metrics[2].Value)
+
+	_, err = stor.ListMetrics(ctx, storage.ListQuery{Cursor: "!"})
+	require.ErrorIs(t, err, storage.ErrInvalidListQuery)
+}
+
+// testDelete: deleted metric is missing, deletion by filter returns deleted series.
+func testDelete(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{
+		gauge("HeapAlloc", 1),
+		gauge("HeapSys", 2),
+		gauge("Alloc", 3),
+		counter("HeapAlloc", 4),
+	}))
+
+	deleted, err := stor.DeleteMetric(ctx, common.CounterMetricName, "HeapAlloc")
+	require.NoError(t, err)
+	require.True(t, deleted)
+	requireMissing(t, stor, common.CounterMetricName, "HeapAlloc")
+	require.Equal(t, 1.0, get(t, stor, common.GaugeMetricName, "HeapAlloc").Value)
+
+	filter, err := storage.NewMetricsFilter(common.GaugeMetricName, "Heap", "", "")
+	require.NoError(t, err)
+
+	deletedMetrics, err := stor.DeleteMetrics(ctx, *filter)
+	require.NoError(t, err)
+
+	deletedKeys := make([]string, 0, len(deletedMetrics))
+	for _, sm := range deletedMetrics {
+		deletedKeys = append(deletedKeys, sm.MType+":"+sm.Key())
+	}
+	sort.Strings(deletedKeys)
+	require.Equal(t, []string{"gauge:HeapAlloc", "gauge:HeapSys"}, deletedKeys)
+
+	require.Equal(t, []string{"gauge:Alloc"}, keys(t, stor))
+
+	// deleted metric starts again
+	require.NoError(t, stor.UpdateMetric(ctx, gauge("HeapAlloc", 5)))
+	sm := get(t, stor, common.GaugeMetricName, "HeapAlloc")
+	require.Equal(t, 5.0, sm.Value)
+	require.Equal(t, uint64(1), sm.Version)
+}
+
+// testEvictExpired: metrics not updated within TTL are removed and returned.
+func testEvictExpired(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	require.NoError(t, stor.UpdateMetrics(ctx, []common.Metric{gauge("Alloc", 1), counter("PollCount", 1)}))
+
+	evicted, err := stor.EvictExpired(ctx, &storage.TTLPolicy{Default: time.Hour}, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, evicted)
+
+	evicted, err = stor.EvictExpired(ctx, &storage.TTLPolicy{Default: time.Hour}, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, evicted, 2)
+
+	require.Empty(t, keys(t, stor))
+}
+
+// testConcurrentUpdates: concurrent updates are not lost and reads see consistent metrics.
+func testConcurrentUpdates(t *testing.T, stor storage.StorageInterface) {
+	ctx := context.Background()
+
+	const (
+		writers = 8
+		updates = 25
+	)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, writers*2)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < updates; i++ {
+				err := stor.UpdateMetrics(ctx, []common.Metric{
+					counter("PollCount", 1),
+					gauge(fmt.Sprintf("Writer%d", w), float64(i)),
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < updates; i++ {
+				if _, err := stor.GetMetric(ctx, common.CounterMetricName, "PollCount"); err != nil {
+					errs <- err
+					return
+				}
+				if err := stor.ForEachMetrics(ctx, func(*storage.StorageMetric) {}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int64(writers*updates), get(t, stor, common.CounterMetricName, "PollCount").Delta)
+	for w := 0; w < writers; w++ {
+		sm := get(t, stor, common.GaugeMetricName, fmt.Sprintf("Writer%d", w))
+		require.Equal(t, float64(updates-1), sm.Value)
+		require.Equal(t, uint64(updates), sm.Version)
+	}
+}
+
+func testPing(t *testing.T, stor storage.StorageInterface) {
+	require.NoError(t, stor.Ping(context.Background()))
+}
@@ -0,0 +1,422 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+// TenantLabel is the reserved label which keeps metrics of tenants apart in the storage.
+const TenantLabel = "__tenant__"
+
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+	ErrTenantLimit   = errors.New("tenant limit is exceeded")
+)
+
+var tenantNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Tenant has own metrics, hash key and limits (0 - no limit).
+// Tenant header is not authenticated, so tenant without key should be marked public:
+// anyone may update and read its metrics.
+type Tenant struct {
+	Name   string `json:"name"`
+	Key    string `json:"key,omitempty"`
+	Public bool   `json:"public,omitempty"`
+	// MaxSeries is the number of series the tenant may keep in the storage
+	MaxSeries int `json:"max_series,omitempty"`
+	// MaxBatchSize is the number of metrics the tenant may update at once
+	MaxBatchSize int `json:"max_batch_size,omitempty"`
+}
+
+// Tenants is the list of tenants known to the server.
+type Tenants struct {
+	list   []*Tenant
+	byName map[string]*Tenant
+}
+
+// ParseTenants parses JSON list of tenants.
+func ParseTenants(data []byte) (*Tenants, error) {
+	list := make([]*Tenant, 0)
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	tenants := &Tenants{
+		list:   list,
+		byName: make(map[string]*Tenant),
+	}
+
+	for _, tenant := range list {
+		if !tenantNameRegexp.MatchString(tenant.Name) {
+			return nil, fmt.Errorf("tenant %q: name should be %s", tenant.Name, tenantNameRegexp)
+		}
+
+		if _, ok := tenants.byName[tenant.Name]; ok {
+			return nil, fmt.Errorf("tenant %q: duplicated name", tenant.Name)
+		}
+
+		if tenant.Key == "" && !tenant.Public {
+			return nil, fmt.Errorf("tenant %q: key is required unless tenant is public", tenant.Name)
+		}
+
+		if tenant.MaxSeries < 0 || tenant.MaxBatchSize < 0 {
+			return nil, fmt.Errorf("tenant %q: negative limit", tenant.Name)
+		}
+
+		tenants.byName[tenant.Name] = tenant
+	}
+
+	return tenants, nil
+}
+
+// LoadTenants reads JSON list of tenants from file.
+func LoadTenants(filePath string) (*Tenants, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseTenants(data)
+}
+
+// Get returns tenant by name, nil if it is unknown.
+func (tenants *Tenants) Get(name string) *Tenant {
+	return tenants.byName[name]
+}
+
+// ByHash returns the first tenant whose key signs the metric, nil if there is no such tenant.
+func (tenants *Tenants) ByHash(metric *common.Metric) *Tenant {
+	if metric.Hash == nil {
+		return nil
+	}
+
+	for _, tenant := range tenants.list {
+		if tenant.Key == "" {
+			continue
+		}
+
+		if ok, _ := metric.CheckHash(tenant.Key); ok {
+			return tenant
+		}
+	}
+
+	return nil
+}
+
+type tenantContextKey struct{}
+
+// ContextWithTenant scopes storage requests with ctx to the tenant.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns tenant of ctx, empty string is the default tenant.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+
+	return tenant
+}
+
+// tenantLabels returns labels of the tenant series, labels are not changed.
+func tenantLabels(tenant string, labels map[string]string) map[string]string {
+	if tenant == "" {
+		return labels
+	}
+
+	tenantLabels := make(map[string]string, len(labels)+1)
+	for name, value := range labels {
+		tenantLabels[name] = value
+	}
+	tenantLabels[TenantLabel] = tenant
+
+	return tenantLabels
+}
+
+// TenantStorageWrapper keeps metrics of the tenant from request context under TenantLabel,
+// metrics of the default tenant have no TenantLabel. Reads see only metrics of the tenant without TenantLabel.
+// EvictExpired is not scoped.
+type TenantStorageWrapper struct {
+	StorageInterface
+
+	tenants *Tenants
+
+	mutex sync.Mutex
+	// series are keys of stored series by tenant with series limit, loaded on the first update
+	series map[string]map[string]struct{}
+}
+
+func WithTenants(stor StorageInterface, tenants *Tenants) *TenantStorageWrapper {
+	return &TenantStorageWrapper{
+		StorageInterface: stor,
+		tenants:          tenants,
+		series:           make(map[string]map[string]struct{}),
+	}
+}
+
+// tenantKey returns series key of the tenant, false if series key has TenantLabel.
+func tenantKey(tenant, key string) (string, bool, error) {
+	id, labels, err := common.ParseSeriesKey(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if _, ok := labels[TenantLabel]; ok {
+		return "", false, nil
+	}
+
+	return common.SeriesKey(id, tenantLabels(tenant, labels)), true, nil
+}
+
+// scoped returns metric without TenantLabel if it belongs to the tenant.
+func scoped(tenant string, sm *StorageMetric) (*StorageMetric, bool) {
+	if sm.Labels[TenantLabel] != tenant {
+		return nil, false
+	}
+
+	if tenant == "" {
+		return sm, true
+	}
+
+	metric := *sm
+	metric.Labels = make(map[string]string, len(sm.Labels)-1)
+	for name, value := range sm.Labels {
+		if name != TenantLabel {
+			metric.Labels[name] = value
+		}
+	}
+	if len(metric.Labels) == 0 {
+		metric.Labels = nil
+	}
+
+	return &metric, true
+}
+
+func (stor *TenantStorageWrapper) ForEachMetrics(ctx context.Context, handler func(*StorageMetric)) error {
+	tenant := TenantFromContext(ctx)
+
+	return stor.StorageInterface.ForEachMetrics(ctx, func(sm *StorageMetric) {
+		if metric, ok := scoped(tenant, sm); ok {
+			handler(metric)
+		}
+	})
+}
+
+func (stor *TenantStorageWrapper) GetMetric(ctx context.Context, mType string, id string) (*StorageMetric, error) {
+	tenant := TenantFromContext(ctx)
+
+	key, ok, err := tenantKey(tenant, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	metric, err := stor.StorageInterface.GetMetric(ctx, mType, key)
+	if err != nil || metric == nil {
+		return metric, err
+	}
+
+	metric, _ = scoped(tenant, metric)
+
+	return metric, nil
+}
+
+func (stor *TenantStorageWrapper) GetMetricHistory(
+	ctx context.Context,
+	mType string,
+	id string,
+	from, to time.Time,
+	step time.Duration,
+) ([]*HistoryPoint, error) {
+	key, ok, err := tenantKey(TenantFromContext(ctx), id)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return stor.StorageInterface.GetMetricHistory(ctx, mType, key, from, to, step)
+}
+
+// reserveSeries checks series limit of the tenant and remembers new series of metrics.
+func (stor *TenantStorageWrapper) reserveSeries(ctx context.Context, tenant *Tenant, metricsList []common.Metric) error {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	series, ok := stor.series[tenant.Name]
+	if !ok {
+		series = make(map[string]struct{})
+
+		err := stor.StorageInterface.ForEachMetrics(ctx, func(sm *StorageMetric) {
+			if sm.Labels[TenantLabel] == tenant.Name {
+				series[historyKey(sm.MType, sm.Key())] = struct{}{}
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		stor.series[tenant.Name] = series
+	}
+
+	newSeries := make(map[string]struct{})
+	for _, metric := range metricsList {
+		key := historyKey(metric.MType, metric.Key())
+		if _, ok := series[key]; !ok {
+			newSeries[key] = struct{}{}
+		}
+	}
+
+	if len(series)+len(newSeries) > tenant.MaxSeries {
+		return fmt.Errorf("%w: %d series of tenant %q", ErrTenantLimit, tenant.MaxSeries, tenant.Name)
+	}
+
+	for key := range newSeries {
+		series[key] = struct{}{}
+	}
+
+	return nil
+}
+
+func (stor *TenantStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+func (stor *TenantStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	name := TenantFromContext(ctx)
+
+	var tenant *Tenant
+	if name != "" {
+		if tenant = stor.tenants.Get(name); tenant == nil {
+			return fmt.Errorf("%w: %q", ErrUnknownTenant, name)
+		}
+
+		if tenant.MaxBatchSize > 0 && len(metricsList) > tenant.MaxBatchSize {
+			return fmt.Errorf("%w: %d metrics in batch of tenant %q", ErrTenantLimit, tenant.MaxBatchSize, name)
+		}
+	}
+
+	metrics := make([]common.Metric, len(metricsList))
+	for i, metric := range metricsList {
+		if _, ok := metric.Labels[TenantLabel]; ok {
+			return fmt.Errorf("%w: %q is reserved", common.ErrInvalidLabel, TenantLabel)
+		}
+
+		metric.Labels = tenantLabels(name, metric.Labels)
+		metrics[i] = metric
+	}
+
+	if tenant != nil && tenant.MaxSeries > 0 {
+		if err := stor.reserveSeries(ctx, tenant, metrics); err != nil {
+			return err
+		}
+	}
+
+	err := stor.StorageInterface.UpdateMetrics(ctx, metrics)
+	if err != nil && tenant != nil {
+		// failed update may leave reserved series which are not saved
+		stor.mutex.Lock()
+		delete(stor.series, tenant.Name)
+		stor.mutex.Unlock()
+	}
+
+	return err
+}
+
+func (stor *TenantStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+	stor.forgetSeries(evicted)
+
+	return evicted, err
+}
+
+// forgetSeries removes deleted metrics (with TenantLabel) from series of their tenants.
+func (stor *TenantStorageWrapper) forgetSeries(deleted []*StorageMetric) {
+	stor.mutex.Lock()
+	defer stor.mutex.Unlock()
+
+	for _, sm := range deleted {
+		if series, ok := stor.series[sm.Labels[TenantLabel]]; ok {
+			delete(series, historyKey(sm.MType, sm.Key()))
+		}
+	}
+}
+
+// tenantFilter returns filter which selects metrics of the tenant only.
+func tenantFilter(tenant string, filter MetricsFilter) (MetricsFilter, error) {
+	matcher, err := common.NewLabelMatcher(TenantLabel, common.MatchEqual, tenant)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Matchers = append([]*common.LabelMatcher{matcher}, filter.Matchers...)
+
+	return filter, nil
+}
+
+// ListMetrics lists metrics of the tenant, the cursor is position of the series with TenantLabel.
+func (stor *TenantStorageWrapper) ListMetrics(ctx context.Context, query ListQuery) (*MetricsPage, error) {
+	tenant := TenantFromContext(ctx)
+
+	var err error
+	if query.Filter, err = tenantFilter(tenant, query.Filter); err != nil {
+		return nil, err
+	}
+
+	page, err := stor.StorageInterface.ListMetrics(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]*StorageMetric, 0, len(page.Metrics))
+	for _, sm := range page.Metrics {
+		if metric, ok := scoped(tenant, sm); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	return &MetricsPage{
+		Metrics:    metrics,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+func (stor *TenantStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	key, ok, err := tenantKey(TenantFromContext(ctx), id)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	deleted, err := stor.StorageInterface.DeleteMetric(ctx, mType, key)
+	if deleted {
+		stor.forgetSeries([]*StorageMetric{newStorageMetric(mType, key)})
+	}
+
+	return deleted, err
+}
+
+// DeleteMetrics deletes metrics of the tenant selected by filter and returns them without TenantLabel.
+func (stor *TenantStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	tenant := TenantFromContext(ctx)
+
+	filter, err := tenantFilter(tenant, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+	stor.forgetSeries(deleted)
+
+	metrics := make([]*StorageMetric, 0, len(deleted))
+	for _, sm := range deleted {
+		if metric, ok := scoped(tenant, sm); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	return metrics, err
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TTLRule sets time to live of metrics which ID (without labels) matches Pattern (path.Match syntax, e.g. Heap*).
+type TTLRule struct {
+	Pattern string
+	TTL     time.Duration
+}
+
+// TTLPolicy decides when metric is expired. The first matching rule wins,
+// metrics not matching any rule live for Default. Zero TTL means metric never expires.
+type TTLPolicy struct {
+	Default time.Duration
+	Rules   []TTLRule
+}
+
+// NewTTLPolicy builds policy from default TTL and rules in `pattern=ttl,pattern=ttl` format.
+func NewTTLPolicy(defaultTTL time.Duration, rulesStr string) (*TTLPolicy, error) {
+	policy := &TTLPolicy{Default: defaultTTL}
+
+	for _, ruleStr := range strings.Split(rulesStr, ",") {
+		ruleStr = strings.TrimSpace(ruleStr)
+		if ruleStr == "" {
+			continue
+		}
+
+		pattern, ttlStr, ok := strings.Cut(ruleStr, "=")
+		if !ok {
+			return nil, fmt.Errorf("ttl rule %q: pattern=ttl expected", ruleStr)
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("ttl rule %q: %w", ruleStr, err)
+		}
+
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("ttl rule %q: %w", ruleStr, err)
+		}
+
+		policy.Rules = append(policy.Rules, TTLRule{Pattern: pattern, TTL: ttl})
+	}
+
+	return policy, nil
+}
+
+// TTL returns time to live of metric with id.
+func (p *TTLPolicy) TTL(id string) time.Duration {
+	for _, rule := range p.Rules {
+		if ok, _ := path.Match(rule.Pattern, id); ok {
+			return rule.TTL
+		}
+	}
+
+	return p.Default
+}
+
+// MinTTL returns the least non zero TTL of the policy (0 if metrics never expire).
+func (p *TTLPolicy) MinTTL() time.Duration {
+	minTTL := p.Default
+
+	for _, rule := range p.Rules {
+		if rule.TTL > 0 && (minTTL == 0 || rule.TTL < minTTL) {
+			minTTL = rule.TTL
+		}
+	}
+
+	return minTTL
+}
+
+// IsExpired reports if metric with id updated at updatedAt is expired at the moment now.
+func (p *TTLPolicy) IsExpired(id string, updatedAt, now time.Time) bool {
+	ttl := p.TTL(id)
+
+	return ttl > 0 && now.Sub(updatedAt) > ttl
+}
+
+// Janitor periodically evicts expired metrics from the storage.
+type Janitor struct {
+	stor    StorageInterface
+	policy  *TTLPolicy
+	evicted uint64
+}
+
+func NewJanitor(stor StorageInterface, policy *TTLPolicy) *Janitor {
+	return &Janitor{
+		stor:   stor,
+		policy: policy,
+	}
+}
+
+// Evicted returns the number of metrics evicted since Janitor creation.
+func (j *Janitor) Evicted() uint64 {
+	return atomic.LoadUint64(&j.evicted)
+}
+
+// Run evicts metrics expired by now.
+func (j *Janitor) Run(ctx context.Context, now time.Time) error {
+	evicted, err := j.stor.EvictExpired(ctx, j.policy, now)
+
+	for _, sm := range evicted {
+		log.Printf("Metric %s %s is evicted by TTL\n", sm.MType, sm.Key())
+	}
+
+	if len(evicted) != 0 {
+		total := atomic.AddUint64(&j.evicted, uint64(len(evicted)))
+		log.Printf("%d metrics are evicted by TTL, %d in total\n", len(evicted), total)
+	}
+
+	return err
+}
+
+// Start runs eviction every interval until returned function is called.
+// Read-only replica is skipped quietly, it drops metrics evicted by its primary.
+func (j *Janitor) Start(interval time.Duration) func() {
+	return startTicker(interval, func() {
+		if err := j.Run(context.Background(), time.Now()); err != nil && !errors.Is(err, ErrReadOnlyReplica) {
+			log.Println("Could not evict expired metrics", err)
+		}
+	})
+}
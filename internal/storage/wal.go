@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+const walFileSuffix = ".wal"
+
+// ErrWALCorrupted is returned by replay of write-ahead log with damaged record which is not the unfinished last one.
+var ErrWALCorrupted = errors.New("write-ahead log is corrupted")
+
+// WALFilePath returns path of the write-ahead log kept next to backup file.
+func WALFilePath(backupFilePath string) string {
+	return backupFilePath + walFileSuffix
+}
+
+// walRecord is a single line of the write-ahead log.
+type walRecord struct {
+	Sequence  uint64          `json:"seq"`
+	Timestamp time.Time       `json:"ts"`
+	Metrics   []common.Metric `json:"metrics"`
+}
+
+// WALStorageWrapper appends every update to the write-ahead log before applying it to Storage.
+// The log is compacted into backup file (snapshot) by Compact.
+type WALStorageWrapper struct {
+	*Storage
+	backupConfig BackupConfig
+
+	walFile  *os.File
+	walSize  int64
+	walMutex sync.Mutex
+
+	stopCompaction func()
+}
+
+// replayWAL applies log records (decoded by codec) newer than the restored backup to the storage.
+// Unfinished last record (server crashed while writing it) is ignored, replay stops at damaged record
+// with ErrWALCorrupted and at record which needs another codec key setting with the codec error.
+// Records should follow one another by sequence number, encrypted ones are bound to their numbers.
+// The first record which can not be decrypted is read with the wrong key, so it is not reported as damaged.
+func replayWAL(stor *Storage, walFilePath string, codec *BackupCodec) (int, error) {
+	file, err := os.Open(walFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	records := 0
+	lineNumber := 0
+	previousSequence := uint64(0)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) != 0 {
+				log.Println("Write-ahead log ends with unfinished record, it is skipped")
+			}
+
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+
+		lineNumber++
+
+		recordBytes, sequence, err := codec.DecodeRecord(bytes.TrimSuffix(line, []byte{'\n'}))
+		if errors.Is(err, ErrBackupNotEncrypted) || errors.Is(err, ErrBackupKeyRequired) ||
+			errors.Is(err, ErrBackupDecrypt) && lineNumber == 1 {
+			return records, err
+		}
+		if err != nil {
+			return records, fmt.Errorf("%w: line %d: %s", ErrWALCorrupted, lineNumber, err)
+		}
+
+		record := &walRecord{}
+		if err := json.Unmarshal(recordBytes, record); err != nil {
+			return records, fmt.Errorf("%w: line %d: %s", ErrWALCorrupted, lineNumber, err)
+		}
+
+		if sequence != 0 && record.Sequence != sequence {
+			return records, fmt.Errorf("%w: line %d: record %d is encrypted as %d", ErrWALCorrupted, lineNumber, record.Sequence, sequence)
+		}
+		// records are appended one by one, so a moved, repeated or dropped record breaks the sequence
+		if previousSequence != 0 && record.Sequence != previousSequence+1 {
+			return records, fmt.Errorf("%w: line %d: record %d does not follow record %d",
+				ErrWALCorrupted, lineNumber, record.Sequence, previousSequence)
+		}
+		previousSequence = record.Sequence
+
+		if record.Sequence <= atomic.LoadUint64(&stor.walSequence) {
+			continue
+		}
+
+		if err := validateMetrics(record.Metrics); err != nil {
+			return records, fmt.Errorf("%w: line %d: %s", ErrWALCorrupted, lineNumber, err)
+		}
+
+		timestamp := record.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+
+		stor.applyMetrics(record.Metrics, timestamp)
+		atomic.StoreUint64(&stor.walSequence, record.Sequence)
+		records++
+	}
+}
+
+// moveWALAside renames write-ahead log which could not be replayed, so it is not truncated by compaction
+// and records after the damaged one may be recovered by hand.
+func moveWALAside(walFilePath string) (string, error) {
+	path := fmt.Sprintf("%s.corrupt-%s", walFilePath, time.Now().UTC().Format(snapshotTimeLayout))
+
+	return path, os.Rename(walFilePath, path)
+}
+
+// WithWAL opens write-ahead log of backupConfig.FilePath and compacts it right away,
+// so the log starts from the current state of stor. Storage which restored nothing is not compacted
+// if there is neither backup nor log records to replace, the first backup is written by compaction.
+// Non zero compactInterval turns on scheduled compaction.
+func WithWAL(stor *Storage, backupConfig BackupConfig, compactInterval time.Duration) (*WALStorageWrapper, error) {
+	walFile, err := os.OpenFile(WALFilePath(backupConfig.FilePath), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := &WALStorageWrapper{
+		Storage:      stor,
+		backupConfig: backupConfig,
+		walFile:      walFile,
+	}
+
+	walInfo, err := walFile.Stat()
+	if err != nil {
+		walFile.Close()
+		return nil, err
+	}
+
+	// backup and log which were not restored are replaced, otherwise the next restore would mix their metrics
+	// with the new ones and skip new records with sequence numbers of the old backup
+	if stor.restored || walInfo.Size() != 0 || hasBackup(backupConfig.FilePath) {
+		if err = wrapper.Compact(); err != nil {
+			walFile.Close()
+			return nil, err
+		}
+	}
+
+	if compactInterval > 0 {
+		wrapper.stopCompaction = startTicker(compactInterval, func() {
+			if err := wrapper.Compact(); err != nil {
+				log.Println("Could not compact write-ahead log", err)
+			}
+		})
+	}
+
+	return wrapper, nil
+}
+
+// Compact writes backup file with the current state of Storage and truncates the log.
+func (stor *WALStorageWrapper) Compact() error {
+	stor.walMutex.Lock()
+	defer stor.walMutex.Unlock()
+
+	return stor.compactLocked()
+}
+
+func (stor *WALStorageWrapper) compactLocked() error {
+	if err := writeStoreBackup(stor.Storage, stor.backupConfig); err != nil {
+		return err
+	}
+
+	if err := stor.walFile.Truncate(0); err != nil {
+		return err
+	}
+	stor.walSize = 0
+
+	return stor.walFile.Sync()
+}
+
+// Close stops scheduled compaction, compacts the log and closes it.
+func (stor *WALStorageWrapper) Close() {
+	if stor.stopCompaction != nil {
+		stor.stopCompaction()
+	}
+
+	if err := stor.Compact(); err != nil {
+		log.Println("Could not compact write-ahead log", err)
+	}
+
+	stor.walFile.Close()
+}
+
+func (stor *WALStorageWrapper) appendMetrics(metricsList []common.Metric) error {
+	if err := validateMetrics(metricsList); err != nil {
+		return err
+	}
+
+	stor.walMutex.Lock()
+	defer stor.walMutex.Unlock()
+
+	// metrics are saved only under walMutex, so the checked gauges are not changed until the record is applied,
+	// replayed records are applied without checks
+	if hasPreconditions(metricsList) {
+		if err := checkPreconditions(metricsList, stor.Storage.gaugeState); err != nil {
+			return err
+		}
+	}
+
+	// walSequence is changed only under walMutex
+	record := walRecord{
+		Sequence:  atomic.LoadUint64(&stor.Storage.walSequence) + 1,
+		Timestamp: time.Now(),
+		Metrics:   metricsList,
+	}
+
+	recordBytes, err := json.Marshal(&record)
+	if err == nil {
+		recordBytes, err = stor.backupConfig.Codec.EncodeRecord(recordBytes, record.Sequence)
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := stor.walFile.Write(append(recordBytes, '\n'))
+	if err == nil {
+		err = stor.walFile.Sync()
+	}
+	if err != nil {
+		// drop partially written record, so the next ones stay readable
+		stor.walFile.Truncate(stor.walSize)
+		return err
+	}
+	stor.walSize += int64(n)
+
+	stor.Storage.applyMetrics(metricsList, record.Timestamp)
+	atomic.StoreUint64(&stor.Storage.walSequence, record.Sequence)
+
+	return nil
+}
+
+func (stor *WALStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.appendMetrics([]common.Metric{metric})
+}
+
+func (stor *WALStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	return stor.appendMetrics(metricsList)
+}
+
+// EvictExpired evicts metrics from Storage and compacts the log,
+// so evicted metrics are not brought back by replay.
+func (stor *WALStorageWrapper) EvictExpired(
+	ctx context.Context,
+	policy *TTLPolicy,
+	now time.Time,
+) ([]*StorageMetric, error) {
+	stor.walMutex.Lock()
+	defer stor.walMutex.Unlock()
+
+	evicted, err := stor.Storage.EvictExpired(ctx, policy, now)
+	if err != nil || len(evicted) == 0 {
+		return evicted, err
+	}
+
+	return evicted, stor.compactLocked()
+}
+
+// DeleteMetric deletes the metric from Storage and compacts the log,
+// so the metric is not brought back by replay.
+func (stor *WALStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	stor.walMutex.Lock()
+	defer stor.walMutex.Unlock()
+
+	deleted, err := stor.Storage.DeleteMetric(ctx, mType, id)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+
+	return deleted, stor.compactLocked()
+}
+
+// DeleteMetrics deletes metrics from Storage and compacts the log,
+// so the metrics are not brought back by replay.
+func (stor *WALStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	stor.walMutex.Lock()
+	defer stor.walMutex.Unlock()
+
+	deleted, err := stor.Storage.DeleteMetrics(ctx, filter)
+	if err != nil || len(deleted) == 0 {
+		return deleted, err
+	}
+
+	return deleted, stor.compactLocked()
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/GermanVor/devops-pet-project/internal/common"
+)
+
+const DefaultWatchBufferSize = 256
+
+var (
+	// ErrWatchLagged ends subscription which did not receive events as fast as they were published
+	ErrWatchLagged = errors.New("watch subscription is too slow")
+	// ErrWatchClosed ends subscriptions of the closed hub
+	ErrWatchClosed = errors.New("watch hub is closed")
+)
+
+// WatchStats is subscription and delivery statistics of WatchHub.
+type WatchStats struct {
+	Subscriptions int    `json:"subscriptions"`
+	Published     uint64 `json:"published"`
+	Lagged        uint64 `json:"lagged"`
+	BufferSize    int    `json:"buffer_size"`
+}
+
+type WatchEventType string
+
+const (
+	WatchUpdated WatchEventType = "updated"
+	WatchDeleted WatchEventType = "deleted"
+)
+
+// WatchEvent is a change of the metric. Updated metric has its value after the update,
+// deleted metric has type and series only.
+type WatchEvent struct {
+	Type      WatchEventType
+	Metric    *StorageMetric
+	Timestamp time.Time
+}
+
+// Subscription receives events of metrics selected by its filter until it is closed.
+type Subscription struct {
+	hub    *WatchHub
+	tenant string
+	filter MetricsFilter
+	events chan WatchEvent
+	// err is set under the hub lock before events are closed
+	err error
+}
+
+// Events returns channel of events, it is closed when the subscription ends.
+func (sub *Subscription) Events() <-chan WatchEvent {
+	return sub.events
+}
+
+// Err returns the reason the subscription was ended by the hub (ErrWatchLagged or ErrWatchClosed),
+// nil if it was closed by Close. It should be called after Events is closed.
+func (sub *Subscription) Err() error {
+	sub.hub.mutex.Lock()
+	defer sub.hub.mutex.Unlock()
+
+	return sub.err
+}
+
+// Close ends the subscription.
+func (sub *Subscription) Close() {
+	sub.hub.mutex.Lock()
+	defer sub.hub.mutex.Unlock()
+
+	sub.hub.removeLocked(sub, nil)
+}
+
+// WatchHub delivers published changes of metrics to subscriptions. Publishing never waits for subscribers:
+// every subscription has a buffer of events, subscription with the full buffer is ended with ErrWatchLagged.
+type WatchHub struct {
+	bufferSize int
+
+	mutex         sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+	stats         WatchStats
+}
+
+// NewWatchHub creates hub with bufferSize events buffered for every subscription (at least one).
+func NewWatchHub(bufferSize int) *WatchHub {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	return &WatchHub{
+		bufferSize:    bufferSize,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe creates subscription to changes of metrics of ctx tenant selected by filter,
+// events of tenant metrics have no TenantLabel.
+func (hub *WatchHub) Subscribe(ctx context.Context, filter MetricsFilter) (*Subscription, error) {
+	tenant := TenantFromContext(ctx)
+
+	filter, err := tenantFilter(tenant, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if hub.closed {
+		return nil, ErrWatchClosed
+	}
+
+	sub := &Subscription{
+		hub:    hub,
+		tenant: tenant,
+		filter: filter,
+		events: make(chan WatchEvent, hub.bufferSize),
+	}
+	hub.subscriptions[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// removeLocked ends subscription with err, should be called under the hub lock.
+func (hub *WatchHub) removeLocked(sub *Subscription, err error) {
+	if _, ok := hub.subscriptions[sub]; !ok {
+		return
+	}
+
+	delete(hub.subscriptions, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+// Watched reports if any of subscriptions selects the metric.
+func (hub *WatchHub) Watched(sm *StorageMetric) bool {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for sub := range hub.subscriptions {
+		if sub.filter.Match(sm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Publish delivers events to subscriptions which select their metrics.
+func (hub *WatchHub) Publish(events []WatchEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for sub := range hub.subscriptions {
+		for _, event := range events {
+			if !sub.filter.Match(event.Metric) {
+				continue
+			}
+
+			metric, _ := scoped(sub.tenant, event.Metric)
+			event.Metric = metric
+
+			select {
+			case sub.events <- event:
+				hub.stats.Published++
+				continue
+			default:
+			}
+
+			hub.stats.Lagged++
+			hub.removeLocked(sub, ErrWatchLagged)
+
+			break
+		}
+	}
+}
+
+// Subscriptions returns the number of active subscriptions.
+func (hub *WatchHub) Subscriptions() int {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	return len(hub.subscriptions)
+}
+
+// Stats returns statistics since the hub creation.
+func (hub *WatchHub) Stats() WatchStats {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	stats := hub.stats
+	stats.Subscriptions = len(hub.subscriptions)
+	stats.BufferSize = hub.bufferSize
+
+	return stats
+}
+
+// Close ends all the subscriptions with ErrWatchClosed and rejects new ones.
+func (hub *WatchHub) Close() {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.closed = true
+	for sub := range hub.subscriptions {
+		hub.removeLocked(sub, ErrWatchClosed)
+	}
+}
+
+// WatchStorageWrapper publishes saved and removed metrics of the storage to the hub.
+// Updated metrics selected by subscriptions are read back after the update, so the event has the stored value
+// (sum of counter, merged histogram, version of gauge) which may already include later updates.
+type WatchStorageWrapper struct {
+	StorageInterface
+	hub *WatchHub
+}
+
+func WithWatch(stor StorageInterface, hub *WatchHub) *WatchStorageWrapper {
+	return &WatchStorageWrapper{
+		StorageInterface: stor,
+		hub:              hub,
+	}
+}
+
+func (stor *WatchStorageWrapper) Hub() *WatchHub {
+	return stor.hub
+}
+
+// publishDeleted publishes removed metrics.
+func (stor *WatchStorageWrapper) publishDeleted(deleted []*StorageMetric) {
+	if len(deleted) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	events := make([]WatchEvent, len(deleted))
+	for i, sm := range deleted {
+		events[i] = WatchEvent{
+			Type:      WatchDeleted,
+			Metric:    newStorageMetric(sm.MType, sm.Key()),
+			Timestamp: now,
+		}
+	}
+
+	stor.hub.Publish(events)
+}
+
+func (stor *WatchStorageWrapper) UpdateMetric(ctx context.Context, metric common.Metric) error {
+	return stor.UpdateMetrics(ctx, []common.Metric{metric})
+}
+
+func (stor *WatchStorageWrapper) UpdateMetrics(ctx context.Context, metricsList []common.Metric) error {
+	if err := stor.StorageInterface.UpdateMetrics(ctx, metricsList); err != nil {
+		return err
+	}
+
+	if stor.hub.Subscriptions() == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	events := make([]WatchEvent, 0, len(metricsList))
+	seen := make(map[string]struct{}, len(metricsList))
+
+	for _, metric := range metricsList {
+		key := metric.Key()
+		if _, ok := seen[historyKey(metric.MType, key)]; ok {
+			continue
+		}
+		seen[historyKey(metric.MType, key)] = struct{}{}
+
+		if !stor.hub.Watched(newStorageMetric(metric.MType, key)) {
+			continue
+		}
+
+		// the update is saved, metric which can not be read back is not published
+		sm, err := stor.StorageInterface.GetMetric(ctx, metric.MType, key)
+		if err != nil || sm == nil {
+			continue
+		}
+
+		events = append(events, WatchEvent{Type: WatchUpdated, Metric: sm, Timestamp: now})
+	}
+
+	stor.hub.Publish(events)
+
+	return nil
+}
+
+func (stor *WatchStorageWrapper) EvictExpired(ctx context.Context, policy *TTLPolicy, now time.Time) ([]*StorageMetric, error) {
+	evicted, err := stor.StorageInterface.EvictExpired(ctx, policy, now)
+	stor.publishDeleted(evicted)
+
+	return evicted, err
+}
+
+func (stor *WatchStorageWrapper) DeleteMetric(ctx context.Context, mType string, id string) (bool, error) {
+	deleted, err := stor.StorageInterface.DeleteMetric(ctx, mType, id)
+	if deleted {
+		stor.publishDeleted([]*StorageMetric{newStorageMetric(mType, id)})
+	}
+
+	return deleted, err
+}
+
+func (stor *WatchStorageWrapper) DeleteMetrics(ctx context.Context, filter MetricsFilter) ([]*StorageMetric, error) {
+	deleted, err := stor.StorageInterface.DeleteMetrics(ctx, filter)
+	stor.publishDeleted(deleted)
+
+	return deleted, err
+}
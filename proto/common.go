@@ -5,6 +5,7 @@ import (
 
 	"github.com/GermanVor/devops-pet-project/internal/common"
 	"github.com/GermanVor/devops-pet-project/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func (protoMetric *Metric) Equal(protoMetricA *Metric) bool {
@@ -12,6 +13,10 @@ func (protoMetric *Metric) Equal(protoMetricA *Metric) bool {
 		return false
 	}
 
+	if common.SeriesKey("", protoMetric.Labels) != common.SeriesKey("", protoMetricA.Labels) {
+		return false
+	}
+
 	switch mA := protoMetric.Spec.(type) {
 	case *Metric_Counter:
 		{
@@ -29,6 +34,30 @@ func (protoMetric *Metric) Equal(protoMetricA *Metric) bool {
 				return false
 			}
 		}
+	case *Metric_Rate:
+		{
+			mB, ok := protoMetricA.Spec.(*Metric_Rate)
+
+			if !ok || mA.Rate.Value != mB.Rate.Value {
+				return false
+			}
+		}
+	case *Metric_Histogram:
+		{
+			mB, ok := protoMetricA.Spec.(*Metric_Histogram)
+
+			if !ok || GetHistogram(mA.Histogram).String() != GetHistogram(mB.Histogram).String() {
+				return false
+			}
+		}
+	case *Metric_Summary:
+		{
+			mB, ok := protoMetricA.Spec.(*Metric_Summary)
+
+			if !ok || GetSummary(mA.Summary).String() != GetSummary(mB.Summary).String() {
+				return false
+			}
+		}
 	default:
 		return false
 	}
@@ -36,10 +65,98 @@ func (protoMetric *Metric) Equal(protoMetricA *Metric) bool {
 	return true
 }
 
+func GetHistogram(protoHistogram *HistogramMetric) *common.Histogram {
+	histogram := &common.Histogram{
+		Buckets: make([]common.HistogramBucket, len(protoHistogram.GetBuckets())),
+		Sum:     protoHistogram.GetSum(),
+		Count:   protoHistogram.GetCount(),
+	}
+
+	for i, bucket := range protoHistogram.GetBuckets() {
+		histogram.Buckets[i] = common.HistogramBucket{UpperBound: bucket.UpperBound, Count: bucket.Count}
+	}
+
+	return histogram
+}
+
+func GetProtoHistogram(histogram *common.Histogram) *HistogramMetric {
+	protoHistogram := &HistogramMetric{
+		Buckets: make([]*HistogramBucket, len(histogram.Buckets)),
+		Sum:     histogram.Sum,
+		Count:   histogram.Count,
+	}
+
+	for i, bucket := range histogram.Buckets {
+		protoHistogram.Buckets[i] = &HistogramBucket{UpperBound: bucket.UpperBound, Count: bucket.Count}
+	}
+
+	return protoHistogram
+}
+
+func GetSummary(protoSummary *SummaryMetric) *common.Summary {
+	summary := &common.Summary{
+		Quantiles: make([]common.SummaryQuantile, len(protoSummary.GetQuantiles())),
+		Sum:       protoSummary.GetSum(),
+		Count:     protoSummary.GetCount(),
+	}
+
+	for i, quantile := range protoSummary.GetQuantiles() {
+		summary.Quantiles[i] = common.SummaryQuantile{Quantile: quantile.Quantile, Value: quantile.Value}
+	}
+
+	return summary
+}
+
+func GetProtoSummary(summary *common.Summary) *SummaryMetric {
+	protoSummary := &SummaryMetric{
+		Quantiles: make([]*SummaryQuantile, len(summary.Quantiles)),
+		Sum:       summary.Sum,
+		Count:     summary.Count,
+	}
+
+	for i, quantile := range summary.Quantiles {
+		protoSummary.Quantiles[i] = &SummaryQuantile{Quantile: quantile.Quantile, Value: quantile.Value}
+	}
+
+	return protoSummary
+}
+
+func GetPrecondition(protoPrecondition *Precondition) *common.Precondition {
+	precondition := &common.Precondition{
+		Value:   protoPrecondition.Value,
+		Version: protoPrecondition.Version,
+	}
+
+	if protoPrecondition.Timestamp != nil {
+		timestamp := protoPrecondition.Timestamp.AsTime()
+		precondition.Timestamp = &timestamp
+	}
+
+	return precondition
+}
+
+func GetProtoPrecondition(precondition *common.Precondition) *Precondition {
+	protoPrecondition := &Precondition{
+		Value:   precondition.Value,
+		Version: precondition.Version,
+	}
+
+	if precondition.Timestamp != nil {
+		protoPrecondition.Timestamp = timestamppb.New(*precondition.Timestamp)
+	}
+
+	return protoPrecondition
+}
+
 func (protoMetric *Metric) GetRequestMetric() *common.Metric {
 	metric := &common.Metric{
-		ID:   protoMetric.Id,
-		Hash: protoMetric.Hash,
+		ID:     protoMetric.Id,
+		Hash:   protoMetric.Hash,
+		Labels: protoMetric.Labels,
+	}
+
+	if protoMetric.Precondition != nil {
+		metric.Precondition = GetPrecondition(protoMetric.Precondition)
 	}
 
 	switch m := protoMetric.Spec.(type) {
@@ -53,6 +170,21 @@ func (protoMetric *Metric) GetRequestMetric() *common.Metric {
 			metric.Value = &m.Gauge.Value
 			metric.MType = common.GaugeMetricName
 		}
+	case *Metric_Rate:
+		{
+			metric.Value = &m.Rate.Value
+			metric.MType = common.RateMetricName
+		}
+	case *Metric_Histogram:
+		{
+			metric.Histogram = GetHistogram(m.Histogram)
+			metric.MType = common.HistogramMetricName
+		}
+	case *Metric_Summary:
+		{
+			metric.Summary = GetSummary(m.Summary)
+			metric.MType = common.SummaryMetricName
+		}
 	}
 
 	return metric
@@ -60,14 +192,22 @@ func (protoMetric *Metric) GetRequestMetric() *common.Metric {
 
 func GetProtoStorageMetric(storageMetric *storage.StorageMetric) *Metric {
 	protoMetric := &Metric{
-		Id: storageMetric.ID,
+		Id:     storageMetric.ID,
+		Labels: storageMetric.Labels,
 	}
 
 	switch storageMetric.MType {
 	case common.GaugeMetricName:
 		protoMetric.Spec = &Metric_Gauge{Gauge: &GaugeMetric{Value: storageMetric.Value}}
+		protoMetric.Version = storageMetric.Version
 	case common.CounterMetricName:
 		protoMetric.Spec = &Metric_Counter{Counter: &CounterMetric{Delta: storageMetric.Delta}}
+	case common.RateMetricName:
+		protoMetric.Spec = &Metric_Rate{Rate: &GaugeMetric{Value: storageMetric.Value}}
+	case common.HistogramMetricName:
+		protoMetric.Spec = &Metric_Histogram{Histogram: GetProtoHistogram(storageMetric.Histogram)}
+	case common.SummaryMetricName:
+		protoMetric.Spec = &Metric_Summary{Summary: GetProtoSummary(storageMetric.Summary)}
 	default:
 		log.Fatal()
 	}
@@ -75,9 +215,65 @@ func GetProtoStorageMetric(storageMetric *storage.StorageMetric) *Metric {
 	return protoMetric
 }
 
+// GetProtoSeries returns type and series of the metric.
+func GetProtoSeries(storageMetric *storage.StorageMetric) *Series {
+	return &Series{
+		Id:     storageMetric.ID,
+		Type:   storageMetric.MType,
+		Labels: storageMetric.Labels,
+	}
+}
+
+// GetProtoWatchEvent returns response of WatchMetrics stream with the event.
+func GetProtoWatchEvent(event storage.WatchEvent) *WatchMetricsResponse {
+	resp := &WatchMetricsResponse{
+		Timestamp: timestamppb.New(event.Timestamp),
+	}
+
+	if event.Type == storage.WatchDeleted {
+		resp.Event = &WatchMetricsResponse_Deleted{Deleted: GetProtoSeries(event.Metric)}
+	} else {
+		resp.Event = &WatchMetricsResponse_Updated{Updated: GetProtoStorageMetric(event.Metric)}
+	}
+
+	return resp
+}
+
+func GetProtoHistoryPoint(mType string, point *storage.HistoryPoint) *MetricPoint {
+	protoPoint := &MetricPoint{
+		Timestamp: timestamppb.New(point.Timestamp),
+	}
+
+	switch mType {
+	case common.GaugeMetricName:
+		protoPoint.Spec = &MetricPoint_Gauge{Gauge: &GaugeMetric{Value: point.Value}}
+		protoPoint.Min = point.Min
+		protoPoint.Max = point.Max
+	case common.CounterMetricName:
+		protoPoint.Spec = &MetricPoint_Counter{Counter: &CounterMetric{Delta: point.Delta}}
+	case common.HistogramMetricName:
+		protoPoint.Spec = &MetricPoint_Histogram{Histogram: GetProtoHistogram(point.Histogram)}
+	case common.SummaryMetricName:
+		protoPoint.Spec = &MetricPoint_Summary{Summary: GetProtoSummary(point.Summary)}
+	default:
+		log.Fatal()
+	}
+
+	return protoPoint
+}
+
 func GetProtoMetric(metric *common.Metric) *Metric {
 	protoMetric := &Metric{
-		Id: metric.ID,
+		Id:     metric.ID,
+		Hash:   metric.Hash,
+		Labels: metric.Labels,
+	}
+
+	if metric.Version != nil {
+		protoMetric.Version = *metric.Version
+	}
+	if metric.Precondition != nil {
+		protoMetric.Precondition = GetProtoPrecondition(metric.Precondition)
 	}
 
 	switch metric.MType {
@@ -89,9 +285,21 @@ func GetProtoMetric(metric *common.Metric) *Metric {
 		}
 	case common.CounterMetricName:
 		if metric.Delta == nil {
+			protoMetric.Spec = &Metric_Counter{Counter: &CounterMetric{Delta: 0}}
+		} else {
 			protoMetric.Spec = &Metric_Counter{Counter: &CounterMetric{Delta: *metric.Delta}}
+		}
+	case common.HistogramMetricName:
+		if metric.Histogram == nil {
+			protoMetric.Spec = &Metric_Histogram{Histogram: &HistogramMetric{}}
 		} else {
-			protoMetric.Spec = &Metric_Counter{Counter: &CounterMetric{Delta: 0}}
+			protoMetric.Spec = &Metric_Histogram{Histogram: GetProtoHistogram(metric.Histogram)}
+		}
+	case common.SummaryMetricName:
+		if metric.Summary == nil {
+			protoMetric.Spec = &Metric_Summary{Summary: &SummaryMetric{}}
+		} else {
+			protoMetric.Spec = &Metric_Summary{Summary: GetProtoSummary(metric.Summary)}
 		}
 	default:
 		log.Fatal()